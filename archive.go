@@ -0,0 +1,199 @@
+package imgutil
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// ArchiveFormat is the format of the tar file written when saving an image to a file.
+type ArchiveFormat string
+
+const (
+	// DockerArchive is the format written by `docker save` and read by `docker load`.
+	DockerArchive ArchiveFormat = "docker-archive"
+	// OCIArchive is a tar of an OCI image layout (`oci-layout`, `index.json` and `blobs/`).
+	OCIArchive ArchiveFormat = "oci-archive"
+)
+
+// SaveArchiveFile writes the image to a new temporary tar file in the provided format (DockerArchive if empty),
+// referencing the image by the provided name (if any), and returns the path of the file.
+func SaveArchiveFile(image v1.Image, withName string, format ArchiveFormat) (string, error) {
+	f, err := os.CreateTemp("", "imgutil.image.export.*.tar")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer func() {
+		f.Close()
+		if err != nil {
+			os.Remove(f.Name())
+		}
+	}()
+
+	if err = WriteArchive(f, image, withName, format); err != nil {
+		return "", err
+	}
+	if err = f.Close(); err != nil {
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// WriteArchive writes the image to w as a tar in the provided format (DockerArchive if empty),
+// referencing the image by the provided name (if any).
+func WriteArchive(w io.Writer, image v1.Image, withName string, format ArchiveFormat) error {
+	var (
+		ref name.Reference
+		err error
+	)
+	if withName != "" {
+		if ref, err = name.ParseReference(withName, name.WeakValidation); err != nil {
+			return fmt.Errorf("failed to parse reference %q: %w", withName, err)
+		}
+	}
+	switch format {
+	case "", DockerArchive:
+		if ref == nil {
+			// a digest reference is not tagged in the archive, so the repository doesn't matter
+			digest, err := image.Digest()
+			if err != nil {
+				return err
+			}
+			if ref, err = name.NewDigest("untagged@" + digest.String()); err != nil {
+				return err
+			}
+		}
+		return tarball.Write(ref, image, w)
+	case OCIArchive:
+		return writeOCIArchive(w, image, ref)
+	default:
+		return fmt.Errorf("unsupported archive format %q", format)
+	}
+}
+
+func writeOCIArchive(w io.Writer, image v1.Image, ref name.Reference) error {
+	tw := tar.NewWriter(w)
+	if err := addFileToTar(tw, "oci-layout", []byte(`{"imageLayoutVersion":"1.0.0"}`)); err != nil {
+		return err
+	}
+
+	// layers
+	layers, err := image.Layers()
+	if err != nil {
+		return err
+	}
+	written := make(map[v1.Hash]bool)
+	for _, layer := range layers {
+		digest, err := layer.Digest()
+		if err != nil {
+			return err
+		}
+		if written[digest] {
+			continue
+		}
+		if err = addLayerBlobToTar(tw, layer, digest); err != nil {
+			return fmt.Errorf("failed to write layer %s: %w", digest, err)
+		}
+		written[digest] = true
+	}
+
+	// config
+	configName, err := image.ConfigName()
+	if err != nil {
+		return err
+	}
+	rawConfig, err := image.RawConfigFile()
+	if err != nil {
+		return err
+	}
+	if err = addFileToTar(tw, blobPath(configName), rawConfig); err != nil {
+		return err
+	}
+
+	// manifest
+	digest, err := image.Digest()
+	if err != nil {
+		return err
+	}
+	rawManifest, err := image.RawManifest()
+	if err != nil {
+		return err
+	}
+	if err = addFileToTar(tw, blobPath(digest), rawManifest); err != nil {
+		return err
+	}
+
+	// index
+	mediaType, err := image.MediaType()
+	if err != nil {
+		return err
+	}
+	manifest, err := image.Manifest()
+	if err != nil {
+		return err
+	}
+	annotations := make(map[string]string)
+	for k, v := range manifest.Annotations {
+		annotations[k] = v
+	}
+	if ref != nil {
+		annotations["io.containerd.image.name"] = ref.Name()
+		if _, ok := ref.(name.Tag); ok {
+			annotations["org.opencontainers.image.ref.name"] = ref.Identifier()
+		}
+	}
+	rawIndex, err := json.Marshal(v1.IndexManifest{
+		SchemaVersion: 2,
+		MediaType:     types.OCIImageIndex,
+		Manifests: []v1.Descriptor{{
+			MediaType:   mediaType,
+			Size:        int64(len(rawManifest)),
+			Digest:      digest,
+			Annotations: annotations,
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	if err = addFileToTar(tw, "index.json", rawIndex); err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+func addLayerBlobToTar(tw *tar.Writer, layer v1.Layer, digest v1.Hash) error {
+	size, err := layer.Size()
+	if err != nil {
+		return err
+	}
+	rc, err := layer.Compressed()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if err = tw.WriteHeader(&tar.Header{Name: blobPath(digest), Mode: 0644, Size: size}); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, rc)
+	return err
+}
+
+func addFileToTar(tw *tar.Writer, withName string, contents []byte) error {
+	if err := tw.WriteHeader(&tar.Header{Name: withName, Mode: 0644, Size: int64(len(contents))}); err != nil {
+		return err
+	}
+	_, err := tw.Write(contents)
+	return err
+}
+
+func blobPath(digest v1.Hash) string {
+	return path.Join("blobs", digest.Algorithm, digest.Hex)
+}
@@ -0,0 +1,86 @@
+package archive
+
+import (
+	"context"
+	"os"
+
+	"github.com/pkg/errors"
+
+	"github.com/buildpacks/imgutil"
+)
+
+var _ imgutil.ImageWithContext = (*Image)(nil)
+
+// Image wraps an imgutil.CNBImageCore and implements the methods needed to complete the imgutil.Image interface,
+// for images stored in a tar file (a docker archive or an OCI archive) on disk.
+type Image struct {
+	*imgutil.CNBImageCore
+	ctx           context.Context
+	path          string
+	archiveFormat imgutil.ArchiveFormat
+}
+
+func (i *Image) Kind() string {
+	return "archive"
+}
+
+func (i *Image) Name() string {
+	return i.path
+}
+
+func (i *Image) Rename(name string) {
+	i.path = name
+}
+
+// Found reports if an archive exists at `Name()`.
+func (i *Image) Found() bool {
+	return archiveExists(i.path)
+}
+
+// FoundWithContext is like Found; it only accesses the filesystem.
+func (i *Image) FoundWithContext(_ context.Context) bool {
+	return i.Found()
+}
+
+func archiveExists(path string) bool {
+	if path == "" {
+		return false
+	}
+	fi, err := os.Stat(path)
+	return err == nil && fi.Mode().IsRegular()
+}
+
+// Identifier
+// As with images in a docker daemon, the ID of an image in an archive is the digest of its config,
+// because docker archives do not store image manifests.
+func (i *Image) Identifier() (imgutil.Identifier, error) {
+	configName, err := i.ConfigName()
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting identifier for image at path %q", i.path)
+	}
+	return Identifier{
+		ImageID: configName.String(),
+		Path:    i.path,
+	}, nil
+}
+
+// Valid returns true if the archive at `Name()` is a docker archive or an OCI archive.
+func (i *Image) Valid() bool {
+	index, err := indexArchive(i.path)
+	if err != nil {
+		return false
+	}
+	_, err = index.format()
+	return err == nil
+}
+
+func (i *Image) Delete() error {
+	return i.DeleteWithContext(i.ctx)
+}
+
+func (i *Image) DeleteWithContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return os.Remove(i.path)
+}
@@ -0,0 +1,330 @@
+package archive_test
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/compression"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil"
+	"github.com/buildpacks/imgutil/archive"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestArchive(t *testing.T) {
+	spec.Run(t, "Image", testImage, spec.Sequential(), spec.Report(report.Terminal{}))
+}
+
+func testImage(t *testing.T, when spec.G, it spec.S) {
+	var (
+		tmpDir    string
+		imagePath string
+		layerPath string
+		layerSHA  string
+		err       error
+	)
+
+	it.Before(func() {
+		tmpDir, err = os.MkdirTemp("", "archive")
+		h.AssertNil(t, err)
+		imagePath = filepath.Join(tmpDir, "image.tar")
+		layerPath, layerSHA, _ = h.RandomLayer(t, tmpDir)
+	})
+
+	it.After(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	// saveBaseImage saves an image with a label and a layer to an archive in the given format
+	saveBaseImage := func(path string, format imgutil.ArchiveFormat) {
+		baseImage, err := archive.NewImage(path, imgutil.WithArchiveFormat(format))
+		h.AssertNil(t, err)
+		h.AssertNil(t, baseImage.SetLabel("some-label", "some-value"))
+		h.AssertNil(t, baseImage.AddLayer(layerPath))
+		h.AssertNil(t, baseImage.Save())
+	}
+
+	when("#NewImage", func() {
+		it("returns an empty image for the default platform", func() {
+			img, err := archive.NewImage(imagePath)
+			h.AssertNil(t, err)
+
+			h.AssertEq(t, img.Kind(), "archive")
+			h.AssertEq(t, img.Found(), false)
+			osName, err := img.OS()
+			h.AssertNil(t, err)
+			h.AssertEq(t, osName, "linux")
+			layers, err := img.Layers()
+			h.AssertNil(t, err)
+			h.AssertEq(t, len(layers), 0)
+		})
+
+		when("#FromBaseImage", func() {
+			for _, format := range []imgutil.ArchiveFormat{imgutil.DockerArchive, imgutil.OCIArchive} {
+				format := format
+
+				when("the base image is a "+string(format), func() {
+					var basePath string
+
+					it.Before(func() {
+						basePath = filepath.Join(tmpDir, "base.tar")
+						saveBaseImage(basePath, format)
+					})
+
+					it("sets the initial state from the base image", func() {
+						img, err := archive.NewImage(imagePath, imgutil.FromBaseImage(basePath))
+						h.AssertNil(t, err)
+
+						label, err := img.Label("some-label")
+						h.AssertNil(t, err)
+						h.AssertEq(t, label, "some-value")
+						topLayer, err := img.TopLayer()
+						h.AssertNil(t, err)
+						h.AssertEq(t, topLayer, layerSHA)
+
+						rc, err := img.GetLayer(layerSHA)
+						h.AssertNil(t, err)
+						h.AssertNil(t, rc.Close())
+					})
+				})
+			}
+
+			when("the base image is for another platform", func() {
+				it("returns an error", func() {
+					basePath := filepath.Join(tmpDir, "base.tar")
+					baseImage, err := archive.NewImage(basePath, imgutil.WithDefaultPlatform(imgutil.Platform{OS: "linux", Architecture: "arm64"}))
+					h.AssertNil(t, err)
+					h.AssertNil(t, baseImage.Save())
+
+					_, err = archive.NewImage(imagePath, imgutil.FromBaseImage(basePath))
+					h.AssertError(t, err, "failed to find manifest matching platform linux/amd64")
+					h.AssertEq(t, errors.Is(err, imgutil.ErrPlatformMismatch), true)
+
+					img, err := archive.NewImage(imagePath, imgutil.FromBaseImage(basePath),
+						imgutil.WithDefaultPlatform(imgutil.Platform{OS: "linux", Architecture: "arm64"}))
+					h.AssertNil(t, err)
+					arch, err := img.Architecture()
+					h.AssertNil(t, err)
+					h.AssertEq(t, arch, "arm64")
+				})
+			})
+
+			when("the base image is a docker archive with uncompressed layers", func() {
+				it("sets the initial state from the base image", func() {
+					basePath := filepath.Join(tmpDir, "base.tar")
+					writeDockerSaveArchive(t, basePath, layerPath, layerSHA)
+
+					img, err := archive.NewImage(imagePath, imgutil.FromBaseImage(basePath))
+					h.AssertNil(t, err)
+
+					topLayer, err := img.TopLayer()
+					h.AssertNil(t, err)
+					h.AssertEq(t, topLayer, layerSHA)
+					rc, err := img.GetLayer(layerSHA)
+					h.AssertNil(t, err)
+					contents, err := io.ReadAll(rc)
+					h.AssertNil(t, err)
+					h.AssertNil(t, rc.Close())
+					expected, err := os.ReadFile(layerPath)
+					h.AssertNil(t, err)
+					h.AssertEq(t, contents, expected)
+				})
+			})
+
+			when("the base image does not exist", func() {
+				it("returns an empty image", func() {
+					img, err := archive.NewImage(imagePath, imgutil.FromBaseImage(filepath.Join(tmpDir, "does-not-exist.tar")))
+					h.AssertNil(t, err)
+
+					layers, err := img.Layers()
+					h.AssertNil(t, err)
+					h.AssertEq(t, len(layers), 0)
+				})
+
+				it("returns an error with #WithStrictBaseImage", func() {
+					_, err := archive.NewImage(imagePath, imgutil.FromBaseImage(filepath.Join(tmpDir, "does-not-exist.tar")), imgutil.WithStrictBaseImage())
+					h.AssertError(t, err, "failed to find archive")
+					h.AssertEq(t, errors.Is(err, imgutil.ErrImageNotFound), true)
+				})
+			})
+
+			when("the base image is not an archive of an image", func() {
+				it("returns an error", func() {
+					_, err := archive.NewImage(imagePath, imgutil.FromBaseImage(layerPath))
+					h.AssertError(t, err, "is neither a docker archive nor an OCI archive")
+				})
+			})
+		})
+
+		when("#WithPreviousImage", func() {
+			it("reuses layers from the previous image", func() {
+				previousPath := filepath.Join(tmpDir, "previous.tar")
+				saveBaseImage(previousPath, imgutil.OCIArchive)
+
+				img, err := archive.NewImage(imagePath, imgutil.WithPreviousImage(previousPath))
+				h.AssertNil(t, err)
+				h.AssertNil(t, img.ReuseLayer(layerSHA))
+				h.AssertNil(t, img.Save())
+
+				saved, err := tarball.ImageFromPath(imagePath, nil)
+				h.AssertNil(t, err)
+				assertDiffIDs(t, saved, layerSHA)
+			})
+		})
+	})
+
+	when("#Save", func() {
+		it("writes a docker archive by default", func() {
+			img, err := archive.NewImage(imagePath)
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.AddLayer(layerPath))
+
+			h.AssertNil(t, img.Save())
+
+			h.AssertEq(t, img.Found(), true)
+			h.AssertEq(t, img.Valid(), true)
+			saved, err := tarball.ImageFromPath(imagePath, nil)
+			h.AssertNil(t, err)
+			assertDiffIDs(t, saved, layerSHA)
+		})
+
+		it("writes the archive to additional paths", func() {
+			img, err := archive.NewImage(imagePath)
+			h.AssertNil(t, err)
+			otherPath := filepath.Join(tmpDir, "other-image.tar")
+
+			h.AssertNil(t, img.Save(otherPath))
+
+			h.AssertPathExists(t, imagePath)
+			h.AssertPathExists(t, otherPath)
+		})
+
+		it("recompresses the layers of the base image with #WithLayerCompression", func() {
+			basePath := filepath.Join(tmpDir, "base.tar")
+			saveBaseImage(basePath, imgutil.DockerArchive)
+			img, err := archive.NewImage(
+				imagePath,
+				imgutil.FromBaseImage(basePath),
+				imgutil.WithArchiveFormat(imgutil.OCIArchive),
+				imgutil.WithLayerCompression(imgutil.LayerCompression{Algorithm: compression.ZStd}),
+			)
+			h.AssertNil(t, err)
+
+			h.AssertNil(t, img.Save())
+
+			saved, err := archive.NewImage(filepath.Join(tmpDir, "other-image.tar"), imgutil.FromBaseImage(imagePath))
+			h.AssertNil(t, err)
+			manifest, err := saved.Manifest()
+			h.AssertNil(t, err)
+			h.AssertEq(t, len(manifest.Layers), 1)
+			h.AssertEq(t, manifest.Layers[0].MediaType, types.OCILayerZStd)
+			assertDiffIDs(t, saved, layerSHA)
+		})
+
+		it("replaces an existing archive without leaving temporary files", func() {
+			saveBaseImage(imagePath, imgutil.DockerArchive)
+			img, err := archive.NewImage(imagePath, imgutil.FromBaseImage(imagePath))
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.SetLabel("other-label", "other-value"))
+
+			h.AssertNil(t, img.Save())
+
+			saved, err := tarball.ImageFromPath(imagePath, nil)
+			h.AssertNil(t, err)
+			configFile, err := saved.ConfigFile()
+			h.AssertNil(t, err)
+			h.AssertEq(t, configFile.Config.Labels["other-label"], "other-value")
+			entries, err := os.ReadDir(tmpDir)
+			h.AssertNil(t, err)
+			for _, entry := range entries {
+				h.AssertEq(t, filepath.Ext(entry.Name()) == ".tmp", false)
+			}
+		})
+	})
+
+	when("#SaveFile", func() {
+		it("writes the archive to a temporary file", func() {
+			img, err := archive.NewImage(imagePath, imgutil.WithArchiveFormat(imgutil.OCIArchive))
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.AddLayer(layerPath))
+
+			path, err := img.SaveFile()
+			h.AssertNil(t, err)
+			defer os.Remove(path)
+
+			h.AssertEq(t, img.Found(), false)
+			fromFile, err := archive.NewImage(imagePath, imgutil.FromBaseImage(path))
+			h.AssertNil(t, err)
+			assertDiffIDs(t, fromFile, layerSHA)
+		})
+	})
+
+	when("#Delete", func() {
+		it("removes the archive", func() {
+			img, err := archive.NewImage(imagePath)
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.Save())
+			h.AssertEq(t, img.Found(), true)
+
+			h.AssertNil(t, img.Delete())
+
+			h.AssertEq(t, img.Found(), false)
+		})
+	})
+}
+
+func assertDiffIDs(t *testing.T, image v1.Image, expected ...string) {
+	t.Helper()
+	configFile, err := image.ConfigFile()
+	h.AssertNil(t, err)
+	var diffIDs []string
+	for _, diffID := range configFile.RootFS.DiffIDs {
+		diffIDs = append(diffIDs, diffID.String())
+	}
+	h.AssertEq(t, diffIDs, expected)
+}
+
+// writeDockerSaveArchive writes a docker archive with a single uncompressed layer, as written by `docker save`
+func writeDockerSaveArchive(t *testing.T, path, layerPath, layerSHA string) {
+	t.Helper()
+	diffID, err := v1.NewHash(layerSHA)
+	h.AssertNil(t, err)
+	rawConfig, err := json.Marshal(v1.ConfigFile{
+		Architecture: "amd64",
+		OS:           "linux",
+		RootFS:       v1.RootFS{Type: "layers", DiffIDs: []v1.Hash{diffID}},
+	})
+	h.AssertNil(t, err)
+	layer, err := os.ReadFile(layerPath)
+	h.AssertNil(t, err)
+	rawManifest, err := json.Marshal(tarball.Manifest{{Config: "config.json", Layers: []string{diffID.Hex + "/layer.tar"}}})
+	h.AssertNil(t, err)
+
+	f, err := os.Create(path)
+	h.AssertNil(t, err)
+	defer f.Close()
+	tw := tar.NewWriter(f)
+	for _, entry := range []struct {
+		name     string
+		contents []byte
+	}{
+		{"config.json", rawConfig},
+		{diffID.Hex + "/layer.tar", layer},
+		{"manifest.json", rawManifest},
+	} {
+		h.AssertNil(t, tw.WriteHeader(&tar.Header{Name: entry.name, Mode: 0644, Size: int64(len(entry.contents)), Typeflag: tar.TypeReg}))
+		_, err = tw.Write(entry.contents)
+		h.AssertNil(t, err)
+	}
+	h.AssertNil(t, tw.Close())
+}
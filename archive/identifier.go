@@ -0,0 +1,14 @@
+package archive
+
+import "fmt"
+
+const identifierDelim = "@"
+
+type Identifier struct {
+	ImageID string
+	Path    string
+}
+
+func (i Identifier) String() string {
+	return fmt.Sprintf("%s%s%s", i.Path, identifierDelim, i.ImageID)
+}
@@ -0,0 +1,108 @@
+package archive
+
+import (
+	"fmt"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+
+	"github.com/buildpacks/imgutil"
+)
+
+// NewImage returns a new image that can be modified and saved to a tar file at the provided path.
+// Base and previous images (provided with imgutil.FromBaseImage and imgutil.WithPreviousImage) are read from
+// docker archives or OCI archives at the provided paths.
+func NewImage(path string, ops ...imgutil.ImageOption) (*Image, error) {
+	options := &imgutil.ImageOptions{}
+	for _, op := range ops {
+		op(options)
+	}
+
+	options.Platform = processPlatformOption(options.Platform)
+
+	var err error
+	if options.BaseImage == nil && options.BaseImageRepoName != "" { // options.BaseImage supersedes options.BaseImageRepoName
+		var baseImage imageResult
+		baseImage, err = newImageFromArchive(options.BaseImageRepoName, options.Platform, options.StrictBaseImage)
+		if err != nil {
+			return nil, err
+		}
+		options.BaseImage = baseImage.image
+		options.BaseImageDigest = baseImage.digest
+	}
+	options.MediaTypes = imgutil.GetPreferredMediaTypes(*options)
+	if options.BaseImage != nil {
+		options.BaseImage, _, err = imgutil.EnsureMediaTypesAndLayers(options.BaseImage, options.MediaTypes, imgutil.PreserveLayers)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if options.PreviousImage == nil && options.PreviousImageRepoName != "" {
+		previousImage, err := newImageFromArchive(options.PreviousImageRepoName, options.Platform, options.StrictPreviousImage)
+		if err != nil {
+			return nil, err
+		}
+		options.PreviousImage = previousImage.image
+	}
+
+	cnbImage, err := imgutil.NewCNBImage(*options)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Image{
+		CNBImageCore:  cnbImage,
+		ctx:           imgutil.ContextOrBackground(options.Context),
+		path:          path,
+		archiveFormat: options.ArchiveFormat,
+	}, nil
+}
+
+func processPlatformOption(requestedPlatform imgutil.Platform) imgutil.Platform {
+	if !requestedPlatform.IsZero() {
+		return requestedPlatform
+	}
+	return imgutil.Platform{
+		OS:           "linux",
+		Architecture: "amd64",
+	}
+}
+
+type imageResult struct {
+	image  v1.Image
+	digest string // empty for docker archives, as they do not store image manifests
+}
+
+// newImageFromArchive reads an image from the docker archive or OCI archive at the given path.
+// * If the archive contains images for multiple platforms, it will select the image according to the platform provided.
+// * If the archive does not exist, then nothing is returned, unless strict is true.
+func newImageFromArchive(path string, withPlatform imgutil.Platform, strict bool) (imageResult, error) {
+	if !archiveExists(path) {
+		if strict {
+			return imageResult{}, fmt.Errorf("failed to find archive %q: %w", path, imgutil.ErrImageNotFound)
+		}
+		return imageResult{}, nil
+	}
+	index, err := indexArchive(path)
+	if err != nil {
+		return imageResult{}, fmt.Errorf("failed to read archive %q: %w", path, err)
+	}
+	format, err := index.format()
+	if err != nil {
+		return imageResult{}, err
+	}
+	switch format {
+	case imgutil.DockerArchive:
+		image, err := dockerArchiveImageFrom(index, withPlatform)
+		if err != nil {
+			return imageResult{}, fmt.Errorf("failed to read docker archive %q: %w", path, err)
+		}
+		return imageResult{image: image}, nil
+	default:
+		image, digest, err := ociArchiveImageFrom(index, withPlatform)
+		if err != nil {
+			return imageResult{}, fmt.Errorf("failed to read OCI archive %q: %w", path, err)
+		}
+		return imageResult{image: image, digest: digest.String()}, nil
+	}
+}
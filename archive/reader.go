@@ -0,0 +1,444 @@
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+
+	"github.com/buildpacks/imgutil"
+)
+
+var errEntryNotFound = errors.New("entry not found in archive")
+
+// archiveIndex records where the contents of the regular files of an archive are,
+// so that each of them can be read without scanning the archive again.
+type archiveIndex struct {
+	path    string
+	entries map[string]archiveEntry
+}
+
+type archiveEntry struct {
+	offset int64
+	size   int64
+}
+
+// indexArchive scans the archive at the given path once and returns its index.
+func indexArchive(archivePath string) (*archiveIndex, error) {
+	f, err := os.Open(filepath.Clean(archivePath))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	index := &archiveIndex{path: archivePath, entries: make(map[string]archiveEntry)}
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return index, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		// the tar reader does not buffer, so the file is positioned at the start of the contents of the entry
+		offset, err := f.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+		index.entries[path.Clean(hdr.Name)] = archiveEntry{offset: offset, size: hdr.Size}
+	}
+}
+
+// format returns the format of the archive.
+// Archives written by recent versions of docker are in both formats, in which case DockerArchive is returned.
+func (a *archiveIndex) format() (imgutil.ArchiveFormat, error) {
+	switch {
+	case a.has("manifest.json"):
+		return imgutil.DockerArchive, nil
+	case a.has("index.json"):
+		return imgutil.OCIArchive, nil
+	default:
+		return "", fmt.Errorf("%q is neither a docker archive nor an OCI archive", a.path)
+	}
+}
+
+func (a *archiveIndex) has(name string) bool {
+	_, ok := a.entries[name]
+	return ok
+}
+
+func (a *archiveIndex) read(name string) ([]byte, error) {
+	rc, err := a.open(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %q: %w", name, err)
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// open returns a reader for the entry with the given name; the archive is closed when the reader is closed.
+func (a *archiveIndex) open(name string) (io.ReadCloser, error) {
+	entry, ok := a.entries[name]
+	if !ok {
+		return nil, fmt.Errorf("failed to open %q: %w", name, errEntryNotFound)
+	}
+	f, err := os.Open(filepath.Clean(a.path))
+	if err != nil {
+		return nil, err
+	}
+	return &entryReader{Reader: io.NewSectionReader(f, entry.offset, entry.size), file: f}, nil
+}
+
+type entryReader struct {
+	io.Reader
+	file *os.File
+}
+
+func (r *entryReader) Close() error {
+	return r.file.Close()
+}
+
+func blobPath(digest v1.Hash) string {
+	return path.Join("blobs", digest.Algorithm, digest.Hex)
+}
+
+// ociArchiveImageFrom returns the image in the OCI archive at the given path matching the given platform,
+// along with the digest of its manifest. Blobs are read from the archive when needed, without extracting it.
+func ociArchiveImageFrom(index *archiveIndex, withPlatform imgutil.Platform) (v1.Image, v1.Hash, error) {
+	rawIndex, err := index.read("index.json")
+	if err != nil {
+		return nil, v1.Hash{}, err
+	}
+	desc, err := manifestFor(index, rawIndex, withPlatform)
+	if err != nil {
+		return nil, v1.Hash{}, err
+	}
+	rawManifest, err := index.read(blobPath(desc.Digest))
+	if err != nil {
+		return nil, v1.Hash{}, err
+	}
+	manifest, err := v1.ParseManifest(bytes.NewReader(rawManifest))
+	if err != nil {
+		return nil, v1.Hash{}, err
+	}
+	image, err := partial.CompressedToImage(&compressedArchiveImage{
+		index:       index,
+		manifest:    manifest,
+		mediaType:   desc.MediaType,
+		rawManifest: rawManifest,
+	})
+	if err != nil {
+		return nil, v1.Hash{}, err
+	}
+	return image, desc.Digest, nil
+}
+
+// manifestFor returns the descriptor of the image manifest matching the given platform,
+// looking into nested indexes if needed.
+func manifestFor(index *archiveIndex, rawIndex []byte, withPlatform imgutil.Platform) (v1.Descriptor, error) {
+	var indexManifest v1.IndexManifest
+	if err := json.Unmarshal(rawIndex, &indexManifest); err != nil {
+		return v1.Descriptor{}, fmt.Errorf("failed to parse index: %w", err)
+	}
+	var candidates []v1.Descriptor
+	for _, desc := range indexManifest.Manifests {
+		if desc.MediaType.IsImage() || desc.MediaType.IsIndex() {
+			candidates = append(candidates, desc)
+		}
+	}
+	if len(candidates) == 0 {
+		return v1.Descriptor{}, fmt.Errorf("failed to find manifest matching platform %s: %w", withPlatform, imgutil.ErrPlatformMismatch)
+	}
+	found := candidates[0]
+	if len(candidates) > 1 {
+		var err error
+		if found, err = imgutil.FindManifestForPlatform(candidates, withPlatform); err != nil {
+			return v1.Descriptor{}, err
+		}
+	}
+	if found.MediaType.IsIndex() {
+		rawChild, err := index.read(blobPath(found.Digest))
+		if err != nil {
+			return v1.Descriptor{}, err
+		}
+		return manifestFor(index, rawChild, withPlatform)
+	}
+	return found, nil
+}
+
+// compressedArchiveImage implements partial.CompressedImageCore for an image with compressed layers in an archive.
+type compressedArchiveImage struct {
+	index       *archiveIndex
+	manifest    *v1.Manifest
+	mediaType   types.MediaType
+	rawManifest []byte
+	// paths are the names of the entries of blobs that are not stored under "blobs/<algorithm>/<hex>"
+	paths map[v1.Hash]string
+}
+
+func (i *compressedArchiveImage) pathOf(digest v1.Hash) string {
+	if p, ok := i.paths[digest]; ok {
+		return p
+	}
+	return blobPath(digest)
+}
+
+func (i *compressedArchiveImage) RawConfigFile() ([]byte, error) {
+	return i.index.read(i.pathOf(i.manifest.Config.Digest))
+}
+
+func (i *compressedArchiveImage) MediaType() (types.MediaType, error) {
+	return i.mediaType, nil
+}
+
+func (i *compressedArchiveImage) RawManifest() ([]byte, error) {
+	return i.rawManifest, nil
+}
+
+func (i *compressedArchiveImage) LayerByDigest(digest v1.Hash) (partial.CompressedLayer, error) {
+	for _, desc := range i.manifest.Layers {
+		if desc.Digest == digest {
+			return &compressedArchiveLayer{index: i.index, desc: desc, path: i.pathOf(digest)}, nil
+		}
+	}
+	return nil, fmt.Errorf("failed to find layer with digest %s in manifest", digest)
+}
+
+type compressedArchiveLayer struct {
+	index *archiveIndex
+	desc  v1.Descriptor
+	path  string
+}
+
+func (l *compressedArchiveLayer) Digest() (v1.Hash, error) {
+	return l.desc.Digest, nil
+}
+
+func (l *compressedArchiveLayer) Compressed() (io.ReadCloser, error) {
+	return l.index.open(l.path)
+}
+
+func (l *compressedArchiveLayer) Size() (int64, error) {
+	return l.desc.Size, nil
+}
+
+func (l *compressedArchiveLayer) MediaType() (types.MediaType, error) {
+	return l.desc.MediaType, nil
+}
+
+// dockerArchiveImageFrom returns the image in the docker archive matching the given platform.
+// Blobs are read from the archive when needed, without extracting it.
+// Layers are either all uncompressed (as written by `docker save`) or all compressed (as written by go-containerregistry).
+func dockerArchiveImageFrom(index *archiveIndex, withPlatform imgutil.Platform) (v1.Image, error) {
+	rawManifest, err := index.read("manifest.json")
+	if err != nil {
+		return nil, err
+	}
+	var manifest tarball.Manifest
+	if err = json.Unmarshal(rawManifest, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	entry, rawConfig, err := dockerEntryFor(index, manifest, withPlatform)
+	if err != nil {
+		return nil, err
+	}
+	configFile, err := v1.ParseConfigFile(bytes.NewReader(rawConfig))
+	if err != nil {
+		return nil, err
+	}
+	if len(configFile.RootFS.DiffIDs) != len(entry.Layers) {
+		return nil, fmt.Errorf("config has %d layers but manifest has %d", len(configFile.RootFS.DiffIDs), len(entry.Layers))
+	}
+	layerPaths := make([]string, len(entry.Layers))
+	for idx, layer := range entry.Layers {
+		layerPaths[idx] = path.Clean(layer)
+	}
+
+	var compression types.MediaType
+	if len(layerPaths) > 0 {
+		if compression, err = compressionOf(index, layerPaths[0]); err != nil {
+			return nil, err
+		}
+	}
+	if compression == "" {
+		return partial.UncompressedToImage(&dockerArchiveImage{
+			index:      index,
+			rawConfig:  rawConfig,
+			diffIDs:    configFile.RootFS.DiffIDs,
+			layerPaths: layerPaths,
+		})
+	}
+	return compressedDockerArchiveImage(index, path.Clean(entry.Config), rawConfig, layerPaths, compression)
+}
+
+// dockerEntryFor returns the entry of the docker archive manifest whose config matches the given platform,
+// along with the config. An archive with a single image without platform can be used for any platform.
+func dockerEntryFor(index *archiveIndex, manifest tarball.Manifest, withPlatform imgutil.Platform) (tarball.Descriptor, []byte, error) {
+	type candidate struct {
+		entry     tarball.Descriptor
+		rawConfig []byte
+	}
+	var (
+		descs      []v1.Descriptor
+		candidates = make(map[v1.Hash]candidate)
+	)
+	for _, entry := range manifest {
+		rawConfig, err := index.read(path.Clean(entry.Config))
+		if err != nil {
+			return tarball.Descriptor{}, nil, err
+		}
+		configFile, err := v1.ParseConfigFile(bytes.NewReader(rawConfig))
+		if err != nil {
+			return tarball.Descriptor{}, nil, err
+		}
+		digest, _, err := v1.SHA256(bytes.NewReader(rawConfig))
+		if err != nil {
+			return tarball.Descriptor{}, nil, err
+		}
+		descs = append(descs, v1.Descriptor{Digest: digest, Platform: configFile.Platform()})
+		candidates[digest] = candidate{entry: entry, rawConfig: rawConfig}
+	}
+	if len(descs) == 1 && descs[0].Platform == nil {
+		found := candidates[descs[0].Digest]
+		return found.entry, found.rawConfig, nil
+	}
+	desc, err := imgutil.FindManifestForPlatform(descs, withPlatform)
+	if err != nil {
+		return tarball.Descriptor{}, nil, err
+	}
+	found := candidates[desc.Digest]
+	return found.entry, found.rawConfig, nil
+}
+
+// compressionOf returns the media type of the layer at the given path in the archive if it is compressed,
+// or an empty string otherwise.
+func compressionOf(index *archiveIndex, layerPath string) (types.MediaType, error) {
+	rc, err := index.open(layerPath)
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	magic := make([]byte, 4)
+	n, err := io.ReadFull(rc, magic)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	switch {
+	case bytes.HasPrefix(magic[:n], []byte{0x1f, 0x8b}):
+		return types.DockerLayer, nil
+	case bytes.HasPrefix(magic[:n], []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		return types.OCILayerZStd, nil
+	default:
+		return "", nil
+	}
+}
+
+// dockerArchiveImage implements partial.UncompressedImageCore for an image with uncompressed layers in a docker archive.
+type dockerArchiveImage struct {
+	index      *archiveIndex
+	rawConfig  []byte
+	diffIDs    []v1.Hash
+	layerPaths []string
+}
+
+func (i *dockerArchiveImage) RawConfigFile() ([]byte, error) {
+	return i.rawConfig, nil
+}
+
+func (i *dockerArchiveImage) MediaType() (types.MediaType, error) {
+	return types.DockerManifestSchema2, nil
+}
+
+func (i *dockerArchiveImage) LayerByDiffID(diffID v1.Hash) (partial.UncompressedLayer, error) {
+	for idx, layerDiffID := range i.diffIDs {
+		if layerDiffID == diffID {
+			return &dockerArchiveLayer{index: i.index, diffID: diffID, path: i.layerPaths[idx]}, nil
+		}
+	}
+	return nil, fmt.Errorf("failed to find layer with diff ID %s in config", diffID)
+}
+
+type dockerArchiveLayer struct {
+	index  *archiveIndex
+	diffID v1.Hash
+	path   string
+}
+
+func (l *dockerArchiveLayer) DiffID() (v1.Hash, error) {
+	return l.diffID, nil
+}
+
+func (l *dockerArchiveLayer) Uncompressed() (io.ReadCloser, error) {
+	return l.index.open(l.path)
+}
+
+func (l *dockerArchiveLayer) MediaType() (types.MediaType, error) {
+	return types.DockerLayer, nil
+}
+
+// compressedDockerArchiveImage returns an image with compressed layers in a docker archive.
+// As docker archives do not store image manifests, the manifest is computed from the layers in the archive.
+func compressedDockerArchiveImage(index *archiveIndex, configPath string, rawConfig []byte, layerPaths []string, layerMediaType types.MediaType) (v1.Image, error) {
+	configDigest, configSize, err := v1.SHA256(bytes.NewReader(rawConfig))
+	if err != nil {
+		return nil, err
+	}
+	manifest := &v1.Manifest{
+		SchemaVersion: 2,
+		MediaType:     types.DockerManifestSchema2,
+		Config: v1.Descriptor{
+			MediaType: types.DockerConfigJSON,
+			Size:      configSize,
+			Digest:    configDigest,
+		},
+	}
+	for _, layerPath := range layerPaths {
+		desc, err := blobDescriptor(index, layerPath, layerMediaType)
+		if err != nil {
+			return nil, err
+		}
+		manifest.Layers = append(manifest.Layers, desc)
+	}
+	rawManifest, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+	paths := map[v1.Hash]string{configDigest: configPath}
+	for idx, desc := range manifest.Layers {
+		paths[desc.Digest] = layerPaths[idx]
+	}
+	return partial.CompressedToImage(&compressedArchiveImage{
+		index:       index,
+		manifest:    manifest,
+		mediaType:   types.DockerManifestSchema2,
+		rawManifest: rawManifest,
+		paths:       paths,
+	})
+}
+
+func blobDescriptor(index *archiveIndex, blobPath string, mediaType types.MediaType) (v1.Descriptor, error) {
+	rc, err := index.open(blobPath)
+	if err != nil {
+		return v1.Descriptor{}, err
+	}
+	defer rc.Close()
+	digest, size, err := v1.SHA256(rc)
+	if err != nil {
+		return v1.Descriptor{}, err
+	}
+	return v1.Descriptor{MediaType: mediaType, Size: size, Digest: digest}, nil
+}
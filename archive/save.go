@@ -0,0 +1,88 @@
+package archive
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+
+	"github.com/buildpacks/imgutil"
+)
+
+func (i *Image) Save(additionalNames ...string) error {
+	return i.SaveAsWithContext(i.ctx, i.Name(), additionalNames...)
+}
+
+func (i *Image) SaveWithContext(ctx context.Context, additionalNames ...string) error {
+	return i.SaveAsWithContext(ctx, i.Name(), additionalNames...)
+}
+
+// SaveAs ignores the image `Name()` method and saves the image according to name & additional names provided to this method
+func (i *Image) SaveAs(name string, additionalNames ...string) error {
+	return i.SaveAsWithContext(i.ctx, name, additionalNames...)
+}
+
+// SaveAsWithContext is like SaveAs, but stops saving to further paths once the context is done.
+// Each archive is written to a temporary file in the same directory and then renamed,
+// so that an existing archive is never left partially written.
+func (i *Image) SaveAsWithContext(ctx context.Context, name string, additionalNames ...string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := i.SetCreatedAtAndHistory(); err != nil {
+		return err
+	}
+	if err := i.CompressLayers(); err != nil {
+		return err
+	}
+
+	var diagnostics []imgutil.SaveDiagnostic
+	for _, path := range append([]string{name}, additionalNames...) {
+		if err := ctx.Err(); err != nil {
+			diagnostics = append(diagnostics, imgutil.SaveDiagnostic{ImageName: path, Cause: err})
+			continue
+		}
+		if err := writeArchive(path, i.CNBImageCore, i.archiveFormat); err != nil {
+			diagnostics = append(diagnostics, imgutil.SaveDiagnostic{ImageName: path, Cause: err})
+		}
+	}
+	if len(diagnostics) > 0 {
+		return imgutil.SaveError{Errors: diagnostics}
+	}
+	return nil
+}
+
+// SaveFile saves the image as a tar file in a temporary location and returns the path of the file.
+// Like Save, it sets the created at time and history of the image, and compresses its layers as requested.
+func (i *Image) SaveFile() (string, error) {
+	if err := i.SetCreatedAtAndHistory(); err != nil {
+		return "", err
+	}
+	if err := i.CompressLayers(); err != nil {
+		return "", err
+	}
+	return imgutil.SaveArchiveFile(i.CNBImageCore, "", i.archiveFormat)
+}
+
+func writeArchive(path string, image v1.Image, format imgutil.ArchiveFormat) (err error) {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	if err = imgutil.WriteArchive(f, image, "", format); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
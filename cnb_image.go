@@ -1,15 +1,19 @@
 package imgutil
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
-	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/types"
 )
 
 // CNBImageCore wraps a v1.Image and provides most of the methods necessary for the image to satisfy the Image interface.
@@ -21,16 +25,34 @@ type CNBImageCore struct {
 	// required
 	v1.Image // the working image
 	// optional
-	createdAt           time.Time
-	preferredMediaTypes MediaTypes
-	preserveHistory     bool
-	previousImage       v1.Image
-}
+	baseImageAnnotations bool
+	baseImageDigest      string
+	baseImageName        string
+	createdAt            time.Time
+	estargz              bool
+	layerCompression     LayerCompression
+	preferredMediaTypes  MediaTypes
+	preserveHistory      bool
+	previousImage        v1.Image
+}
+
+const (
+	BaseImageDigestAnnotation = "org.opencontainers.image.base.digest"
+	BaseImageNameAnnotation   = "org.opencontainers.image.base.name"
+)
 
 var _ v1.Image = &CNBImageCore{}
 
 // FIXME: mark deprecated methods as deprecated on the interface when other packages (remote, layout) expose a v1.Image
 
+func (i *CNBImageCore) Annotations() (map[string]string, error) {
+	manifest, err := getManifest(i.Image)
+	if err != nil {
+		return nil, err
+	}
+	return manifest.Annotations, nil
+}
+
 // TBD Deprecated: Architecture
 func (i *CNBImageCore) Architecture() (string, error) {
 	configFile, err := getConfigFile(i.Image)
@@ -85,33 +107,20 @@ func (i *CNBImageCore) GetLayer(diffID string) (io.ReadCloser, error) {
 	if err != nil {
 		return nil, err
 	}
-	configFile, err := i.ConfigFile()
+	idx, err := layerIndex(layerHash, i.Image)
 	if err != nil {
 		return nil, err
 	}
-	if !contains(configFile.RootFS.DiffIDs, layerHash) {
+	if idx < 0 {
 		return nil, ErrLayerNotFound{DiffID: layerHash.String()}
 	}
-	hash, err := v1.NewHash(diffID)
-	if err != nil {
-		return nil, err
-	}
-	layer, err := i.LayerByDiffID(hash)
+	layer, _, err := layerAt(idx, i.Image)
 	if err != nil {
 		return nil, err
 	}
 	return layer.Uncompressed()
 }
 
-func contains(diffIDs []v1.Hash, hash v1.Hash) bool {
-	for _, diffID := range diffIDs {
-		if diffID.String() == hash.String() {
-			return true
-		}
-	}
-	return false
-}
-
 // TBD Deprecated: History
 func (i *CNBImageCore) History() ([]v1.History, error) {
 	configFile, err := getConfigFile(i.Image)
@@ -202,21 +211,19 @@ func (i *CNBImageCore) WorkingDir() (string, error) {
 }
 
 func (i *CNBImageCore) AnnotateRefName(refName string) error {
-	manifest, err := getManifest(i.Image)
-	if err != nil {
-		return err
-	}
-	if manifest.Annotations == nil {
-		manifest.Annotations = make(map[string]string)
-	}
-	manifest.Annotations["org.opencontainers.image.ref.name"] = refName
-	mutated := mutate.Annotations(i.Image, manifest.Annotations)
-	image, ok := mutated.(v1.Image)
-	if !ok {
-		return fmt.Errorf("failed to add annotation")
-	}
-	i.Image = image
-	return nil
+	return i.SetAnnotation("org.opencontainers.image.ref.name", refName)
+}
+
+func (i *CNBImageCore) RemoveAnnotation(key string) error {
+	return i.MutateAnnotations(func(annotations map[string]string) {
+		delete(annotations, key)
+	})
+}
+
+func (i *CNBImageCore) SetAnnotation(key, val string) error {
+	return i.MutateAnnotations(func(annotations map[string]string) {
+		annotations[key] = val
+	})
 }
 
 // TBD Deprecated: SetArchitecture
@@ -319,7 +326,18 @@ func (i *CNBImageCore) AddLayerWithDiffID(path, _ string) error {
 }
 
 func (i *CNBImageCore) AddLayerWithDiffIDAndHistory(path, _ string, history v1.History) error {
-	layer, err := tarball.LayerFromFile(path)
+	opener := func() (io.ReadCloser, error) {
+		return os.Open(filepath.Clean(path))
+	}
+	var (
+		layer v1.Layer
+		err   error
+	)
+	if i.estargz {
+		layer, err = i.layerCompression.estargzLayerFromOpener(opener)
+	} else {
+		layer, err = i.layerCompression.layerFromOpener(opener)
+	}
 	if err != nil {
 		return err
 	}
@@ -345,7 +363,7 @@ func (i *CNBImageCore) AddLayerWithHistory(layer v1.Layer, history v1.History) e
 		mutate.Addendum{
 			Layer:     layer,
 			History:   history,
-			MediaType: i.preferredMediaTypes.LayerType(),
+			MediaType: layerTypeFor(layer, i.preferredMediaTypes.LayerType()),
 		},
 	)
 	return err
@@ -370,11 +388,11 @@ func (i *CNBImageCore) PreviousImageHasLayer(diffID string) (bool, error) {
 	if err != nil {
 		return false, fmt.Errorf("failed to get layer hash: %w", err)
 	}
-	prevConfigFile, err := getConfigFile(i.previousImage)
+	idx, err := layerIndex(layerHash, i.previousImage)
 	if err != nil {
-		return false, fmt.Errorf("failed to get previous image config: %w", err)
+		return false, fmt.Errorf("failed to get previous image layers: %w", err)
 	}
-	return contains(prevConfigFile.RootFS.DiffIDs, layerHash), nil
+	return idx >= 0, nil
 }
 
 func (i *CNBImageCore) Rebase(baseTopLayerDiffID string, withNewBase Image) error {
@@ -390,10 +408,62 @@ func (i *CNBImageCore) Rebase(baseTopLayerDiffID string, withNewBase Image) erro
 	if err != nil {
 		return err
 	}
-	return i.MutateConfigFile(func(c *v1.ConfigFile) {
+	if err = i.MutateConfigFile(func(c *v1.ConfigFile) {
 		c.Architecture = newBaseConfigFile.Architecture
 		c.OS = newBaseConfigFile.OS
 		c.OSVersion = newBaseConfigFile.OSVersion
+	}); err != nil {
+		return err
+	}
+
+	// update base image annotations if requested or previously set
+	annotations, err := i.Annotations()
+	if err != nil {
+		return err
+	}
+	_, hasName := annotations[BaseImageNameAnnotation]
+	_, hasDigest := annotations[BaseImageDigestAnnotation]
+	if !i.baseImageAnnotations && !hasName && !hasDigest {
+		return nil
+	}
+	baseName, baseDigest, err := baseImageReference(withNewBase, newBase)
+	if err != nil {
+		return err
+	}
+	i.baseImageName, i.baseImageDigest = baseName, baseDigest
+	return i.setBaseImageAnnotations()
+}
+
+// baseImageReferrer is satisfied by images that recorded the reference of the base image they were created from,
+// i.e., any image embedding a CNBImageCore.
+type baseImageReferrer interface {
+	baseImageReference() (string, string)
+}
+
+func (i *CNBImageCore) baseImageReference() (string, string) {
+	return i.baseImageName, i.baseImageDigest
+}
+
+// baseImageReference returns the name and digest to reference the provided image as a base image.
+// When the image was created from a base image that was found in its store, that base image is referenced,
+// otherwise the name and current digest of the provided image are used.
+func baseImageReference(image Image, underlyingImage v1.Image) (string, string, error) {
+	if referrer, ok := image.(baseImageReferrer); ok {
+		if baseName, baseDigest := referrer.baseImageReference(); baseName != "" && baseDigest != "" {
+			return baseName, baseDigest, nil
+		}
+	}
+	digest, err := underlyingImage.Digest()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get digest of base image: %w", err)
+	}
+	return image.Name(), digest.String(), nil
+}
+
+func (i *CNBImageCore) setBaseImageAnnotations() error {
+	return i.MutateAnnotations(func(annotations map[string]string) {
+		annotations[BaseImageNameAnnotation] = i.baseImageName
+		annotations[BaseImageDigestAnnotation] = i.baseImageDigest
 	})
 }
 
@@ -414,16 +484,18 @@ func (si *v1ImageFacade) Layers() ([]v1.Layer, error) {
 	if err != nil {
 		return nil, err
 	}
-	for i, l := range all {
-		d, err := l.DiffID()
-		if err != nil {
-			return nil, err
-		}
-		if d.String() == si.topLayerDiffID {
-			return all[0 : i+1], nil
-		}
+	topLayerHash, err := v1.NewHash(si.topLayerDiffID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get layer hash: %w", err)
+	}
+	idx, err := layerIndex(topLayerHash, si.Image)
+	if err != nil {
+		return nil, err
 	}
-	return nil, errors.New("could not find base layer in image")
+	if idx < 0 || idx >= len(all) {
+		return nil, errors.New("could not find base layer in image")
+	}
+	return all[0 : idx+1], nil
 }
 
 func (i *CNBImageCore) RemoveLabel(key string) error {
@@ -452,16 +524,14 @@ func getLayerIndex(forDiffID string, fromImage v1.Image) (int, error) {
 	if err != nil {
 		return -1, fmt.Errorf("failed to get layer hash: %w", err)
 	}
-	configFile, err := getConfigFile(fromImage)
+	idx, err := layerIndex(layerHash, fromImage)
 	if err != nil {
-		return -1, fmt.Errorf("failed to get config file: %w", err)
+		return -1, err
 	}
-	for idx, configHash := range configFile.RootFS.DiffIDs {
-		if layerHash.String() == configHash.String() {
-			return idx, nil
-		}
+	if idx < 0 {
+		return -1, fmt.Errorf("failed to find diffID %s in config file", layerHash.String())
 	}
-	return -1, fmt.Errorf("failed to find diffID %s in config file", layerHash.String())
+	return idx, nil
 }
 
 func getHistory(forIndex int, fromImage v1.Image) (v1.History, error) {
@@ -485,11 +555,11 @@ func (i *CNBImageCore) ReuseLayerWithHistory(diffID string, history v1.History)
 		return err
 	}
 
-	layerHash, err := v1.NewHash(diffID)
+	idx, err := getLayerIndex(diffID, i.previousImage)
 	if err != nil {
-		return fmt.Errorf("failed to get layer hash: %w", err)
+		return fmt.Errorf("failed to get layer by diffID: %w", err)
 	}
-	layer, err := i.previousImage.LayerByDiffID(layerHash)
+	layer, annotations, err := layerAt(idx, i.previousImage)
 	if err != nil {
 		return fmt.Errorf("failed to get layer by diffID: %w", err)
 	}
@@ -501,14 +571,67 @@ func (i *CNBImageCore) ReuseLayerWithHistory(diffID string, history v1.History)
 	i.Image, err = mutate.Append(
 		i.Image,
 		mutate.Addendum{
-			Layer:     layer,
-			History:   history,
-			MediaType: i.preferredMediaTypes.LayerType(),
+			Layer:       layer,
+			History:     history,
+			MediaType:   layerTypeFor(layer, i.preferredMediaTypes.LayerType()),
+			Annotations: annotations, // keep eStargz annotations
 		},
 	)
 	return err
 }
 
+// CompressLayers recompresses the layers of the working image, including the layers from the base and previous images,
+// that are not compressed as requested with WithLayerCompression, so that all the layers are written with the requested compression.
+// It does nothing if no compression was requested.
+// Layers without data (e.g., from sparse images, see LayerWithoutData) are preserved.
+func (i *CNBImageCore) CompressLayers() error {
+	if i.layerCompression.Algorithm == "" {
+		return nil
+	}
+	layers, err := i.Image.Layers()
+	if err != nil {
+		return err
+	}
+	var needed bool
+	for _, layer := range layers {
+		matches, err := i.layerCompression.matches(layer)
+		if err != nil {
+			return err
+		}
+		if !matches && hasData(layer) {
+			needed = true
+			break
+		}
+	}
+	if !needed {
+		return nil
+	}
+
+	annotations, err := i.Annotations() // not kept by EnsureMediaTypesAndLayers
+	if err != nil {
+		return err
+	}
+	mediaTypes := i.preferredMediaTypes
+	if mediaTypes == MissingTypes || mediaTypes == DefaultTypes {
+		manifestType, err := i.Image.MediaType()
+		if err != nil {
+			return err
+		}
+		mediaTypes = DockerTypes
+		if manifestType == types.OCIManifestSchema1 {
+			mediaTypes = OCITypes
+		}
+	}
+	if i.Image, _, err = EnsureMediaTypesAndLayers(i.Image, mediaTypes, RecompressLayers(i.layerCompression)); err != nil {
+		return err
+	}
+	return i.MutateAnnotations(func(a map[string]string) {
+		for k, v := range annotations {
+			a[k] = v
+		}
+	})
+}
+
 // helpers
 
 func (i *CNBImageCore) MutateConfigFile(withFunc func(c *v1.ConfigFile)) error {
@@ -522,6 +645,23 @@ func (i *CNBImageCore) MutateConfigFile(withFunc func(c *v1.ConfigFile)) error {
 	return err
 }
 
+func (i *CNBImageCore) MutateAnnotations(withFunc func(annotations map[string]string)) error {
+	manifest, err := getManifest(i.Image)
+	if err != nil {
+		return err
+	}
+	annotations := make(map[string]string)
+	for k, v := range manifest.Annotations {
+		annotations[k] = v
+	}
+	withFunc(annotations)
+	i.Image = &annotatedImage{
+		Image:       i.Image,
+		annotations: annotations,
+	}
+	return nil
+}
+
 func (i *CNBImageCore) SetCreatedAtAndHistory() error {
 	var err error
 	// set created at
@@ -573,3 +713,39 @@ func getManifest(image v1.Image) (*v1.Manifest, error) {
 	}
 	return manifest, nil
 }
+
+// annotatedImage replaces (rather than merges, as mutate.Annotations does) the annotations of the image manifest,
+// so that annotations can also be removed.
+type annotatedImage struct {
+	v1.Image
+	annotations map[string]string
+}
+
+func (a *annotatedImage) Manifest() (*v1.Manifest, error) {
+	manifest, err := getManifest(a.Image)
+	if err != nil {
+		return nil, err
+	}
+	manifest = manifest.DeepCopy()
+	manifest.Annotations = nil
+	if len(a.annotations) > 0 {
+		manifest.Annotations = a.annotations
+	}
+	return manifest, nil
+}
+
+func (a *annotatedImage) RawManifest() ([]byte, error) {
+	manifest, err := a.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(manifest)
+}
+
+func (a *annotatedImage) Digest() (v1.Hash, error) {
+	return partial.Digest(a)
+}
+
+func (a *annotatedImage) Size() (int64, error) {
+	return partial.Size(a)
+}
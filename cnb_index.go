@@ -0,0 +1,296 @@
+package imgutil
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/match"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// CNBIndex wraps a v1.ImageIndex and provides most of the methods necessary for the index to satisfy the ImageIndex interface.
+// Specific implementations will need to supply the methods that are omitted,
+// such as Identifier(), Found() and Save().
+type CNBIndex struct {
+	// required
+	index v1.ImageIndex // the working index
+}
+
+var _ v1.ImageIndex = &CNBIndex{}
+
+// v1.ImageIndex methods are forwarded to the working index,
+// as the field cannot be embedded without clashing with the ImageIndex method.
+
+func (i *CNBIndex) Digest() (v1.Hash, error) {
+	return i.index.Digest()
+}
+
+func (i *CNBIndex) Image(h v1.Hash) (v1.Image, error) {
+	return i.index.Image(h)
+}
+
+func (i *CNBIndex) ImageIndex(h v1.Hash) (v1.ImageIndex, error) {
+	return i.index.ImageIndex(h)
+}
+
+func (i *CNBIndex) IndexManifest() (*v1.IndexManifest, error) {
+	return i.index.IndexManifest()
+}
+
+func (i *CNBIndex) MediaType() (types.MediaType, error) {
+	return i.index.MediaType()
+}
+
+func (i *CNBIndex) RawManifest() ([]byte, error) {
+	return i.index.RawManifest()
+}
+
+func (i *CNBIndex) Size() (int64, error) {
+	return i.index.Size()
+}
+
+func (i *CNBIndex) Annotations() (map[string]string, error) {
+	manifest, err := getIndexManifest(i.index)
+	if err != nil {
+		return nil, err
+	}
+	return manifest.Annotations, nil
+}
+
+// ImageForPlatform returns the image that best matches the platform (see FindManifestForPlatform),
+// looking into nested indexes if needed.
+func (i *CNBIndex) ImageForPlatform(platform Platform) (v1.Image, error) {
+	return imageForPlatform(i.index, platform)
+}
+
+func imageForPlatform(index v1.ImageIndex, platform Platform) (v1.Image, error) {
+	manifest, err := getIndexManifest(index)
+	if err != nil {
+		return nil, err
+	}
+	desc, err := FindManifestForPlatform(manifest.Manifests, platform)
+	if err != nil {
+		return nil, err
+	}
+	if desc.MediaType.IsIndex() {
+		child, err := index.ImageIndex(desc.Digest)
+		if err != nil {
+			return nil, err
+		}
+		return imageForPlatform(child, platform)
+	}
+	return index.Image(desc.Digest)
+}
+
+func (i *CNBIndex) Platforms() ([]Platform, error) {
+	manifest, err := getIndexManifest(i.index)
+	if err != nil {
+		return nil, err
+	}
+	var platforms []Platform
+	for _, desc := range manifest.Manifests {
+		if desc.Platform == nil {
+			continue
+		}
+		platforms = append(platforms, PlatformFrom(*desc.Platform))
+	}
+	return platforms, nil
+}
+
+// UnderlyingIndex is used to expose a v1.ImageIndex from an imgutil.ImageIndex.
+func (i *CNBIndex) UnderlyingIndex() v1.ImageIndex {
+	return i.index
+}
+
+func (i *CNBIndex) RemoveAnnotation(key string) error {
+	return i.mutateAnnotations(func(annotations map[string]string) {
+		delete(annotations, key)
+	})
+}
+
+func (i *CNBIndex) SetAnnotation(key, val string) error {
+	return i.mutateAnnotations(func(annotations map[string]string) {
+		annotations[key] = val
+	})
+}
+
+// modifiers
+
+func (i *CNBIndex) AddManifest(image v1.Image) error {
+	platform, err := platformFrom(image)
+	if err != nil {
+		return err
+	}
+	existing, err := i.findManifest(platform)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("index already contains a manifest for platform %s", platform)
+	}
+	return i.appendManifest(image, platform)
+}
+
+func (i *CNBIndex) RemoveManifest(platform Platform) error {
+	existing, err := i.findManifest(platform)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return fmt.Errorf("failed to find manifest matching platform %s: %w", platform, ErrPlatformMismatch)
+	}
+	i.index = mutate.RemoveManifests(i.index, match.Digests(existing.Digest))
+	return nil
+}
+
+func (i *CNBIndex) ReplaceManifest(image v1.Image) error {
+	platform, err := platformFrom(image)
+	if err != nil {
+		return err
+	}
+	i.index = mutate.RemoveManifests(i.index, platformMatcher(platform))
+	return i.appendManifest(image, platform)
+}
+
+// helpers
+
+func (i *CNBIndex) appendManifest(image v1.Image, platform Platform) error {
+	i.index = mutate.AppendManifests(i.index, mutate.IndexAddendum{
+		Add: image,
+		Descriptor: v1.Descriptor{
+			Platform: &v1.Platform{
+				Architecture: platform.Architecture,
+				OS:           platform.OS,
+				OSVersion:    platform.OSVersion,
+				Variant:      platform.Variant,
+				OSFeatures:   platform.OSFeatureList(),
+			},
+		},
+	})
+	// force compute, so that errors surface when the manifest is added rather than when the index is saved
+	_, err := i.index.IndexManifest()
+	return err
+}
+
+func (i *CNBIndex) findManifest(platform Platform) (*v1.Descriptor, error) {
+	manifest, err := getIndexManifest(i.index)
+	if err != nil {
+		return nil, err
+	}
+	matches := platformMatcher(platform)
+	for _, desc := range manifest.Manifests {
+		if matches(desc) {
+			desc := desc
+			return &desc, nil
+		}
+	}
+	return nil, nil
+}
+
+func (i *CNBIndex) mutateAnnotations(withFunc func(annotations map[string]string)) error {
+	manifest, err := getIndexManifest(i.index)
+	if err != nil {
+		return err
+	}
+	annotations := make(map[string]string)
+	for k, v := range manifest.Annotations {
+		annotations[k] = v
+	}
+	withFunc(annotations)
+	i.index = &annotatedIndex{
+		base:        i.index,
+		annotations: annotations,
+	}
+	return nil
+}
+
+func platformFrom(image v1.Image) (Platform, error) {
+	configFile, err := getConfigFile(image)
+	if err != nil {
+		return Platform{}, err
+	}
+	return Platform{
+		Architecture: configFile.Architecture,
+		OS:           configFile.OS,
+		OSVersion:    configFile.OSVersion,
+		Variant:      configFile.Variant,
+		OSFeatures:   JoinOSFeatures(configFile.OSFeatures),
+	}, nil
+}
+
+// platformMatcher matches the manifests for exactly the platform, unlike Platform.Matches.
+func platformMatcher(platform Platform) match.Matcher {
+	return func(desc v1.Descriptor) bool {
+		if desc.Platform == nil {
+			return false
+		}
+		return desc.Platform.OS == platform.OS &&
+			desc.Platform.Architecture == platform.Architecture &&
+			desc.Platform.OSVersion == platform.OSVersion &&
+			desc.Platform.Variant == platform.Variant &&
+			JoinOSFeatures(desc.Platform.OSFeatures) == JoinOSFeatures(platform.OSFeatureList())
+	}
+}
+
+// annotatedIndex replaces (rather than merges, as mutate.Annotations does) the annotations of the index manifest,
+// so that annotations can also be removed.
+type annotatedIndex struct {
+	base        v1.ImageIndex
+	annotations map[string]string
+}
+
+func (a *annotatedIndex) Image(h v1.Hash) (v1.Image, error) {
+	return a.base.Image(h)
+}
+
+func (a *annotatedIndex) ImageIndex(h v1.Hash) (v1.ImageIndex, error) {
+	return a.base.ImageIndex(h)
+}
+
+func (a *annotatedIndex) MediaType() (types.MediaType, error) {
+	return a.base.MediaType()
+}
+
+func (a *annotatedIndex) IndexManifest() (*v1.IndexManifest, error) {
+	manifest, err := getIndexManifest(a.base)
+	if err != nil {
+		return nil, err
+	}
+	manifest = manifest.DeepCopy()
+	manifest.Annotations = nil
+	if len(a.annotations) > 0 {
+		manifest.Annotations = a.annotations
+	}
+	return manifest, nil
+}
+
+func (a *annotatedIndex) RawManifest() ([]byte, error) {
+	manifest, err := a.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(manifest)
+}
+
+func (a *annotatedIndex) Digest() (v1.Hash, error) {
+	return partial.Digest(a)
+}
+
+func (a *annotatedIndex) Size() (int64, error) {
+	return partial.Size(a)
+}
+
+func getIndexManifest(index v1.ImageIndex) (*v1.IndexManifest, error) {
+	manifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+	if manifest == nil {
+		return nil, errors.New("missing index manifest")
+	}
+	return manifest, nil
+}
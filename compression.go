@@ -0,0 +1,216 @@
+package imgutil
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/google/go-containerregistry/pkg/compression"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// LayerCompression describes how layer blobs are compressed.
+type LayerCompression struct {
+	// Algorithm is one of compression.GZip (the default), compression.ZStd or compression.None.
+	Algorithm compression.Compression
+	// Level is the compression level to use for gzip or zstd.
+	// If not provided, the fastest level (1) is used.
+	Level int
+}
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// layerFromOpener returns a layer for the tar provided by the opener, compressed according to c.
+// The tar may already be compressed, in which case it is only recompressed if the algorithm differs.
+func (c LayerCompression) layerFromOpener(opener tarball.Opener) (v1.Layer, error) {
+	ops := []tarball.LayerOption{tarball.WithCompressionLevel(c.level())}
+	switch c.Algorithm {
+	case "", compression.GZip:
+		return tarball.LayerFromOpener(opener, ops...)
+	case compression.ZStd, compression.None:
+		// tarball layers keep compressed input as is, regardless of the requested compression
+		uncompressed, err := uncompressedOpener(opener)
+		if err != nil {
+			return nil, err
+		}
+		if c.Algorithm == compression.None {
+			return newUncompressedLayer(uncompressed)
+		}
+		ops = append(ops, tarball.WithCompression(compression.ZStd), tarball.WithMediaType(types.OCILayerZStd))
+		return tarball.LayerFromOpener(uncompressed, ops...)
+	default:
+		return nil, fmt.Errorf("unsupported layer compression %q", c.Algorithm)
+	}
+}
+
+// fastestLevel is the fastest level for both gzip (gzip.BestSpeed) and zstd.
+const fastestLevel = 1
+
+func (c LayerCompression) level() int {
+	if c.Level == 0 {
+		return fastestLevel
+	}
+	return c.Level
+}
+
+// matches returns true if the layer is already compressed according to c (ignoring the compression level).
+func (c LayerCompression) matches(layer v1.Layer) (bool, error) {
+	mediaType, err := layer.MediaType()
+	if err != nil {
+		return false, err
+	}
+	switch c.Algorithm {
+	case "", compression.GZip:
+		return mediaType == types.OCILayer || mediaType == types.DockerLayer, nil
+	case compression.ZStd:
+		return mediaType == types.OCILayerZStd, nil
+	case compression.None:
+		return mediaType == types.OCIUncompressedLayer || mediaType == types.DockerUncompressedLayer, nil
+	default:
+		return false, nil
+	}
+}
+
+// RecompressLayers returns a "mutate layer" function for use with EnsureMediaTypesAndLayers
+// that compresses each layer according to the provided compression.
+// Layers that are already compressed with the requested algorithm, or that have no data
+// (e.g., from sparse images, see LayerWithoutData), are preserved.
+func RecompressLayers(withCompression LayerCompression) func(idx int, layer v1.Layer) (v1.Layer, error) {
+	return func(_ int, layer v1.Layer) (v1.Layer, error) {
+		matches, err := withCompression.matches(layer)
+		if err != nil {
+			return nil, err
+		}
+		if matches || !hasData(layer) {
+			return layer, nil
+		}
+		return withCompression.layerFromOpener(layer.Uncompressed)
+	}
+}
+
+// layerTypeFor returns the media type to use for the provided layer in an image with the requested layer type,
+// taking into account how the layer is compressed.
+func layerTypeFor(layer v1.Layer, requestedType types.MediaType) types.MediaType {
+	if requestedType == "" {
+		return ""
+	}
+	mediaType, err := layer.MediaType()
+	if err != nil {
+		return requestedType
+	}
+	switch mediaType {
+	case types.OCILayerZStd:
+		return types.OCILayerZStd // there is no docker equivalent
+	case types.OCIUncompressedLayer, types.DockerUncompressedLayer:
+		if requestedType == types.OCILayer {
+			return types.OCIUncompressedLayer
+		}
+		return types.DockerUncompressedLayer
+	default:
+		return requestedType
+	}
+}
+
+// LayerWithoutData is implemented by layers that stand in for layers whose data is not available
+// (e.g., the layers of sparse images that are not present in a layout), even if reading them does not return an error.
+type LayerWithoutData interface {
+	v1.Layer
+	// WithoutData returns true if the layer has no data.
+	WithoutData() bool
+}
+
+func hasData(layer v1.Layer) bool {
+	if layer, ok := layer.(LayerWithoutData); ok && layer.WithoutData() {
+		return false
+	}
+	rc, err := layer.Compressed()
+	if err != nil {
+		return false
+	}
+	rc.Close()
+	return true
+}
+
+func uncompressedOpener(opener tarball.Opener) (tarball.Opener, error) {
+	compressed, err := isCompressed(opener)
+	if err != nil {
+		return nil, err
+	}
+	if !compressed {
+		return opener, nil
+	}
+	layer, err := tarball.LayerFromOpener(opener)
+	if err != nil {
+		return nil, err
+	}
+	return layer.Uncompressed, nil
+}
+
+func isCompressed(opener tarball.Opener) (bool, error) {
+	rc, err := opener()
+	if err != nil {
+		return false, err
+	}
+	defer rc.Close()
+	magic := make([]byte, len(zstdMagic))
+	n, err := io.ReadFull(rc, magic)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return false, err
+	}
+	return bytes.HasPrefix(magic[:n], gzipMagic) || bytes.HasPrefix(magic[:n], zstdMagic), nil
+}
+
+// uncompressedLayer is a layer whose blob is the uncompressed tar,
+// as tarball layers do not support writing uncompressed blobs.
+type uncompressedLayer struct {
+	opener tarball.Opener
+	diffID v1.Hash
+	size   int64
+}
+
+func newUncompressedLayer(opener tarball.Opener) (v1.Layer, error) {
+	rc, err := opener()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	diffID, size, err := v1.SHA256(rc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute diffID: %w", err)
+	}
+	return &uncompressedLayer{
+		opener: opener,
+		diffID: diffID,
+		size:   size,
+	}, nil
+}
+
+func (l *uncompressedLayer) Digest() (v1.Hash, error) {
+	return l.diffID, nil
+}
+
+func (l *uncompressedLayer) DiffID() (v1.Hash, error) {
+	return l.diffID, nil
+}
+
+func (l *uncompressedLayer) Compressed() (io.ReadCloser, error) {
+	return l.opener()
+}
+
+func (l *uncompressedLayer) Uncompressed() (io.ReadCloser, error) {
+	return l.opener()
+}
+
+func (l *uncompressedLayer) Size() (int64, error) {
+	return l.size, nil
+}
+
+func (l *uncompressedLayer) MediaType() (types.MediaType, error) {
+	return types.DockerUncompressedLayer, nil
+}
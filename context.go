@@ -0,0 +1,52 @@
+package imgutil
+
+import (
+	"context"
+	"io"
+)
+
+// ContextOrBackground returns the provided context (e.g., from WithContext), or context.Background() if it is nil.
+func ContextOrBackground(ctx context.Context) context.Context {
+	if ctx != nil {
+		return ctx
+	}
+	return context.Background()
+}
+
+// GetLayerWithContext is like GetLayer, but returns an error if the context is done
+// and returns a reader that fails once the context is done.
+func (i *CNBImageCore) GetLayerWithContext(ctx context.Context, diffID string) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	rc, err := i.GetLayer(diffID)
+	if err != nil {
+		return nil, err
+	}
+	return NewContextReadCloser(ctx, rc), nil
+}
+
+// RebaseWithContext is like Rebase, but returns an error if the context is done.
+func (i *CNBImageCore) RebaseWithContext(ctx context.Context, baseTopLayerDiffID string, withNewBase Image) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return i.Rebase(baseTopLayerDiffID, withNewBase)
+}
+
+// NewContextReadCloser returns an io.ReadCloser that returns the context error from Read once the context is done.
+func NewContextReadCloser(ctx context.Context, rc io.ReadCloser) io.ReadCloser {
+	return &contextReadCloser{ReadCloser: rc, ctx: ctx}
+}
+
+type contextReadCloser struct {
+	io.ReadCloser
+	ctx context.Context
+}
+
+func (r *contextReadCloser) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.ReadCloser.Read(p)
+}
@@ -0,0 +1,169 @@
+package imgutil
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/containerd/stargz-snapshotter/estargz"
+	"github.com/google/go-containerregistry/pkg/compression"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+)
+
+// estargzLayerFromOpener returns an eStargz layer for the tar provided by the opener, annotated with its TOC digest.
+// The DiffID of the layer is the DiffID of the tar, so that the layer can be found by it (e.g., when reusing layers
+// or rebasing), rather than the DiffID of the eStargz tar.
+func (c LayerCompression) estargzLayerFromOpener(opener tarball.Opener) (v1.Layer, error) {
+	if c.Algorithm != "" && c.Algorithm != compression.GZip {
+		return nil, fmt.Errorf("eStargz layers must be compressed with gzip; got %q", c.Algorithm)
+	}
+	uncompressed, err := uncompressedOpener(opener)
+	if err != nil {
+		return nil, err
+	}
+	rc, err := uncompressed()
+	if err != nil {
+		return nil, err
+	}
+	originalDiffID, _, err := v1.SHA256(rc)
+	rc.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute diffID: %w", err)
+	}
+
+	// the blob is built again whenever the layer is read, as building it is deterministic
+	compressed := func() (io.ReadCloser, error) {
+		return buildEstargz(uncompressed, c.level())
+	}
+	blob, err := buildEstargz(uncompressed, c.level())
+	if err != nil {
+		return nil, err
+	}
+	tocDigest := blob.TOCDigest().String()
+	if err = blob.Close(); err != nil {
+		return nil, err
+	}
+	layer, err := tarball.LayerFromOpener(compressed)
+	if err != nil {
+		return nil, err
+	}
+	return &annotatedLayer{
+		Layer: &estargzLayer{
+			Layer:        layer,
+			diffID:       originalDiffID,
+			uncompressed: uncompressed,
+		},
+		annotations: map[string]string{estargz.TOCJSONDigestAnnotation: tocDigest},
+	}, nil
+}
+
+// estargzLayer is an eStargz layer with the DiffID and the uncompressed contents of the tar it was created from.
+type estargzLayer struct {
+	v1.Layer
+	diffID       v1.Hash
+	uncompressed tarball.Opener
+}
+
+func (l *estargzLayer) DiffID() (v1.Hash, error) {
+	return l.diffID, nil
+}
+
+func (l *estargzLayer) Uncompressed() (io.ReadCloser, error) {
+	return l.uncompressed()
+}
+
+// buildEstargz returns the eStargz blob for the tar provided by the opener.
+// The tar is copied to a temporary file, which is removed when the blob is closed.
+func buildEstargz(uncompressed tarball.Opener, level int) (*estargzBlob, error) {
+	rc, err := uncompressed()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	tarFile, err := os.CreateTemp("", "imgutil-estargz")
+	if err != nil {
+		return nil, err
+	}
+	size, err := io.Copy(tarFile, rc)
+	if err != nil {
+		tarFile.Close()
+		os.Remove(tarFile.Name())
+		return nil, err
+	}
+	blob, err := estargz.Build(io.NewSectionReader(tarFile, 0, size), estargz.WithCompressionLevel(level))
+	if err != nil {
+		tarFile.Close()
+		os.Remove(tarFile.Name())
+		return nil, fmt.Errorf("failed to build eStargz layer: %w", err)
+	}
+	return &estargzBlob{Blob: blob, tarFile: tarFile}, nil
+}
+
+type estargzBlob struct {
+	*estargz.Blob
+	tarFile *os.File
+}
+
+func (b *estargzBlob) Close() error {
+	err := b.Blob.Close()
+	b.tarFile.Close()
+	os.Remove(b.tarFile.Name())
+	return err
+}
+
+// annotatedLayer adds annotations to the descriptor of the layer,
+// so that the annotations are kept when the layer is appended to an image (including when rebasing).
+type annotatedLayer struct {
+	v1.Layer
+	annotations map[string]string
+}
+
+func (l *annotatedLayer) Descriptor() (*v1.Descriptor, error) {
+	desc, err := partial.Descriptor(l.Layer)
+	if err != nil {
+		return nil, err
+	}
+	annotations := make(map[string]string)
+	for k, v := range desc.Annotations {
+		annotations[k] = v
+	}
+	for k, v := range l.annotations {
+		annotations[k] = v
+	}
+	desc.Annotations = annotations
+	return desc, nil
+}
+
+// layerIndex returns the index of the layer with the provided DiffID in the image.
+// If no layer is found, it returns -1.
+func layerIndex(diffID v1.Hash, image v1.Image) (int, error) {
+	configFile, err := getConfigFile(image)
+	if err != nil {
+		return -1, fmt.Errorf("failed to get config file: %w", err)
+	}
+	for idx, configHash := range configFile.RootFS.DiffIDs {
+		if diffID.String() == configHash.String() {
+			return idx, nil
+		}
+	}
+	return -1, nil
+}
+
+// layerAt returns the layer at the provided index in the image, along with the annotations of its manifest descriptor.
+func layerAt(idx int, image v1.Image) (v1.Layer, map[string]string, error) {
+	layers, err := image.Layers()
+	if err != nil {
+		return nil, nil, err
+	}
+	manifest, err := getManifest(image)
+	if err != nil {
+		return nil, nil, err
+	}
+	if idx < 0 || idx >= len(layers) || len(layers) != len(manifest.Layers) {
+		return nil, nil, errors.New("layer index out of range")
+	}
+	return layers[idx], manifest.Layers[idx].Annotations, nil
+}
@@ -2,6 +2,7 @@ package fakes
 
 import (
 	"archive/tar"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
@@ -18,7 +19,7 @@ import (
 	"github.com/buildpacks/imgutil"
 )
 
-var _ imgutil.Image = &Image{}
+var _ imgutil.ImageWithContext = &Image{}
 
 func NewImage(name, topLayerSha string, identifier imgutil.Identifier) *Image {
 	return &Image{
@@ -35,6 +36,7 @@ func NewImage(name, topLayerSha string, identifier imgutil.Identifier) *Image {
 		os:               "linux",
 		osVersion:        "",
 		architecture:     "amd64",
+		annotations:      map[string]string{},
 		savedAnnotations: map[string]string{},
 	}
 }
@@ -63,10 +65,18 @@ type Image struct {
 	workingDir       string
 	savedNames       map[string]bool
 	manifestSize     int64
-	refName          string
+	annotations      map[string]string
 	savedAnnotations map[string]string
 }
 
+func (i *Image) Annotations() (map[string]string, error) {
+	copiedAnnotations := make(map[string]string)
+	for k, v := range i.annotations {
+		copiedAnnotations[k] = v
+	}
+	return copiedAnnotations, nil
+}
+
 func (i *Image) CreatedAt() (time.Time, error) {
 	return i.createdAt, nil
 }
@@ -128,6 +138,23 @@ func (i *Image) Rebase(_ string, newBase imgutil.Image) error {
 	return nil
 }
 
+func (i *Image) RebaseWithContext(ctx context.Context, baseTopLayerDiffID string, newBase imgutil.Image) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return i.Rebase(baseTopLayerDiffID, newBase)
+}
+
+func (i *Image) SetAnnotation(k string, v string) error {
+	i.annotations[k] = v
+	return nil
+}
+
+func (i *Image) RemoveAnnotation(key string) error {
+	delete(i.annotations, key)
+	return nil
+}
+
 func (i *Image) SetLabel(k string, v string) error {
 	if i.labels == nil {
 		i.labels = map[string]string{}
@@ -253,6 +280,17 @@ func (i *Image) GetLayer(sha string) (io.ReadCloser, error) {
 	return os.Open(filepath.Clean(path))
 }
 
+func (i *Image) GetLayerWithContext(ctx context.Context, sha string) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	rc, err := i.GetLayer(sha)
+	if err != nil {
+		return nil, err
+	}
+	return imgutil.NewContextReadCloser(ctx, rc), nil
+}
+
 func (i *Image) ReuseLayer(sha string) error {
 	prevLayer, ok := i.prevLayersMap[sha]
 	if !ok {
@@ -275,6 +313,17 @@ func (i *Image) Save(additionalNames ...string) error {
 	return i.SaveAs(i.Name(), additionalNames...)
 }
 
+func (i *Image) SaveWithContext(ctx context.Context, additionalNames ...string) error {
+	return i.SaveAsWithContext(ctx, i.Name(), additionalNames...)
+}
+
+func (i *Image) SaveAsWithContext(ctx context.Context, name string, additionalNames ...string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return i.SaveAs(name, additionalNames...)
+}
+
 func (i *Image) SaveAs(name string, additionalNames ...string) error {
 	var err error
 	i.layerDir, err = os.MkdirTemp("", "fake-image")
@@ -294,8 +343,8 @@ func (i *Image) SaveAs(name string, additionalNames ...string) error {
 	}
 
 	allNames := append([]string{name}, additionalNames...)
-	if i.refName != "" {
-		i.savedAnnotations["org.opencontainers.image.ref.name"] = i.refName
+	for k, v := range i.annotations {
+		i.savedAnnotations[k] = v
 	}
 
 	var errs []imgutil.SaveDiagnostic
@@ -344,21 +393,31 @@ func (i *Image) Delete() error {
 	return nil
 }
 
+func (i *Image) DeleteWithContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return i.Delete()
+}
+
 func (i *Image) Found() bool {
 	return !i.deleted
 }
 
+func (i *Image) FoundWithContext(_ context.Context) bool {
+	return i.Found()
+}
+
 func (i *Image) Valid() bool {
 	return !i.deleted
 }
 
 func (i *Image) AnnotateRefName(refName string) error {
-	i.refName = refName
-	return nil
+	return i.SetAnnotation("org.opencontainers.image.ref.name", refName)
 }
 
 func (i *Image) GetAnnotateRefName() (string, error) {
-	return i.refName, nil
+	return i.annotations["org.opencontainers.image.ref.name"], nil
 }
 
 // test methods
@@ -147,6 +147,24 @@ Layers
 			h.AssertEq(t, annotations["org.opencontainers.image.ref.name"], refName)
 		})
 	})
+
+	when("#SetAnnotation", func() {
+		var repoName = newRepoName()
+
+		it("saves the set annotations", func() {
+			image := fakes.NewImage(repoName, "", nil)
+			h.AssertNil(t, image.SetAnnotation("org.opencontainers.image.source", "some-source"))
+			h.AssertNil(t, image.SetAnnotation("org.opencontainers.image.revision", "some-revision"))
+			h.AssertNil(t, image.RemoveAnnotation("org.opencontainers.image.revision"))
+
+			_ = image.Save()
+
+			annotations, err := image.Annotations()
+			h.AssertNil(t, err)
+			h.AssertEq(t, annotations, map[string]string{"org.opencontainers.image.source": "some-source"})
+			h.AssertEq(t, image.SavedAnnotations(), annotations)
+		})
+	})
 }
 
 func createLayerTar(contents map[string]string) (string, error) {
@@ -1,6 +1,8 @@
 package imgutil
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"strings"
@@ -12,6 +14,8 @@ import (
 type Image interface {
 	// getters
 
+	// Annotations returns the annotations set on the image manifest.
+	Annotations() (map[string]string, error)
 	Architecture() (string, error)
 	CreatedAt() (time.Time, error)
 	Entrypoint() ([]string, error)
@@ -45,7 +49,12 @@ type Image interface {
 
 	// AnnotateRefName set a value for the `org.opencontainers.image.ref.name` annotation
 	AnnotateRefName(refName string) error
+	// RemoveAnnotation removes the annotation with the given key from the image manifest.
+	RemoveAnnotation(key string) error
 	Rename(name string)
+	// SetAnnotation sets an annotation on the image manifest.
+	// Annotations are not persisted by the `local` implementation, as the daemon does not store image manifests.
+	SetAnnotation(key, value string) error
 	SetArchitecture(string) error
 	SetCmd(...string) error
 	SetEntrypoint(...string) error
@@ -72,10 +81,30 @@ type Image interface {
 	Save(additionalNames ...string) error
 	// SaveAs ignores the image `Name()` method and saves the image according to name & additional names provided to this method
 	SaveAs(name string, additionalNames ...string) error
-	// SaveFile saves the image as a docker archive and provides the filesystem location
+	// SaveFile saves the image as a docker archive (or in the format chosen with WithArchiveFormat) and provides the filesystem location
 	SaveFile() (string, error)
 }
 
+// ImageWithContext is implemented by images that support cancellation and deadlines
+// for operations that might make network or daemon calls.
+type ImageWithContext interface {
+	Image
+
+	// DeleteWithContext is like Delete, but uses the provided context.
+	DeleteWithContext(ctx context.Context) error
+	// FoundWithContext is like Found, but uses the provided context.
+	FoundWithContext(ctx context.Context) bool
+	// GetLayerWithContext is like GetLayer, but uses the provided context.
+	// Reading from the returned reader fails once the context is done.
+	GetLayerWithContext(ctx context.Context, diffID string) (io.ReadCloser, error)
+	// RebaseWithContext is like Rebase, but uses the provided context.
+	RebaseWithContext(ctx context.Context, baseTopLayerDiffID string, withNewBase Image) error
+	// SaveWithContext is like Save, but uses the provided context.
+	SaveWithContext(ctx context.Context, additionalNames ...string) error
+	// SaveAsWithContext is like SaveAs, but uses the provided context.
+	SaveAsWithContext(ctx context.Context, name string, additionalNames ...string) error
+}
+
 type Identifier fmt.Stringer
 
 // Platform represents the target arch/os/os_version for an image construction and querying.
@@ -83,6 +112,11 @@ type Platform struct {
 	Architecture string
 	OS           string
 	OSVersion    string
+	// Variant is the variant of the architecture (e.g., "v7" for "arm").
+	Variant string
+	// OSFeatures are the features required from the OS (e.g., "win32k" for "windows"), separated by commas.
+	// It is a string rather than a slice so that platforms can be compared; see JoinOSFeatures.
+	OSFeatures string
 }
 
 type SaveDiagnostic struct {
@@ -102,6 +136,31 @@ func (e SaveError) Error() string {
 	return fmt.Sprintf("failed to write image to the following tags: %s", strings.Join(errors, ","))
 }
 
+// Unwrap returns the causes, so that errors.Is and errors.As match any of them.
+func (e SaveError) Unwrap() []error {
+	var causes []error
+	for _, d := range e.Errors {
+		causes = append(causes, d.Cause)
+	}
+	return causes
+}
+
+// Errors wrapped by the implementations, so that callers can check for them with errors.Is.
+var (
+	// ErrImageNotFound is returned when an image does not exist in its store.
+	ErrImageNotFound = errors.New("image not found")
+	// ErrUnauthorized is returned when the store (e.g., a registry) denies access to an image.
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrPlatformMismatch is returned when an image index has no image for the requested platform.
+	ErrPlatformMismatch = errors.New("no image for the requested platform")
+	// ErrLayerMissing is returned when a layer of an image is not present in its store
+	// (e.g., a layer omitted from a sparse layout, or a blob missing from a registry).
+	ErrLayerMissing = errors.New("layer missing")
+	// ErrDaemonUnavailable is returned when the daemon cannot be reached.
+	ErrDaemonUnavailable = errors.New("daemon unavailable")
+)
+
+// ErrLayerNotFound is returned when an image does not have a layer with the diff ID.
 type ErrLayerNotFound struct {
 	DiffID string
 }
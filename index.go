@@ -0,0 +1,47 @@
+package imgutil
+
+import (
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// ImageIndex represents a manifest list (Docker) or an image index (OCI)
+// that references images built for different platforms.
+type ImageIndex interface {
+	// getters
+
+	// Annotations returns the annotations set on the index manifest.
+	Annotations() (map[string]string, error)
+	// Found reports if the index exists in the image store with `Name()`.
+	Found() bool
+	Identifier() (Identifier, error)
+	// ImageForPlatform returns the image referenced by the index for the given platform.
+	ImageForPlatform(platform Platform) (v1.Image, error)
+	// Kind exposes the type of index that backs the imgutil.ImageIndex implementation.
+	// It could be `remote` or `layout`.
+	Kind() string
+	Name() string
+	// Platforms returns the platforms of every image referenced by the index.
+	Platforms() ([]Platform, error)
+	UnderlyingIndex() v1.ImageIndex
+
+	// setters
+
+	RemoveAnnotation(key string) error
+	Rename(name string)
+	SetAnnotation(key, value string) error
+
+	// modifiers
+
+	// AddManifest adds the image to the index, using the platform from the image config.
+	// It returns an error if the index already references an image for that platform.
+	AddManifest(image v1.Image) error
+	Delete() error
+	// RemoveManifest removes the image for the given platform from the index.
+	RemoveManifest(platform Platform) error
+	// ReplaceManifest adds the image to the index, replacing any image already referenced for the same platform.
+	ReplaceManifest(image v1.Image) error
+	// Save saves the index as `Name()` and any additional names provided to this method.
+	Save(additionalNames ...string) error
+	// SaveAs ignores the index `Name()` method and saves the index according to name & additional names provided to this method
+	SaveAs(name string, additionalNames ...string) error
+}
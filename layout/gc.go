@@ -0,0 +1,123 @@
+package layout
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// GCReport describes the blobs removed from a layout by Path.GC, or that would be removed, for Path.GCDryRun.
+type GCReport struct {
+	// Removed are the digests of the blobs (e.g., "sha256:<hex>"), sorted.
+	Removed []string
+	// ReclaimedBytes is the total size of the blobs.
+	ReclaimedBytes int64
+}
+
+// GC removes the blobs of the layout that are not reachable from its index,
+// walking the nested indexes, the image manifests and their configs and layers.
+// Layers that are not present in the layout (e.g., for sparse images) are not needed,
+// but missing manifests and indexes make it fail, as the blobs they reference cannot be known.
+// It must not be called while images are saved to the layout.
+func (l Path) GC() (GCReport, error) {
+	return l.gc(false)
+}
+
+// GCDryRun reports the blobs that GC would remove, without removing them.
+func (l Path) GCDryRun() (GCReport, error) {
+	return l.gc(true)
+}
+
+func (l Path) gc(dryRun bool) (GCReport, error) {
+	index, err := l.ImageIndex()
+	if err != nil {
+		return GCReport{}, fmt.Errorf("failed to load index: %w", err)
+	}
+	manifest, err := index.IndexManifest()
+	if err != nil {
+		return GCReport{}, fmt.Errorf("failed to load index: %w", err)
+	}
+	reachable := make(map[string]bool)
+	if err = l.markReachable(manifest.Manifests, reachable); err != nil {
+		return GCReport{}, err
+	}
+
+	var report GCReport
+	algorithms, err := os.ReadDir(l.append("blobs"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return report, nil
+		}
+		return GCReport{}, err
+	}
+	for _, algorithm := range algorithms {
+		if !algorithm.IsDir() {
+			continue
+		}
+		blobs, err := os.ReadDir(l.append("blobs", algorithm.Name()))
+		if err != nil {
+			return GCReport{}, err
+		}
+		for _, blob := range blobs {
+			digest := algorithm.Name() + ":" + blob.Name()
+			if reachable[digest] || blob.IsDir() {
+				continue
+			}
+			info, err := blob.Info()
+			if err != nil {
+				return GCReport{}, err
+			}
+			if !dryRun {
+				if err = os.Remove(filepath.Join(l.append("blobs", algorithm.Name()), blob.Name())); err != nil {
+					return GCReport{}, fmt.Errorf("failed to remove blob %s: %w", digest, err)
+				}
+			}
+			report.Removed = append(report.Removed, digest)
+			report.ReclaimedBytes += info.Size()
+		}
+	}
+	sort.Strings(report.Removed)
+	return report, nil
+}
+
+// markReachable adds the digests of the provided descriptors, and of the blobs they reference, to reachable.
+func (l Path) markReachable(descs []v1.Descriptor, reachable map[string]bool) error {
+	for _, desc := range descs {
+		if reachable[desc.Digest.String()] {
+			continue
+		}
+		reachable[desc.Digest.String()] = true
+		switch {
+		case desc.MediaType.IsIndex():
+			rawIndex, err := l.Bytes(desc.Digest)
+			if err != nil {
+				return fmt.Errorf("failed to read index %s: %w", desc.Digest, err)
+			}
+			var index v1.IndexManifest
+			if err = json.Unmarshal(rawIndex, &index); err != nil {
+				return fmt.Errorf("failed to parse index %s: %w", desc.Digest, err)
+			}
+			if err = l.markReachable(index.Manifests, reachable); err != nil {
+				return err
+			}
+		case desc.MediaType.IsImage():
+			rawManifest, err := l.Bytes(desc.Digest)
+			if err != nil {
+				return fmt.Errorf("failed to read manifest %s: %w", desc.Digest, err)
+			}
+			var manifest v1.Manifest
+			if err = json.Unmarshal(rawManifest, &manifest); err != nil {
+				return fmt.Errorf("failed to parse manifest %s: %w", desc.Digest, err)
+			}
+			reachable[manifest.Config.Digest.String()] = true
+			for _, layer := range manifest.Layers {
+				reachable[layer.Digest.String()] = true
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package layout_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil/layout"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestLayoutGC(t *testing.T) {
+	spec.Run(t, "GC", testGC, spec.Sequential(), spec.Report(report.Terminal{}))
+}
+
+func testGC(t *testing.T, when spec.G, it spec.S) {
+	var (
+		tmpDir    string
+		storePath string
+		layerPath string
+		err       error
+	)
+
+	it.Before(func() {
+		tmpDir, err = os.MkdirTemp("", "layout-gc")
+		h.AssertNil(t, err)
+		storePath = filepath.Join(tmpDir, "store")
+		_, err = layout.Write(storePath, empty.Index)
+		h.AssertNil(t, err)
+		layerPath, err = h.CreateSingleFileLayerTar("/some-file.txt", "some-content", "linux")
+		h.AssertNil(t, err)
+	})
+
+	it.After(func() {
+		os.RemoveAll(tmpDir)
+		os.Remove(layerPath)
+	})
+
+	// saveImage saves an image with a label and a layer to the store under the ref name
+	saveImage := func(refName, labelValue string) *layout.Image {
+		img, err := layout.NewImage(storePath + ":" + refName)
+		h.AssertNil(t, err)
+		h.AssertNil(t, img.SetLabel("some-label", labelValue))
+		h.AssertNil(t, img.AddLayer(layerPath))
+		h.AssertNil(t, img.Save())
+		return img
+	}
+
+	blobCount := func() int {
+		blobs, err := os.ReadDir(filepath.Join(storePath, "blobs", "sha256"))
+		h.AssertNil(t, err)
+		return len(blobs)
+	}
+
+	storeLayout := func() layout.Path {
+		path, err := layout.FromPath(storePath)
+		h.AssertNil(t, err)
+		return path
+	}
+
+	// blobSizes returns the size of the blobs of the manifest and config of the image
+	blobSizes := func(img *layout.Image) int64 {
+		digest, err := img.Digest()
+		h.AssertNil(t, err)
+		configName, err := img.ConfigName()
+		h.AssertNil(t, err)
+		var size int64
+		for _, hex := range []string{digest.Hex, configName.Hex} {
+			info, err := os.Stat(filepath.Join(storePath, "blobs", "sha256", hex))
+			h.AssertNil(t, err)
+			size += info.Size()
+		}
+		return size
+	}
+
+	when("#GC", func() {
+		it("removes the blobs of replaced images", func() {
+			replaced := saveImage("some-image", "some-value")
+			replacedSize := blobSizes(replaced)
+			saveImage("other-image", "other-value")
+			saveImage("some-image", "new-value")
+			h.AssertEq(t, blobCount(), 7)
+
+			gcReport, err := storeLayout().GC()
+			h.AssertNil(t, err)
+
+			digest, err := replaced.Digest()
+			h.AssertNil(t, err)
+			configName, err := replaced.ConfigName()
+			h.AssertNil(t, err)
+			h.AssertEq(t, len(gcReport.Removed), 2)
+			for _, removed := range []string{digest.String(), configName.String()} {
+				h.AssertContains(t, gcReport.Removed, removed)
+			}
+			h.AssertEq(t, gcReport.ReclaimedBytes, replacedSize)
+			h.AssertEq(t, blobCount(), 5) // the shared layer, and the config and manifest of each image
+
+			for _, refName := range []string{"some-image", "other-image"} {
+				img, err := layout.NewImage(storePath+":"+refName, layout.FromBaseImagePath(storePath+":"+refName))
+				h.AssertNil(t, err)
+				h.AssertEq(t, img.Valid(), true)
+			}
+		})
+
+		it("removes the blobs of deleted images", func() {
+			saveImage("some-image", "some-value")
+			deleted := saveImage("other-image", "other-value")
+			deletedSize := blobSizes(deleted)
+			h.AssertNil(t, deleted.Delete())
+
+			gcReport, err := storeLayout().GC()
+			h.AssertNil(t, err)
+
+			h.AssertEq(t, len(gcReport.Removed), 2)
+			h.AssertEq(t, gcReport.ReclaimedBytes, deletedSize)
+			h.AssertEq(t, blobCount(), 3)
+		})
+
+		it("keeps the blobs referenced by nested indexes", func() {
+			saveImage("some-image", "some-value")
+			index, err := random.Index(1024, 1, 2)
+			h.AssertNil(t, err)
+			path := storeLayout()
+			h.AssertNil(t, path.AppendIndex(index))
+			before := blobCount()
+
+			gcReport, err := path.GC()
+			h.AssertNil(t, err)
+
+			h.AssertEq(t, len(gcReport.Removed), 0)
+			h.AssertEq(t, blobCount(), before)
+		})
+
+		it("keeps the blobs of sparse images", func() {
+			img := saveImage("some-image", "some-value")
+			manifest, err := img.UnderlyingImage().Manifest()
+			h.AssertNil(t, err)
+			h.AssertNil(t, os.Remove(filepath.Join(storePath, "blobs", "sha256", manifest.Layers[0].Digest.Hex)))
+
+			gcReport, err := storeLayout().GC()
+			h.AssertNil(t, err)
+
+			h.AssertEq(t, len(gcReport.Removed), 0)
+			h.AssertEq(t, blobCount(), 2)
+		})
+
+		it("fails if a manifest is missing", func() {
+			img := saveImage("some-image", "some-value")
+			h.AssertNil(t, os.WriteFile(filepath.Join(storePath, "blobs", "sha256", "some-unreferenced-blob"), []byte("some-content"), 0600))
+			digest, err := img.Digest()
+			h.AssertNil(t, err)
+			h.AssertNil(t, os.Remove(filepath.Join(storePath, "blobs", "sha256", digest.Hex)))
+
+			_, err = storeLayout().GC()
+			h.AssertError(t, err, "failed to read manifest")
+			h.AssertEq(t, blobCount(), 3)
+		})
+	})
+
+	when("#GCDryRun", func() {
+		it("reports the blobs without removing them", func() {
+			saveImage("some-image", "some-value")
+			saveImage("some-image", "new-value")
+			h.AssertNil(t, os.WriteFile(filepath.Join(storePath, "blobs", "sha256", "some-unreferenced-blob"), []byte("some-content"), 0600))
+
+			gcReport, err := storeLayout().GCDryRun()
+			h.AssertNil(t, err)
+
+			h.AssertEq(t, len(gcReport.Removed), 3)
+			h.AssertContains(t, gcReport.Removed, "sha256:some-unreferenced-blob")
+			h.AssertEq(t, gcReport.ReclaimedBytes > int64(len("some-content")), true)
+			h.AssertEq(t, blobCount(), 6)
+		})
+	})
+}
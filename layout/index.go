@@ -0,0 +1,118 @@
+package layout
+
+import (
+	"fmt"
+	"os"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/pkg/errors"
+
+	"github.com/buildpacks/imgutil"
+)
+
+var _ imgutil.ImageIndex = (*Index)(nil)
+
+// Index wraps an imgutil.CNBIndex and implements the methods needed to complete the imgutil.ImageIndex interface.
+// The index is saved as the `index.json` of the OCI layout at its path.
+type Index struct {
+	*imgutil.CNBIndex
+	repoPath string
+}
+
+// NewIndex returns a new index that can be modified and saved on disk in OCI layout format.
+func NewIndex(path string, ops ...imgutil.ImageOption) (*Index, error) {
+	options := &imgutil.ImageOptions{}
+	for _, op := range ops {
+		op(options)
+	}
+
+	var err error
+	if options.BaseIndex == nil && options.BaseIndexRepoName != "" { // options.BaseIndex supersedes options.BaseIndexRepoName
+		options.BaseIndex, err = newIndexFromPath(options.BaseIndexRepoName)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cnbIndex, err := imgutil.NewCNBIndex(*options)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Index{
+		CNBIndex: cnbIndex,
+		repoPath: path,
+	}, nil
+}
+
+// newIndexFromPath loads the `index.json` at the given path.
+// If the layout does not exist, then nothing is returned.
+func newIndexFromPath(path string) (v1.ImageIndex, error) {
+	if !layoutExists(path) {
+		return nil, nil
+	}
+	layoutPath, err := FromPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load layout from path: %w", err)
+	}
+	index, err := layoutPath.ImageIndex()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load index: %w", err)
+	}
+	return index, nil
+}
+
+func (i *Index) Kind() string {
+	return "layout"
+}
+
+func (i *Index) Name() string {
+	return i.repoPath
+}
+
+func (i *Index) Rename(name string) {
+	i.repoPath = name
+}
+
+// Found reports if index exists in the image store with `Name()`.
+func (i *Index) Found() bool {
+	return layoutExists(i.repoPath)
+}
+
+func (i *Index) Identifier() (imgutil.Identifier, error) {
+	hash, err := i.Digest()
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting identifier for index at path %q", i.repoPath)
+	}
+	return newLayoutIdentifier(i.repoPath, hash)
+}
+
+func (i *Index) Delete() error {
+	return os.RemoveAll(i.repoPath)
+}
+
+func (i *Index) Save(additionalNames ...string) error {
+	return i.SaveAs(i.Name(), additionalNames...)
+}
+
+// SaveAs ignores the index `Name()` method and saves the index according to name & additional names provided to this method
+func (i *Index) SaveAs(name string, additionalNames ...string) error {
+	var (
+		pathsToSave = append([]string{name}, additionalNames...)
+		diagnostics []imgutil.SaveDiagnostic
+	)
+	for _, path := range pathsToSave {
+		layoutPath, err := initEmptyIndexAt(path)
+		if err != nil {
+			diagnostics = append(diagnostics, imgutil.SaveDiagnostic{ImageName: path, Cause: err})
+			continue
+		}
+		if err = layoutPath.writeIndex(i.CNBIndex); err != nil {
+			diagnostics = append(diagnostics, imgutil.SaveDiagnostic{ImageName: path, Cause: err})
+		}
+	}
+	if len(diagnostics) > 0 {
+		return imgutil.SaveError{Errors: diagnostics}
+	}
+	return nil
+}
@@ -0,0 +1,371 @@
+package layout_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil"
+	"github.com/buildpacks/imgutil/layout"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestLayoutIndex(t *testing.T) {
+	spec.Run(t, "Index", testIndex, spec.Sequential(), spec.Report(report.Terminal{}))
+}
+
+func testIndex(t *testing.T, when spec.G, it spec.S) {
+	var (
+		tmpDir    string
+		indexPath string
+		amd64     = imgutil.Platform{OS: "linux", Architecture: "amd64"}
+		arm64     = imgutil.Platform{OS: "linux", Architecture: "arm64"}
+		err       error
+	)
+
+	newPlatformImage := func(platform imgutil.Platform) *layout.Image {
+		img, err := layout.NewImage(filepath.Join(tmpDir, h.RandString(10)), layout.WithDefaultPlatform(platform))
+		h.AssertNil(t, err)
+		h.AssertNil(t, img.Save())
+		return img
+	}
+
+	// newNestedIndexAt writes a layout whose index holds a nested index with an image for the platform,
+	// and returns the image
+	newNestedIndexAt := func(path string, platform imgutil.Platform) v1.Image {
+		img, err := random.Image(1024, 1)
+		h.AssertNil(t, err)
+		descriptor := v1.Descriptor{Platform: &v1.Platform{OS: platform.OS, Architecture: platform.Architecture}}
+		child := mutate.AppendManifests(empty.Index, mutate.IndexAddendum{Add: img, Descriptor: descriptor})
+		index := mutate.AppendManifests(empty.Index, mutate.IndexAddendum{Add: child, Descriptor: descriptor})
+		_, err = layout.Write(path, index)
+		h.AssertNil(t, err)
+		return img
+	}
+
+	it.Before(func() {
+		tmpDir, err = os.MkdirTemp("", "layout-index")
+		h.AssertNil(t, err)
+		indexPath = filepath.Join(tmpDir, "index")
+	})
+
+	it.After(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	when("#NewIndex", func() {
+		it("defaults to an empty OCI image index", func() {
+			idx, err := layout.NewIndex(indexPath)
+			h.AssertNil(t, err)
+
+			mediaType, err := idx.MediaType()
+			h.AssertNil(t, err)
+			h.AssertEq(t, mediaType, types.OCIImageIndex)
+
+			platforms, err := idx.Platforms()
+			h.AssertNil(t, err)
+			h.AssertEq(t, len(platforms), 0)
+		})
+
+		when("#WithMediaTypes", func() {
+			it("sets the requested media type", func() {
+				idx, err := layout.NewIndex(indexPath, layout.WithMediaTypes(imgutil.DockerTypes))
+				h.AssertNil(t, err)
+
+				mediaType, err := idx.MediaType()
+				h.AssertNil(t, err)
+				h.AssertEq(t, mediaType, types.DockerManifestList)
+			})
+		})
+
+		when("#FromBaseIndex", func() {
+			it("loads the manifests of the existing index", func() {
+				base, err := layout.NewIndex(indexPath)
+				h.AssertNil(t, err)
+				h.AssertNil(t, base.AddManifest(newPlatformImage(amd64)))
+				h.AssertNil(t, base.AddManifest(newPlatformImage(arm64)))
+				h.AssertNil(t, base.Save())
+
+				idx, err := layout.NewIndex(filepath.Join(tmpDir, "other-index"), imgutil.FromBaseIndex(indexPath))
+				h.AssertNil(t, err)
+
+				platforms, err := idx.Platforms()
+				h.AssertNil(t, err)
+				h.AssertEq(t, platforms, []imgutil.Platform{amd64, arm64})
+			})
+
+			when("base index does not exist", func() {
+				it("returns an empty index", func() {
+					idx, err := layout.NewIndex(indexPath, imgutil.FromBaseIndex(filepath.Join(tmpDir, "does-not-exist")))
+					h.AssertNil(t, err)
+
+					platforms, err := idx.Platforms()
+					h.AssertNil(t, err)
+					h.AssertEq(t, len(platforms), 0)
+				})
+			})
+		})
+	})
+
+	when("#AddManifest", func() {
+		it("adds the image for its platform", func() {
+			idx, err := layout.NewIndex(indexPath)
+			h.AssertNil(t, err)
+			img := newPlatformImage(arm64)
+
+			h.AssertNil(t, idx.AddManifest(img))
+
+			found, err := idx.ImageForPlatform(arm64)
+			h.AssertNil(t, err)
+			expectedDigest, err := img.Digest()
+			h.AssertNil(t, err)
+			actualDigest, err := found.Digest()
+			h.AssertNil(t, err)
+			h.AssertEq(t, actualDigest, expectedDigest)
+		})
+
+		it("errors when the platform already exists", func() {
+			idx, err := layout.NewIndex(indexPath)
+			h.AssertNil(t, err)
+			h.AssertNil(t, idx.AddManifest(newPlatformImage(amd64)))
+
+			err = idx.AddManifest(newPlatformImage(amd64))
+			h.AssertError(t, err, "index already contains a manifest for platform")
+		})
+	})
+
+	when("#ImageForPlatform", func() {
+		var idx *layout.Index
+
+		it.Before(func() {
+			idx, err = layout.NewIndex(indexPath)
+			h.AssertNil(t, err)
+		})
+
+		// addManifests adds images for the platforms and returns their digests
+		addManifests := func(platforms ...imgutil.Platform) []string {
+			var digests []string
+			for _, platform := range platforms {
+				img := newPlatformImage(platform)
+				h.AssertNil(t, idx.AddManifest(img))
+				digest, err := img.Digest()
+				h.AssertNil(t, err)
+				digests = append(digests, digest.String())
+			}
+			return digests
+		}
+
+		assertImageFor := func(platform imgutil.Platform, expectedDigest string) {
+			t.Helper()
+			found, err := idx.ImageForPlatform(platform)
+			h.AssertNil(t, err)
+			digest, err := found.Digest()
+			h.AssertNil(t, err)
+			h.AssertEq(t, digest.String(), expectedDigest)
+		}
+
+		it("selects the image with the variant, or else the latest compatible one", func() {
+			armV6 := imgutil.Platform{OS: "linux", Architecture: "arm", Variant: "v6"}
+			armV7 := imgutil.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}
+			digests := addManifests(armV6, armV7, amd64)
+
+			assertImageFor(armV7, digests[1])
+			assertImageFor(armV6, digests[0])
+			assertImageFor(imgutil.Platform{OS: "linux", Architecture: "arm", Variant: "v8"}, digests[1])
+
+			_, err = idx.ImageForPlatform(imgutil.Platform{OS: "linux", Architecture: "arm", Variant: "v5"})
+			h.AssertEq(t, errors.Is(err, imgutil.ErrPlatformMismatch), true)
+		})
+
+		it("considers arm64 images without variant as v8", func() {
+			digests := addManifests(amd64, arm64)
+
+			assertImageFor(imgutil.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}, digests[1])
+		})
+
+		it("selects the windows image with the same build, preferring the same revision", func() {
+			digests := addManifests(
+				imgutil.Platform{OS: "windows", Architecture: "amd64", OSVersion: "10.0.20348.2113"},
+				imgutil.Platform{OS: "windows", Architecture: "amd64", OSVersion: "10.0.17763.1397"},
+				imgutil.Platform{OS: "windows", Architecture: "amd64", OSVersion: "10.0.17763.5329"},
+			)
+
+			assertImageFor(imgutil.Platform{OS: "windows", Architecture: "amd64", OSVersion: "10.0.17763.1397"}, digests[1])
+			assertImageFor(imgutil.Platform{OS: "windows", Architecture: "amd64", OSVersion: "10.0.17763.4000"}, digests[2])
+			assertImageFor(imgutil.Platform{OS: "windows", Architecture: "amd64", OSVersion: "10.0.20348.1"}, digests[0])
+
+			_, err = idx.ImageForPlatform(imgutil.Platform{OS: "windows", Architecture: "amd64", OSVersion: "10.0.14393.1"})
+			h.AssertEq(t, errors.Is(err, imgutil.ErrPlatformMismatch), true)
+		})
+
+		it("selects the image with the OS features", func() {
+			digests := addManifests(
+				imgutil.Platform{OS: "windows", Architecture: "amd64"},
+				imgutil.Platform{OS: "windows", Architecture: "amd64", OSFeatures: "win32k"},
+			)
+
+			assertImageFor(imgutil.Platform{OS: "windows", Architecture: "amd64", OSFeatures: "win32k"}, digests[1])
+		})
+
+		it("looks into nested indexes", func() {
+			basePath := filepath.Join(tmpDir, "base-index")
+			img := newNestedIndexAt(basePath, arm64)
+			expectedDigest, err := img.Digest()
+			h.AssertNil(t, err)
+			idx, err = layout.NewIndex(indexPath, imgutil.FromBaseIndex(basePath))
+			h.AssertNil(t, err)
+
+			assertImageFor(arm64, expectedDigest.String())
+		})
+
+		it("lists the available platforms if none matches", func() {
+			addManifests(amd64, imgutil.Platform{OS: "linux", Architecture: "arm", Variant: "v7"})
+
+			_, err = idx.ImageForPlatform(arm64)
+			h.AssertError(t, err, "failed to find manifest matching platform linux/arm64: available platforms are linux/amd64, linux/arm/v7")
+			h.AssertEq(t, errors.Is(err, imgutil.ErrPlatformMismatch), true)
+		})
+	})
+
+	when("#ReplaceManifest", func() {
+		it("replaces the image for its platform", func() {
+			idx, err := layout.NewIndex(indexPath)
+			h.AssertNil(t, err)
+			h.AssertNil(t, idx.AddManifest(newPlatformImage(amd64)))
+
+			replacement := newPlatformImage(amd64)
+			h.AssertNil(t, replacement.SetLabel("some-key", "some-value"))
+			h.AssertNil(t, idx.ReplaceManifest(replacement))
+
+			manifest, err := idx.IndexManifest()
+			h.AssertNil(t, err)
+			h.AssertEq(t, len(manifest.Manifests), 1)
+			expectedDigest, err := replacement.Digest()
+			h.AssertNil(t, err)
+			h.AssertEq(t, manifest.Manifests[0].Digest, expectedDigest)
+		})
+	})
+
+	when("#RemoveManifest", func() {
+		it("removes the image for the platform", func() {
+			idx, err := layout.NewIndex(indexPath)
+			h.AssertNil(t, err)
+			h.AssertNil(t, idx.AddManifest(newPlatformImage(amd64)))
+			h.AssertNil(t, idx.AddManifest(newPlatformImage(arm64)))
+
+			h.AssertNil(t, idx.RemoveManifest(amd64))
+
+			platforms, err := idx.Platforms()
+			h.AssertNil(t, err)
+			h.AssertEq(t, platforms, []imgutil.Platform{arm64})
+		})
+
+		it("errors when the platform does not exist", func() {
+			idx, err := layout.NewIndex(indexPath)
+			h.AssertNil(t, err)
+
+			err = idx.RemoveManifest(amd64)
+			h.AssertError(t, err, "failed to find manifest matching platform")
+			h.AssertEq(t, errors.Is(err, imgutil.ErrPlatformMismatch), true)
+		})
+	})
+
+	when("#SetAnnotation", func() {
+		it("sets and removes annotations on the index manifest", func() {
+			idx, err := layout.NewIndex(indexPath)
+			h.AssertNil(t, err)
+
+			h.AssertNil(t, idx.SetAnnotation("some-key", "some-value"))
+			h.AssertNil(t, idx.SetAnnotation("other-key", "other-value"))
+			h.AssertNil(t, idx.RemoveAnnotation("other-key"))
+
+			annotations, err := idx.Annotations()
+			h.AssertNil(t, err)
+			h.AssertEq(t, annotations, map[string]string{"some-key": "some-value"})
+		})
+	})
+
+	when("#Save", func() {
+		it("writes the index and all referenced images to the layout", func() {
+			idx, err := layout.NewIndex(indexPath)
+			h.AssertNil(t, err)
+			h.AssertNil(t, idx.AddManifest(newPlatformImage(amd64)))
+			h.AssertNil(t, idx.AddManifest(newPlatformImage(arm64)))
+			h.AssertNil(t, idx.SetAnnotation("some-key", "some-value"))
+			anotherPath := filepath.Join(tmpDir, "another-index")
+
+			h.AssertNil(t, idx.Save(anotherPath))
+
+			for _, path := range []string{indexPath, anotherPath} {
+				index := h.ReadIndexManifest(t, path)
+				h.AssertEq(t, len(index.Manifests), 2)
+				h.AssertEq(t, index.Manifests[0].Platform.Architecture, "amd64")
+				h.AssertEq(t, index.Manifests[1].Platform.Architecture, "arm64")
+				h.AssertEq(t, index.Annotations["some-key"], "some-value")
+				for _, desc := range index.Manifests {
+					manifest := h.ReadManifest(t, desc.Digest, path)
+					h.ReadConfigFile(t, manifest, path)
+				}
+			}
+			h.AssertTrue(t, idx.Found)
+		})
+
+		it("writes the nested indexes, skipping the layers without data", func() {
+			basePath := filepath.Join(tmpDir, "base-index")
+			img := newNestedIndexAt(basePath, amd64)
+			layers, err := img.Layers()
+			h.AssertNil(t, err)
+			layerDigest, err := layers[0].Digest()
+			h.AssertNil(t, err)
+			// make the base index sparse
+			h.AssertNil(t, os.Remove(filepath.Join(basePath, "blobs", layerDigest.Algorithm, layerDigest.Hex)))
+			idx, err := layout.NewIndex(indexPath, imgutil.FromBaseIndex(basePath))
+			h.AssertNil(t, err)
+
+			h.AssertNil(t, idx.Save())
+
+			index := h.ReadIndexManifest(t, indexPath)
+			h.AssertEq(t, len(index.Manifests), 1)
+			h.AssertEq(t, index.Manifests[0].MediaType.IsIndex(), true)
+			savedPath, err := layout.FromPath(indexPath)
+			h.AssertNil(t, err)
+			child, err := savedPath.ImageIndex()
+			h.AssertNil(t, err)
+			child, err = child.ImageIndex(index.Manifests[0].Digest)
+			h.AssertNil(t, err)
+			childManifest, err := child.IndexManifest()
+			h.AssertNil(t, err)
+			h.AssertEq(t, len(childManifest.Manifests), 1)
+			manifest := h.ReadManifest(t, childManifest.Manifests[0].Digest, indexPath)
+			h.ReadConfigFile(t, manifest, indexPath)
+			_, err = os.Stat(filepath.Join(indexPath, "blobs", layerDigest.Algorithm, layerDigest.Hex))
+			h.AssertEq(t, errors.Is(err, os.ErrNotExist), true)
+		})
+
+		it("reports failures per path", func() {
+			idx, err := layout.NewIndex(indexPath)
+			h.AssertNil(t, err)
+			h.AssertNil(t, idx.AddManifest(newPlatformImage(amd64)))
+			someFile := filepath.Join(tmpDir, "some-file")
+			h.AssertNil(t, os.WriteFile(someFile, []byte("some-content"), 0600))
+			invalidPath := filepath.Join(someFile, "index")
+
+			err = idx.Save(invalidPath)
+
+			var saveErr imgutil.SaveError
+			h.AssertEq(t, errors.As(err, &saveErr), true)
+			h.AssertEq(t, len(saveErr.Errors), 1)
+			h.AssertEq(t, saveErr.Errors[0].ImageName, invalidPath)
+			h.AssertEq(t, len(h.ReadIndexManifest(t, indexPath).Manifests), 1)
+		})
+	})
+}
@@ -1,21 +1,28 @@
 package layout
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/match"
 	"github.com/pkg/errors"
 
 	"github.com/buildpacks/imgutil"
 )
 
-var _ imgutil.Image = (*Image)(nil)
+var _ imgutil.ImageWithContext = (*Image)(nil)
 
 type Image struct {
 	*imgutil.CNBImageCore
+	ctx               context.Context
 	repoPath          string
 	saveWithoutLayers bool
 	preserveDigest    bool
+	progress          imgutil.ProgressFunc
+	archiveFormat     imgutil.ArchiveFormat
+	layerResolver     func(digest v1.Hash) (v1.Layer, error)
 }
 
 func (i *Image) Kind() string {
@@ -35,7 +42,34 @@ func (i *Image) Found() bool {
 	return imageExists(i.repoPath)
 }
 
-func imageExists(path string) bool {
+// FoundWithContext is like Found; it only accesses the filesystem.
+func (i *Image) FoundWithContext(_ context.Context) bool {
+	return i.Found()
+}
+
+// imageExists reports if there is a layout at the path,
+// with an image with the ref name for names of the form "<path>:<ref name>".
+func imageExists(name string) bool {
+	path, refName := splitRefName(name)
+	if !layoutExists(path) {
+		return false
+	}
+	if refName == "" {
+		return true
+	}
+	layoutPath, err := FromPath(path)
+	if err != nil {
+		return false
+	}
+	index, err := layoutPath.ImageIndex()
+	if err != nil {
+		return false
+	}
+	manifests, err := manifestsWithRefName(index, refName)
+	return err == nil && len(manifests) > 0
+}
+
+func layoutExists(path string) bool {
 	if !pathExists(path) {
 		return false
 	}
@@ -66,11 +100,60 @@ func (i *Image) Identifier() (imgutil.Identifier, error) {
 	return newLayoutIdentifier(i.repoPath, hash)
 }
 
+// Valid returns true if the image is saved at its path with all its blobs present with their declared size.
+// Layers that are not present, as in sparse images, do not make it invalid.
+// The digests of the blobs are not checked, as it requires reading them entirely (see Path.Verify).
 func (i *Image) Valid() bool {
-	// layout images may be invalid if they are missing layer data
-	return true
+	return i.valid() == nil
+}
+
+func (i *Image) valid() error {
+	path, refName := splitRefName(i.repoPath)
+	if !imageExists(i.repoPath) {
+		return errors.Wrapf(imgutil.ErrImageNotFound, "failed to find image at path %q", i.repoPath)
+	}
+	layoutPath, err := FromPath(path)
+	if err != nil {
+		return err
+	}
+	index, err := layoutPath.ImageIndex()
+	if err != nil {
+		return err
+	}
+	manifest, err := index.IndexManifest()
+	if err != nil {
+		return err
+	}
+	descs := manifest.Manifests
+	if refName != "" {
+		if descs, err = manifestsWithRefName(index, refName); err != nil {
+			return err
+		}
+	}
+	_, err = layoutPath.verifyDescriptors(descs, withSizesOnly())
+	return err
 }
 
 func (i *Image) Delete() error {
-	return os.RemoveAll(i.repoPath)
+	return i.DeleteWithContext(i.ctx)
+}
+
+// DeleteWithContext removes the layout at the path of the image or, for names of the form "<path>:<ref name>",
+// the descriptors with the ref name from the index of the layout, leaving its blobs (see Path.GC).
+func (i *Image) DeleteWithContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	path, refName := splitRefName(i.repoPath)
+	if refName == "" {
+		return os.RemoveAll(path)
+	}
+	if !layoutExists(path) {
+		return nil
+	}
+	layoutPath, err := FromPath(path)
+	if err != nil {
+		return err
+	}
+	return layoutPath.RemoveDescriptors(match.Annotation(ImageRefNameKey, refName))
 }
@@ -1,13 +1,20 @@
 package layout_test
 
 import (
+	"compress/gzip"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/google/go-containerregistry/pkg/compression"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 
 	"github.com/google/go-containerregistry/pkg/v1/remote"
@@ -245,6 +252,52 @@ func testImage(t *testing.T, when spec.G, it spec.S) {
 				})
 			})
 
+			when("base image has images for several platforms", func() {
+				var indexPath string
+
+				it.Before(func() {
+					indexPath = filepath.Join(tmpDir, "multi-platform-index")
+					idx, err := layout.NewIndex(indexPath)
+					h.AssertNil(t, err)
+					for _, platform := range []imgutil.Platform{
+						{OS: "linux", Architecture: "amd64"},
+						{OS: "linux", Architecture: "arm", Variant: "v6"},
+						{OS: "linux", Architecture: "arm", Variant: "v7"},
+					} {
+						platformImage, err := layout.NewImage(filepath.Join(tmpDir, h.RandString(10)), layout.WithDefaultPlatform(platform))
+						h.AssertNil(t, err)
+						h.AssertNil(t, idx.AddManifest(platformImage))
+					}
+					h.AssertNil(t, idx.Save())
+				})
+
+				it("uses the image matching the platform", func() {
+					img, err := layout.NewImage(
+						imagePath,
+						layout.FromBaseImagePath(indexPath),
+						layout.WithDefaultPlatform(imgutil.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}),
+					)
+					h.AssertNil(t, err)
+
+					arch, err := img.Architecture()
+					h.AssertNil(t, err)
+					h.AssertEq(t, arch, "arm")
+					variant, err := img.Variant()
+					h.AssertNil(t, err)
+					h.AssertEq(t, variant, "v7")
+				})
+
+				it("returns an error listing the platforms if none matches", func() {
+					_, err := layout.NewImage(
+						imagePath,
+						layout.FromBaseImagePath(indexPath),
+						layout.WithDefaultPlatform(imgutil.Platform{OS: "linux", Architecture: "arm64"}),
+					)
+					h.AssertError(t, err, "available platforms are linux/amd64, linux/arm/v6, linux/arm/v7")
+					h.AssertEq(t, errors.Is(err, imgutil.ErrPlatformMismatch), true)
+				})
+			})
+
 			when("base image does not exist", func() {
 				it("returns an empty image", func() {
 					img, err := layout.NewImage(imagePath, layout.FromBaseImagePath("some-bad-repo-name"))
@@ -254,6 +307,12 @@ func testImage(t *testing.T, when spec.G, it spec.S) {
 					_, err = img.TopLayer()
 					h.AssertError(t, err, "has no layers")
 				})
+
+				it("returns an error with #WithStrictBaseImage", func() {
+					_, err := layout.NewImage(imagePath, layout.FromBaseImagePath("some-bad-repo-name"), imgutil.WithStrictBaseImage())
+					h.AssertError(t, err, `failed to find image at path "some-bad-repo-name"`)
+					h.AssertEq(t, errors.Is(err, imgutil.ErrImageNotFound), true)
+				})
 			})
 
 			when("existing config has extra fields", func() {
@@ -265,6 +324,57 @@ func testImage(t *testing.T, when spec.G, it spec.S) {
 					h.AssertEq(t, digest.String(), "sha256:f75f3d1a317fc82c793d567de94fc8df2bece37acd5f2bd364a0d91a0d1f3dab")
 				})
 			})
+
+			when("#WithBaseImageAnnotations", func() {
+				var baseImagePath = filepath.Join("testdata", "layout", "busybox-sparse")
+
+				it("sets the base image annotations", func() {
+					img, err := layout.NewImage(imagePath, layout.FromBaseImagePath(baseImagePath), imgutil.WithBaseImageAnnotations())
+					h.AssertNil(t, err)
+					h.AssertNil(t, img.Save())
+
+					index := h.ReadIndexManifest(t, imagePath)
+					manifest := h.ReadManifest(t, index.Manifests[0].Digest, imagePath)
+					h.AssertEq(t, manifest.Annotations[imgutil.BaseImageNameAnnotation], baseImagePath)
+					h.AssertEq(t, manifest.Annotations[imgutil.BaseImageDigestAnnotation], "sha256:f75f3d1a317fc82c793d567de94fc8df2bece37acd5f2bd364a0d91a0d1f3dab")
+				})
+
+				it("updates the base image annotations when rebased", func() {
+					img, err := layout.NewImage(imagePath, layout.FromBaseImagePath(baseImagePath), imgutil.WithBaseImageAnnotations())
+					h.AssertNil(t, err)
+					topLayer, err := img.TopLayer()
+					h.AssertNil(t, err)
+
+					newBasePath := filepath.Join(tmpDir, "new-base-image")
+					newBase, err := layout.NewImage(newBasePath, layout.FromBaseImagePath(baseImagePath))
+					h.AssertNil(t, err)
+					h.AssertNil(t, newBase.SetLabel("some-key", "some-value"))
+					h.AssertNil(t, newBase.Save())
+					newBase, err = layout.NewImage(newBasePath, layout.FromBaseImagePath(newBasePath))
+					h.AssertNil(t, err)
+					newBaseDigest, err := newBase.Digest()
+					h.AssertNil(t, err)
+
+					h.AssertNil(t, img.Rebase(topLayer, newBase))
+
+					annotations, err := img.Annotations()
+					h.AssertNil(t, err)
+					h.AssertEq(t, annotations[imgutil.BaseImageNameAnnotation], newBasePath)
+					h.AssertEq(t, annotations[imgutil.BaseImageDigestAnnotation], newBaseDigest.String())
+				})
+
+				when("base image does not exist", func() {
+					it("does not set the base image annotations", func() {
+						img, err := layout.NewImage(imagePath, layout.FromBaseImagePath("some-bad-repo-name"), imgutil.WithBaseImageAnnotations())
+						h.AssertNil(t, err)
+
+						annotations, err := img.Annotations()
+						h.AssertNil(t, err)
+						_, exists := annotations[imgutil.BaseImageNameAnnotation]
+						h.AssertEq(t, exists, false)
+					})
+				})
+			})
 		})
 
 		when("#WithMediaTypes", func() {
@@ -304,6 +414,178 @@ func testImage(t *testing.T, when spec.G, it spec.S) {
 			})
 		})
 
+		when("#WithLayerCompression", func() {
+			it("writes zstd compressed layers", func() {
+				img, err := layout.NewImage(
+					imagePath,
+					imgutil.WithLayerCompression(imgutil.LayerCompression{Algorithm: compression.ZStd}),
+				)
+				h.AssertNil(t, err)
+				path, diffID, _ := h.RandomLayer(t, tmpDir)
+				h.AssertNil(t, img.AddLayerWithDiffID(path, diffID))
+				h.AssertNil(t, img.Save())
+
+				manifest, configFile := h.ReadManifestAndConfigFile(t, imagePath)
+				h.AssertEq(t, len(manifest.Layers), 1)
+				h.AssertEq(t, manifest.Layers[0].MediaType, types.OCILayerZStd)
+				h.AssertEq(t, configFile.RootFS.DiffIDs[0].String(), diffID)
+				blob, err := os.ReadFile(filepath.Join(imagePath, "blobs", "sha256", manifest.Layers[0].Digest.Hex))
+				h.AssertNil(t, err)
+				h.AssertEq(t, blob[:4], []byte{0x28, 0xb5, 0x2f, 0xfd})
+			})
+
+			it("writes uncompressed layers", func() {
+				img, err := layout.NewImage(
+					imagePath,
+					imgutil.WithLayerCompression(imgutil.LayerCompression{Algorithm: compression.None}),
+				)
+				h.AssertNil(t, err)
+				path, diffID, _ := h.RandomLayer(t, tmpDir)
+				h.AssertNil(t, img.AddLayerWithDiffID(path, diffID))
+				h.AssertNil(t, img.Save())
+
+				manifest, _ := h.ReadManifestAndConfigFile(t, imagePath)
+				h.AssertEq(t, len(manifest.Layers), 1)
+				h.AssertEq(t, manifest.Layers[0].MediaType, types.OCIUncompressedLayer)
+				h.AssertEq(t, manifest.Layers[0].Digest.String(), diffID)
+			})
+
+			it("writes gzip compressed layers with the requested level", func() {
+				img, err := layout.NewImage(
+					imagePath,
+					imgutil.WithLayerCompression(imgutil.LayerCompression{Algorithm: compression.GZip, Level: gzip.BestCompression}),
+				)
+				h.AssertNil(t, err)
+				path, diffID, _ := h.RandomLayer(t, tmpDir)
+				h.AssertNil(t, img.AddLayerWithDiffID(path, diffID))
+				h.AssertNil(t, img.Save())
+
+				h.AssertOCIMediaTypes(t, img)
+				manifest, configFile := h.ReadManifestAndConfigFile(t, imagePath)
+				h.AssertEq(t, configFile.RootFS.DiffIDs[0].String(), diffID)
+				blob, err := os.ReadFile(filepath.Join(imagePath, "blobs", "sha256", manifest.Layers[0].Digest.Hex))
+				h.AssertNil(t, err)
+				h.AssertEq(t, blob[:2], []byte{0x1f, 0x8b})
+			})
+
+			it("recompresses the layers of the base image when saved", func() {
+				img, err := layout.NewImage(
+					imagePath,
+					layout.FromBaseImagePath(fullBaseImagePath),
+					imgutil.WithLayerCompression(imgutil.LayerCompression{Algorithm: compression.ZStd}),
+				)
+				h.AssertNil(t, err)
+				beforeConfig, err := img.ConfigFile()
+				h.AssertNil(t, err)
+
+				h.AssertNil(t, img.Save())
+
+				manifest, configFile := h.ReadManifestAndConfigFile(t, imagePath)
+				for _, layer := range manifest.Layers {
+					h.AssertEq(t, layer.MediaType, types.OCILayerZStd)
+				}
+				h.AssertEq(t, configFile.RootFS.DiffIDs, beforeConfig.RootFS.DiffIDs)
+			})
+
+			it("preserves the layers of a sparse base image", func() {
+				img, err := layout.NewImage(
+					imagePath,
+					layout.FromBaseImagePath(sparseBaseImagePath),
+					imgutil.WithLayerCompression(imgutil.LayerCompression{Algorithm: compression.ZStd}),
+				)
+				h.AssertNil(t, err)
+				beforeManifest, err := img.UnderlyingImage().Manifest()
+				h.AssertNil(t, err)
+				beforeConfig, err := img.ConfigFile()
+				h.AssertNil(t, err)
+				path, diffID, _ := h.RandomLayer(t, tmpDir)
+				h.AssertNil(t, img.AddLayerWithDiffID(path, diffID))
+
+				h.AssertNil(t, img.Save())
+
+				manifest, configFile := h.ReadManifestAndConfigFile(t, imagePath)
+				h.AssertEq(t, len(manifest.Layers), len(beforeManifest.Layers)+1)
+				for idx, layer := range beforeManifest.Layers {
+					h.AssertEq(t, manifest.Layers[idx].Digest, layer.Digest)
+					h.AssertEq(t, configFile.RootFS.DiffIDs[idx], beforeConfig.RootFS.DiffIDs[idx])
+				}
+				h.AssertEq(t, manifest.Layers[len(manifest.Layers)-1].MediaType, types.OCILayerZStd)
+			})
+
+			when("#RecompressLayers", func() {
+				it("recompresses the layers when converting media types", func() {
+					img, err := layout.NewImage(imagePath, layout.FromBaseImagePath(fullBaseImagePath))
+					h.AssertNil(t, err)
+
+					converted, _, err := imgutil.EnsureMediaTypesAndLayers(
+						img,
+						imgutil.OCITypes,
+						imgutil.RecompressLayers(imgutil.LayerCompression{Algorithm: compression.ZStd}),
+					)
+					h.AssertNil(t, err)
+
+					manifest, err := converted.Manifest()
+					h.AssertNil(t, err)
+					for _, layer := range manifest.Layers {
+						h.AssertEq(t, layer.MediaType, types.OCILayerZStd)
+					}
+					beforeConfig, err := img.ConfigFile()
+					h.AssertNil(t, err)
+					afterConfig, err := converted.ConfigFile()
+					h.AssertNil(t, err)
+					h.AssertEq(t, afterConfig.RootFS.DiffIDs, beforeConfig.RootFS.DiffIDs)
+				})
+			})
+		})
+
+		when("#WithEstargz", func() {
+			var (
+				layerDiffID       string
+				previousImage     *layout.Image
+				previousImagePath string
+			)
+
+			it.Before(func() {
+				previousImagePath = filepath.Join(tmpDir, "estargz-previous-image")
+				previousImage, err = layout.NewImage(previousImagePath, imgutil.WithEstargz())
+				h.AssertNil(t, err)
+				var layerPath string
+				layerPath, layerDiffID, _ = h.RandomLayer(t, tmpDir)
+				h.AssertNil(t, previousImage.AddLayerWithDiffID(layerPath, layerDiffID))
+				h.AssertNil(t, previousImage.Save())
+			})
+
+			it("writes eStargz layers with the original DiffID", func() {
+				manifest, configFile := h.ReadManifestAndConfigFile(t, previousImagePath)
+				h.AssertEq(t, len(manifest.Layers), 1)
+				h.AssertNotEq(t, manifest.Layers[0].Annotations["containerd.io/snapshot/stargz/toc.digest"], "")
+				h.AssertEq(t, configFile.RootFS.DiffIDs[0].String(), layerDiffID)
+
+				readCloser, err := previousImage.GetLayer(layerDiffID)
+				h.AssertNil(t, err)
+				defer readCloser.Close()
+				contentsDiffID, _, err := v1.SHA256(readCloser)
+				h.AssertNil(t, err)
+				h.AssertEq(t, contentsDiffID.String(), layerDiffID)
+			})
+
+			it("reuses layers by the original DiffID", func() {
+				img, err := layout.NewImage(imagePath, layout.WithPreviousImage(previousImagePath))
+				h.AssertNil(t, err)
+
+				hasLayer, err := img.PreviousImageHasLayer(layerDiffID)
+				h.AssertNil(t, err)
+				h.AssertEq(t, hasLayer, true)
+				h.AssertNil(t, img.ReuseLayer(layerDiffID))
+				h.AssertNil(t, img.Save())
+
+				manifest, configFile := h.ReadManifestAndConfigFile(t, imagePath)
+				h.AssertEq(t, configFile.RootFS.DiffIDs[0].String(), layerDiffID)
+				h.AssertEq(t, len(manifest.Layers), 1)
+				h.AssertNotEq(t, manifest.Layers[0].Annotations["containerd.io/snapshot/stargz/toc.digest"], "")
+			})
+		})
+
 		when("#WithPreviousImage", func() {
 			var (
 				layerDiffID       string
@@ -358,6 +640,16 @@ func testImage(t *testing.T, when spec.G, it spec.S) {
 
 					h.AssertNil(t, err)
 				})
+
+				it("returns an error with #WithStrictPreviousImage", func() {
+					_, err := layout.NewImage(
+						imagePath,
+						layout.WithPreviousImage("some-bad-repo-name"),
+						imgutil.WithStrictPreviousImage(),
+					)
+
+					h.AssertEq(t, errors.Is(err, imgutil.ErrImageNotFound), true)
+				})
 			})
 		})
 	})
@@ -652,6 +944,68 @@ func testImage(t *testing.T, when spec.G, it spec.S) {
 		})
 	})
 
+	when("#SetAnnotation", func() {
+		it.Before(func() {
+			imagePath = filepath.Join(tmpDir, "new-set-annotation-image")
+		})
+
+		it.After(func() {
+			os.RemoveAll(imagePath)
+		})
+
+		it("sets annotation on img object", func() {
+			img, err := layout.NewImage(imagePath)
+			h.AssertNil(t, err)
+
+			h.AssertNil(t, img.SetAnnotation("org.opencontainers.image.source", "https://example.com/some/repo"))
+
+			annotations, err := img.Annotations()
+			h.AssertNil(t, err)
+			h.AssertEq(t, annotations["org.opencontainers.image.source"], "https://example.com/some/repo")
+		})
+
+		it("saves annotations in the manifest and the index descriptor", func() {
+			img, err := layout.NewImage(imagePath)
+			h.AssertNil(t, err)
+
+			h.AssertNil(t, img.SetAnnotation("org.opencontainers.image.source", "https://example.com/some/repo"))
+			h.AssertNil(t, img.SetAnnotation("org.opencontainers.image.revision", "some-revision"))
+			h.AssertNil(t, img.RemoveAnnotation("org.opencontainers.image.revision"))
+			h.AssertNil(t, img.Save())
+
+			index := h.ReadIndexManifest(t, imagePath)
+			h.AssertEq(t, len(index.Manifests), 1)
+			h.AssertEq(t, len(index.Manifests[0].Annotations), 1)
+			h.AssertEqAnnotation(t, index.Manifests[0], "org.opencontainers.image.source", "https://example.com/some/repo")
+
+			manifest := h.ReadManifest(t, index.Manifests[0].Digest, imagePath)
+			h.AssertEq(t, manifest.Annotations, map[string]string{"org.opencontainers.image.source": "https://example.com/some/repo"})
+		})
+	})
+
+	when("#RemoveAnnotation", func() {
+		it.Before(func() {
+			imagePath = filepath.Join(tmpDir, "new-remove-annotation-image")
+		})
+
+		it.After(func() {
+			os.RemoveAll(imagePath)
+		})
+
+		it("removes annotation on img object", func() {
+			img, err := layout.NewImage(imagePath)
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.SetAnnotation("some-key", "some-value"))
+
+			h.AssertNil(t, img.RemoveAnnotation("some-key"))
+
+			annotations, err := img.Annotations()
+			h.AssertNil(t, err)
+			_, exists := annotations["some-key"]
+			h.AssertEq(t, exists, false)
+		})
+	})
+
 	when("#SetCmd", func() {
 		var image *layout.Image
 
@@ -828,8 +1182,12 @@ func testImage(t *testing.T, when spec.G, it spec.S) {
 						// assert org.opencontainers.image.ref.name annotation
 						index := h.ReadIndexManifest(t, imagePath)
 						h.AssertEq(t, len(index.Manifests), 1)
-						h.AssertEq(t, 1, len(index.Manifests[0].Annotations))
+						h.AssertEq(t, 2, len(index.Manifests[0].Annotations))
 						h.AssertEqAnnotation(t, index.Manifests[0], layout.ImageRefNameKey, "latest")
+
+						// assert the base image layer is recorded as omitted
+						manifest := h.ReadManifest(t, index.Manifests[0].Digest, imagePath)
+						h.AssertEqAnnotation(t, index.Manifests[0], layout.OmittedLayersKey, manifest.Layers[0].Digest.String())
 					})
 				})
 			})
@@ -1031,6 +1389,113 @@ func testImage(t *testing.T, when spec.G, it spec.S) {
 		})
 	})
 
+	when("#SaveFile", func() {
+		var (
+			image     *layout.Image
+			layerPath string
+		)
+
+		it.Before(func() {
+			imagePath = filepath.Join(tmpDir, "save-file-image")
+			layerPath, _, _ = h.RandomLayer(t, tmpDir)
+		})
+
+		it.After(func() {
+			os.RemoveAll(imagePath)
+		})
+
+		it("saves an OCI archive by default", func() {
+			image, err = layout.NewImage(imagePath)
+			h.AssertNil(t, err)
+			h.AssertNil(t, image.AddLayer(layerPath))
+			h.AssertNil(t, image.AnnotateRefName("my-tag"))
+
+			path, err := image.SaveFile()
+			h.AssertNil(t, err)
+			defer os.Remove(path)
+
+			layoutDir := filepath.Join(tmpDir, "save-file-layout")
+			defer os.RemoveAll(layoutDir)
+			h.Untar(t, path, layoutDir)
+			h.AssertPathExists(t, filepath.Join(layoutDir, "oci-layout"))
+			index := h.ReadIndexManifest(t, layoutDir)
+			h.AssertEq(t, len(index.Manifests), 1)
+			h.AssertEqAnnotation(t, index.Manifests[0], "org.opencontainers.image.ref.name", "my-tag")
+			digest, err := image.Digest()
+			h.AssertNil(t, err)
+			h.AssertEq(t, index.Manifests[0].Digest, digest)
+			manifest := h.ReadManifest(t, digest, layoutDir)
+			h.AssertEq(t, len(manifest.Layers), 1)
+			h.AssertPathExists(t, filepath.Join(layoutDir, "blobs", "sha256", manifest.Layers[0].Digest.Hex))
+		})
+
+		it("saves a docker archive when requested", func() {
+			image, err = layout.NewImage(imagePath, imgutil.WithArchiveFormat(imgutil.DockerArchive))
+			h.AssertNil(t, err)
+			h.AssertNil(t, image.AddLayer(layerPath))
+
+			path, err := image.SaveFile()
+			h.AssertNil(t, err)
+			defer os.Remove(path)
+
+			archived, err := tarball.ImageFromPath(path, nil)
+			h.AssertNil(t, err)
+			archivedConfig, err := archived.ConfigName()
+			h.AssertNil(t, err)
+			config, err := image.ConfigName()
+			h.AssertNil(t, err)
+			h.AssertEq(t, archivedConfig, config)
+		})
+
+		when("layers are not present in the layout", func() {
+			var (
+				sparsePath string
+				original   *layout.Image
+			)
+
+			it.Before(func() {
+				sparsePath = filepath.Join(tmpDir, "save-file-sparse-image")
+				original, err = layout.NewImage(sparsePath, layout.WithoutLayersWhenSaved())
+				h.AssertNil(t, err)
+				h.AssertNil(t, original.AddLayer(layerPath))
+				h.AssertNil(t, original.Save())
+			})
+
+			it.After(func() {
+				os.RemoveAll(sparsePath)
+			})
+
+			it("returns an error if there is no layer resolver", func() {
+				image, err = layout.NewImage(imagePath, layout.FromBaseImagePath(sparsePath))
+				h.AssertNil(t, err)
+
+				_, err = image.SaveFile()
+				h.AssertError(t, err, "is not present in the layout and no layer resolver was provided")
+				h.AssertEq(t, errors.Is(err, imgutil.ErrLayerMissing), true)
+			})
+
+			it("obtains the layers from the layer resolver", func() {
+				image, err = layout.NewImage(imagePath,
+					layout.FromBaseImagePath(sparsePath),
+					layout.WithLayerResolver(original.LayerByDigest),
+				)
+				h.AssertNil(t, err)
+
+				path, err := image.SaveFile()
+				h.AssertNil(t, err)
+				defer os.Remove(path)
+
+				layoutDir := filepath.Join(tmpDir, "save-file-layout")
+				defer os.RemoveAll(layoutDir)
+				h.Untar(t, path, layoutDir)
+				index := h.ReadIndexManifest(t, layoutDir)
+				manifest := h.ReadManifest(t, index.Manifests[0].Digest, layoutDir)
+				h.AssertEq(t, len(manifest.Layers), 1)
+				h.AssertPathExists(t, filepath.Join(layoutDir, "blobs", "sha256", manifest.Layers[0].Digest.Hex))
+			})
+		})
+	})
+
 	when("#Found", func() {
 		var image *layout.Image
 
@@ -1158,6 +1623,119 @@ func testImage(t *testing.T, when spec.G, it spec.S) {
 		})
 	})
 
+	when("#WithProgress", func() {
+		it.Before(func() {
+			imagePath = filepath.Join(tmpDir, "progress-image")
+		})
+
+		it.After(func() {
+			os.RemoveAll(imagePath)
+		})
+
+		it("reports the bytes written for each layer", func() {
+			var (
+				mutex   sync.Mutex
+				updates = map[string]imgutil.Progress{}
+			)
+			image, err := layout.NewImage(imagePath, imgutil.WithProgress(func(p imgutil.Progress) {
+				mutex.Lock()
+				defer mutex.Unlock()
+				updates[p.Layer] = p
+			}))
+			h.AssertNil(t, err)
+			layerPath, _, _ := h.RandomLayer(t, tmpDir)
+			h.AssertNil(t, image.AddLayer(layerPath))
+
+			h.AssertNil(t, image.Save())
+
+			layers, err := image.Layers()
+			h.AssertNil(t, err)
+			h.AssertEq(t, len(layers), 1)
+			digest, err := layers[0].Digest()
+			h.AssertNil(t, err)
+			size, err := layers[0].Size()
+			h.AssertNil(t, err)
+			h.AssertEq(t, updates, map[string]imgutil.Progress{
+				digest.String(): {Layer: digest.String(), Complete: size, Total: size},
+			})
+		})
+	})
+
+	when("#WithContext", func() {
+		var (
+			ctx    context.Context
+			cancel context.CancelFunc
+		)
+
+		it.Before(func() {
+			imagePath = filepath.Join(tmpDir, "context-image")
+			ctx, cancel = context.WithCancel(context.Background())
+		})
+
+		it.After(func() {
+			cancel()
+			os.RemoveAll(imagePath)
+		})
+
+		when("the construction context is done", func() {
+			it("does not save the image", func() {
+				image, err := layout.NewImage(imagePath, imgutil.WithContext(ctx))
+				h.AssertNil(t, err)
+				cancel()
+
+				h.AssertError(t, image.Save(), context.Canceled.Error())
+				h.AssertEq(t, image.Found(), false)
+			})
+		})
+
+		when("the context provided to a method is done", func() {
+			var (
+				image      *layout.Image
+				diffID     string
+				doneCtx    context.Context
+				doneCancel context.CancelFunc
+			)
+
+			it.Before(func() {
+				image, err = layout.NewImage(imagePath, imgutil.WithContext(ctx))
+				h.AssertNil(t, err)
+				var layerPath string
+				layerPath, diffID, _ = h.RandomLayer(t, tmpDir)
+				h.AssertNil(t, image.AddLayer(layerPath))
+				h.AssertNil(t, image.Save())
+
+				doneCtx, doneCancel = context.WithCancel(context.Background())
+				doneCancel()
+			})
+
+			it("returns the context error", func() {
+				h.AssertError(t, image.SaveWithContext(doneCtx), context.Canceled.Error())
+				h.AssertError(t, image.SaveAsWithContext(doneCtx, filepath.Join(tmpDir, "other-image")), context.Canceled.Error())
+				h.AssertError(t, image.DeleteWithContext(doneCtx), context.Canceled.Error())
+				_, err = image.GetLayerWithContext(doneCtx, diffID)
+				h.AssertError(t, err, context.Canceled.Error())
+				h.AssertError(t, image.RebaseWithContext(doneCtx, diffID, image), context.Canceled.Error())
+				h.AssertEq(t, image.Found(), true)
+			})
+
+			it("fails to read the layer once the context is done", func() {
+				layerCtx, layerCancel := context.WithCancel(context.Background())
+				rc, err := image.GetLayerWithContext(layerCtx, diffID)
+				h.AssertNil(t, err)
+				defer rc.Close()
+				layerCancel()
+
+				_, err = io.ReadAll(rc)
+				h.AssertError(t, err, context.Canceled.Error())
+			})
+
+			it("uses the construction context for methods without a context", func() {
+				h.AssertNil(t, image.Delete())
+				h.AssertEq(t, image.Found(), false)
+			})
+		})
+	})
+
 	when("#Platform", func() {
 		var platform imgutil.Platform
 		var image *layout.Image
@@ -8,6 +8,9 @@ import (
 	"github.com/buildpacks/imgutil"
 )
 
+// NewImage returns a new image that can be modified and saved to an OCI image layout at the provided path.
+// Images named "<path>:<ref name>", where a layout already exists at the path, are saved along with other images in it,
+// and base and previous images named so are found by their ref name.
 func NewImage(path string, ops ...imgutil.ImageOption) (*Image, error) {
 	options := &imgutil.ImageOptions{}
 	for _, op := range ops {
@@ -19,10 +22,17 @@ func NewImage(path string, ops ...imgutil.ImageOption) (*Image, error) {
 	var err error
 
 	if options.BaseImage == nil && options.BaseImageRepoName != "" { // options.BaseImage supersedes options.BaseImageRepoName
-		options.BaseImage, err = newImageFromPath(options.BaseImageRepoName, options.Platform)
+		options.BaseImage, err = newImageFromPath(options.BaseImageRepoName, options.Platform, options.StrictBaseImage)
 		if err != nil {
 			return nil, err
 		}
+		if options.BaseImage != nil {
+			baseDigest, err := options.BaseImage.Digest()
+			if err != nil {
+				return nil, fmt.Errorf("failed to get digest of base image: %w", err)
+			}
+			options.BaseImageDigest = baseDigest.String()
+		}
 	}
 	options.MediaTypes = imgutil.GetPreferredMediaTypes(*options)
 	if options.BaseImage != nil {
@@ -33,7 +43,7 @@ func NewImage(path string, ops ...imgutil.ImageOption) (*Image, error) {
 	}
 
 	if options.PreviousImageRepoName != "" {
-		options.PreviousImage, err = newImageFromPath(options.PreviousImageRepoName, options.Platform)
+		options.PreviousImage, err = newImageFromPath(options.PreviousImageRepoName, options.Platform, options.StrictPreviousImage)
 		if err != nil {
 			return nil, err
 		}
@@ -52,15 +62,18 @@ func NewImage(path string, ops ...imgutil.ImageOption) (*Image, error) {
 
 	return &Image{
 		CNBImageCore:      cnbImage,
+		ctx:               imgutil.ContextOrBackground(options.Context),
 		repoPath:          path,
 		saveWithoutLayers: options.WithoutLayers,
 		preserveDigest:    options.PreserveDigest,
+		progress:          options.Progress,
+		archiveFormat:     options.ArchiveFormat,
+		layerResolver:     options.LayerResolver,
 	}, nil
 }
 
 func processPlatformOption(requestedPlatform imgutil.Platform) imgutil.Platform {
-	var emptyPlatform imgutil.Platform
-	if requestedPlatform != emptyPlatform {
+	if !requestedPlatform.IsZero() {
 		return requestedPlatform
 	}
 	return imgutil.Platform{
@@ -69,14 +82,19 @@ func processPlatformOption(requestedPlatform imgutil.Platform) imgutil.Platform
 	}
 }
 
-// newImageFromPath creates a layout image from the given path.
+// newImageFromPath creates a layout image from the given path, or from the image with the ref name
+// in the layout at the path for names of the form "<path>:<ref name>".
 // * If an image index for multiple platforms exists, it will try to select the image according to the platform provided.
-// * If the image does not exist, then nothing is returned.
-func newImageFromPath(path string, withPlatform imgutil.Platform) (v1.Image, error) {
-	if !imageExists(path) {
+// * If the image does not exist, then nothing is returned, unless strict is true.
+func newImageFromPath(name string, withPlatform imgutil.Platform, strict bool) (v1.Image, error) {
+	if !imageExists(name) {
+		if strict {
+			return nil, fmt.Errorf("failed to find image at path %q: %w", name, imgutil.ErrImageNotFound)
+		}
 		return nil, nil
 	}
 
+	path, refName := splitRefName(name)
 	layoutPath, err := FromPath(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load layout from path: %w", err)
@@ -85,7 +103,15 @@ func newImageFromPath(path string, withPlatform imgutil.Platform) (v1.Image, err
 	if err != nil {
 		return nil, fmt.Errorf("failed to load index: %w", err)
 	}
-	image, err := imageFromIndex(index, withPlatform)
+	var image v1.Image
+	if refName == "" {
+		image, err = imageFromIndex(index, withPlatform)
+	} else {
+		var manifests []v1.Descriptor
+		if manifests, err = manifestsWithRefName(index, refName); err == nil {
+			image, err = imageFromManifests(index, manifests, withPlatform)
+		}
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to load image from index: %w", err)
 	}
@@ -93,30 +119,38 @@ func newImageFromPath(path string, withPlatform imgutil.Platform) (v1.Image, err
 }
 
 // imageFromIndex creates a v1.Image from the given Image Index, selecting the image manifest
-// that matches the given OS and architecture.
+// that best matches the given platform if there are several (see imgutil.FindManifestForPlatform).
 func imageFromIndex(index v1.ImageIndex, platform imgutil.Platform) (v1.Image, error) {
 	manifestList, err := index.IndexManifest()
 	if err != nil {
 		return nil, err
 	}
-	if len(manifestList.Manifests) == 0 {
-		return nil, fmt.Errorf("failed to find manifest at index")
+	return imageFromManifests(index, manifestList.Manifests, platform)
+}
+
+// imageFromManifests creates a v1.Image from the given manifests of the Image Index, like imageFromIndex,
+// looking into nested indexes if needed.
+func imageFromManifests(index v1.ImageIndex, manifests []v1.Descriptor, platform imgutil.Platform) (v1.Image, error) {
+	if len(manifests) == 0 {
+		return nil, fmt.Errorf("failed to find manifest at index: %w", imgutil.ErrImageNotFound)
 	}
 
 	// find manifest for platform
-	var manifest v1.Descriptor
-	if len(manifestList.Manifests) == 1 {
-		manifest = manifestList.Manifests[0]
-	} else {
-		for _, m := range manifestList.Manifests {
-			if m.Platform.OS == platform.OS &&
-				m.Platform.Architecture == platform.Architecture {
-				manifest = m
-				break
-			}
+	manifest := manifests[0]
+	if len(manifests) > 1 {
+		var err error
+		manifest, err = imgutil.FindManifestForPlatform(manifests, platform)
+		if err != nil {
+			return nil, err
 		}
-		return nil, fmt.Errorf("failed to find manifest matching platform %v", platform)
 	}
 
+	if manifest.MediaType.IsIndex() {
+		child, err := index.ImageIndex(manifest.Digest)
+		if err != nil {
+			return nil, err
+		}
+		return imageFromIndex(child, platform)
+	}
 	return index.Image(manifest.Digest)
 }
@@ -23,10 +23,19 @@ func WithoutLayersWhenSaved() func(*imgutil.ImageOptions) {
 	}
 }
 
+// WithLayerResolver (layout only) lets a caller provide the layers that are not present in the layout
+// (e.g., when the base image is sparse) when they are needed, such as when the image is saved to a file.
+func WithLayerResolver(resolver func(digest v1.Hash) (v1.Layer, error)) func(*imgutil.ImageOptions) {
+	return func(o *imgutil.ImageOptions) {
+		o.LayerResolver = resolver
+	}
+}
+
 // FIXME: the following functions are defined in this package for backwards compatibility,
 // and should eventually be deprecated.
 
-// FromBaseImagePath loads the image at the provided path as the manifest, config, and layers for the working image.
+// FromBaseImagePath loads the image at the provided path (or with the ref name, for "<path>:<ref name>")
+// as the manifest, config, and layers for the working image.
 // If the image is not found, it does nothing.
 func FromBaseImagePath(name string) func(*imgutil.ImageOptions) {
 	return imgutil.FromBaseImage(name)
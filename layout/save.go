@@ -1,28 +1,45 @@
 package layout
 
 import (
+	"context"
+
 	"github.com/google/go-containerregistry/pkg/v1/empty"
 
 	"github.com/buildpacks/imgutil"
 )
 
 func (i *Image) Save(additionalNames ...string) error {
-	return i.SaveAs(i.Name(), additionalNames...)
+	return i.SaveAsWithContext(i.ctx, i.Name(), additionalNames...)
+}
+
+func (i *Image) SaveWithContext(ctx context.Context, additionalNames ...string) error {
+	return i.SaveAsWithContext(ctx, i.Name(), additionalNames...)
 }
 
 // SaveAs ignores the image `Name()` method and saves the image according to name & additional names provided to this method
 func (i *Image) SaveAs(name string, additionalNames ...string) error {
+	return i.SaveAsWithContext(i.ctx, name, additionalNames...)
+}
+
+// SaveAsWithContext is like SaveAs, but stops saving to further paths once the context is done.
+func (i *Image) SaveAsWithContext(ctx context.Context, name string, additionalNames ...string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	if !i.preserveDigest {
 		if err := i.SetCreatedAtAndHistory(); err != nil {
 			return err
 		}
+		if err := i.CompressLayers(); err != nil {
+			return err
+		}
 	}
 
-	refName, err := i.GetAnnotateRefName()
+	annotations, err := i.Annotations()
 	if err != nil {
 		return err
 	}
-	ops := []AppendOption{WithAnnotations(ImageRefAnnotation(refName))}
+	ops := []AppendOption{WithAnnotations(annotations), WithLayerProgress(i.progress)}
 	if i.saveWithoutLayers {
 		ops = append(ops, WithoutLayers())
 	}
@@ -32,15 +49,20 @@ func (i *Image) SaveAs(name string, additionalNames ...string) error {
 		diagnostics []imgutil.SaveDiagnostic
 	)
 	for _, path := range pathsToSave {
-		layoutPath, err := initEmptyIndexAt(path)
+		if err = ctx.Err(); err != nil {
+			diagnostics = append(diagnostics, imgutil.SaveDiagnostic{ImageName: path, Cause: err})
+			continue
+		}
+		layoutPath, refName, err := layoutPathFor(path)
 		if err != nil {
-			return err
+			diagnostics = append(diagnostics, imgutil.SaveDiagnostic{ImageName: path, Cause: err})
+			continue
 		}
 		if err = layoutPath.AppendImage(
 			i.Image,
-			ops...,
+			append(ops, WithRefName(refName))...,
 		); err != nil {
-			diagnostics = append(diagnostics, imgutil.SaveDiagnostic{ImageName: i.Name(), Cause: err})
+			diagnostics = append(diagnostics, imgutil.SaveDiagnostic{ImageName: path, Cause: err})
 		}
 	}
 	if len(diagnostics) > 0 {
@@ -50,6 +72,18 @@ func (i *Image) SaveAs(name string, additionalNames ...string) error {
 	return nil
 }
 
+// layoutPathFor returns the layout to save the image with the name to, along with the ref name of the image, if any:
+// for names of the form "<path>:<ref name>", the existing layout at the path is kept, so that other images remain.
+func layoutPathFor(name string) (Path, string, error) {
+	path, refName := splitRefName(name)
+	if refName == "" {
+		layoutPath, err := initEmptyIndexAt(path)
+		return layoutPath, "", err
+	}
+	layoutPath, err := FromPath(path)
+	return layoutPath, refName, err
+}
+
 func initEmptyIndexAt(path string) (Path, error) {
 	return Write(path, empty.Index)
 }
@@ -1,8 +1,87 @@
 package layout
 
-import "errors"
+import (
+	"fmt"
 
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+
+	"github.com/buildpacks/imgutil"
+)
+
+// SaveFile saves the image as a tar file in the format chosen with imgutil.WithArchiveFormat
+// (by default, an OCI archive: a tar of an OCI layout containing only this image) and returns the path of the file.
+// Like Save, it sets the created at time and history of the image, and compresses its layers as requested,
+// unless the digest of the image is preserved.
+// Layers that are not present in the layout (e.g., from a sparse base image) are obtained from the resolver
+// provided with WithLayerResolver; if there is no resolver, an error is returned.
 func (i *Image) SaveFile() (string, error) {
-	// TODO issue https://github.com/buildpacks/imgutil/issues/170
-	return "", errors.New("not yet implemented")
+	if !i.preserveDigest {
+		if err := i.SetCreatedAtAndHistory(); err != nil {
+			return "", err
+		}
+		if err := i.CompressLayers(); err != nil {
+			return "", err
+		}
+	}
+	image, err := i.withResolvedLayers()
+	if err != nil {
+		return "", err
+	}
+	format := i.archiveFormat
+	if format == "" {
+		format = imgutil.OCIArchive
+	}
+	return imgutil.SaveArchiveFile(image, "", format)
+}
+
+// withResolvedLayers returns the image with all of its layers having data.
+func (i *Image) withResolvedLayers() (v1.Image, error) {
+	layers, err := i.Layers()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		resolvedLayers = make([]v1.Layer, len(layers))
+		resolved       bool
+	)
+	for idx, layer := range layers {
+		resolvedLayers[idx] = layer
+		if _, isFacade := layer.(*v1LayerFacade); !isFacade {
+			continue
+		}
+		digest, err := layer.Digest()
+		if err != nil {
+			return nil, err
+		}
+		if i.layerResolver == nil {
+			return nil, fmt.Errorf("layer %s is not present in the layout and no layer resolver was provided: %w", digest, imgutil.ErrLayerMissing)
+		}
+		resolvedLayer, err := i.layerResolver(digest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve layer %s: %w", digest, err)
+		}
+		resolvedDigest, err := resolvedLayer.Digest()
+		if err != nil {
+			return nil, err
+		}
+		if resolvedDigest != digest {
+			return nil, fmt.Errorf("resolved layer has digest %s; expected %s", resolvedDigest, digest)
+		}
+		resolvedLayers[idx] = resolvedLayer
+		resolved = true
+	}
+	if !resolved {
+		return i.CNBImageCore, nil
+	}
+	return &imageWithLayers{Image: i.CNBImageCore, layers: resolvedLayers}, nil
+}
+
+// imageWithLayers replaces the layers of an image with equivalent layers (having the same digests).
+type imageWithLayers struct {
+	v1.Image
+	layers []v1.Layer
+}
+
+func (i *imageWithLayers) Layers() ([]v1.Layer, error) {
+	return i.layers, nil
 }
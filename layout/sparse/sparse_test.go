@@ -3,6 +3,7 @@ package sparse_test
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	v1 "github.com/google/go-containerregistry/pkg/v1"
@@ -104,8 +105,17 @@ func testImage(t *testing.T, when spec.G, it spec.S) {
 				// assert org.opencontainers.image.ref.name annotation
 				index := h.ReadIndexManifest(t, imagePath)
 				h.AssertEq(t, len(index.Manifests), 1)
-				h.AssertEq(t, 1, len(index.Manifests[0].Annotations))
+				h.AssertEq(t, 2, len(index.Manifests[0].Annotations))
 				h.AssertEqAnnotation(t, index.Manifests[0], layout.ImageRefNameKey, "my-tag")
+
+				// assert the layers are recorded as omitted
+				manifest, err := testImage.Manifest()
+				h.AssertNil(t, err)
+				var omitted []string
+				for _, layer := range manifest.Layers {
+					omitted = append(omitted, layer.Digest.String())
+				}
+				h.AssertEqAnnotation(t, index.Manifests[0], layout.OmittedLayersKey, strings.Join(omitted, ","))
 			})
 		})
 
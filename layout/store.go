@@ -0,0 +1,56 @@
+package layout
+
+import (
+	"regexp"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// refNameDelim separates the path of a layout from the ref name of an image in the layout.
+//
+// Images named "<path>:<ref name>" (e.g., "/layouts/store:my-app"), where there is a layout at the path
+// and the ref name is valid, are stored in the layout at the path along with other images, sharing its blobs,
+// and are found by their ref name (see ImageRefNameKey):
+// saving an image replaces the descriptors with the same ref name in the index, and deleting it removes them.
+// Such a layout (a "store") must be created beforehand, e.g., with Write(path, empty.Index),
+// so that names containing the delimiter are never mistaken for images in a store.
+// Other names are paths of images stored alone in their layout.
+const refNameDelim = ":"
+
+// refNamePattern matches the ref names allowed by the OCI image layout specification,
+// without path separators, so that names such as "localhost:5000/some-image" are paths.
+var refNamePattern = regexp.MustCompile(`^[A-Za-z0-9]+(?:(?:[-._:@+]|--)[A-Za-z0-9]+)*$`)
+
+// splitRefName returns the path of the layout and the ref name of the image for names of the form "<path>:<ref name>"
+// where there is a layout at the path and the ref name is valid, or the name and an empty ref name otherwise.
+func splitRefName(name string) (string, string) {
+	if layoutExists(name) {
+		return name, ""
+	}
+	for idx := range name {
+		if !strings.HasPrefix(name[idx:], refNameDelim) {
+			continue
+		}
+		path, refName := name[:idx], name[idx+len(refNameDelim):]
+		if refNamePattern.MatchString(refName) && layoutExists(path) {
+			return path, refName
+		}
+	}
+	return name, ""
+}
+
+// manifestsWithRefName returns the descriptors of the index that have the ref name.
+func manifestsWithRefName(index v1.ImageIndex, refName string) ([]v1.Descriptor, error) {
+	manifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+	var descs []v1.Descriptor
+	for _, desc := range manifest.Manifests {
+		if desc.Annotations[ImageRefNameKey] == refName {
+			descs = append(descs, desc)
+		}
+	}
+	return descs, nil
+}
@@ -0,0 +1,181 @@
+package layout_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil"
+	"github.com/buildpacks/imgutil/layout"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestLayoutStore(t *testing.T) {
+	spec.Run(t, "Store", testStore, spec.Sequential(), spec.Report(report.Terminal{}))
+}
+
+func testStore(t *testing.T, when spec.G, it spec.S) {
+	var (
+		tmpDir    string
+		storePath string
+		layerPath string
+		err       error
+	)
+
+	it.Before(func() {
+		tmpDir, err = os.MkdirTemp("", "layout-store")
+		h.AssertNil(t, err)
+		storePath = filepath.Join(tmpDir, "store")
+		_, err = layout.Write(storePath, empty.Index)
+		h.AssertNil(t, err)
+		layerPath, err = h.CreateSingleFileLayerTar("/some-file.txt", "some-content", "linux")
+		h.AssertNil(t, err)
+	})
+
+	it.After(func() {
+		os.RemoveAll(tmpDir)
+		os.Remove(layerPath)
+	})
+
+	// saveImage saves an image with a label and a layer to the store under the ref name
+	saveImage := func(refName, labelValue string) *layout.Image {
+		img, err := layout.NewImage(storePath + ":" + refName)
+		h.AssertNil(t, err)
+		h.AssertNil(t, img.SetLabel("some-label", labelValue))
+		h.AssertNil(t, img.AddLayer(layerPath))
+		h.AssertNil(t, img.Save())
+		return img
+	}
+
+	refNames := func() []string {
+		var names []string
+		for _, desc := range h.ReadIndexManifest(t, storePath).Manifests {
+			names = append(names, desc.Annotations[layout.ImageRefNameKey])
+		}
+		return names
+	}
+
+	when("#Save", func() {
+		it("keeps the images with other ref names in the layout", func() {
+			saveImage("some-image", "some-value")
+			saveImage("other-image", "other-value")
+
+			h.AssertEq(t, refNames(), []string{"some-image", "other-image"})
+			blobs, err := os.ReadDir(filepath.Join(storePath, "blobs", "sha256"))
+			h.AssertNil(t, err)
+			h.AssertEq(t, len(blobs), 5) // the shared layer, and the config and manifest of each image
+		})
+
+		it("replaces the image with the same ref name", func() {
+			saveImage("some-image", "some-value")
+			saveImage("other-image", "other-value")
+			replacement := saveImage("some-image", "new-value")
+
+			h.AssertEq(t, refNames(), []string{"other-image", "some-image"})
+			digest, err := replacement.Digest()
+			h.AssertNil(t, err)
+			h.AssertEq(t, h.ReadIndexManifest(t, storePath).Manifests[1].Digest, digest)
+		})
+
+		it("saves the image alone in the layout without a ref name", func() {
+			saveImage("some-image", "some-value")
+
+			img, err := layout.NewImage(storePath)
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.Save())
+
+			h.AssertEq(t, refNames(), []string{""})
+		})
+
+		it("considers names as paths if there is no layout before the delimiter", func() {
+			path := filepath.Join(tmpDir, "not-a-store") + ":some-image"
+			img, err := layout.NewImage(path)
+			h.AssertNil(t, err)
+
+			h.AssertNil(t, img.Save())
+
+			h.AssertEq(t, len(h.ReadIndexManifest(t, path).Manifests), 1)
+			h.AssertEq(t, img.Found(), true)
+		})
+
+		it("considers names as paths if the ref name is not valid", func() {
+			path := storePath + ":-some-image"
+			img, err := layout.NewImage(path)
+			h.AssertNil(t, err)
+
+			h.AssertNil(t, img.Save())
+
+			h.AssertEq(t, len(h.ReadIndexManifest(t, path).Manifests), 1)
+			h.AssertEq(t, len(h.ReadIndexManifest(t, storePath).Manifests), 0)
+		})
+
+		it("considers names with a registry port as paths", func() {
+			path := filepath.Join(tmpDir, "localhost:5000", "some-image")
+			img, err := layout.NewImage(path)
+			h.AssertNil(t, err)
+
+			h.AssertNil(t, img.Save())
+
+			h.AssertEq(t, len(h.ReadIndexManifest(t, path).Manifests), 1)
+		})
+	})
+
+	when("#FromBaseImage", func() {
+		it("uses the image with the ref name", func() {
+			saveImage("some-image", "some-value")
+			saveImage("other-image", "other-value")
+
+			img, err := layout.NewImage(filepath.Join(tmpDir, "new-image"), layout.FromBaseImagePath(storePath+":other-image"))
+			h.AssertNil(t, err)
+
+			value, err := img.Label("some-label")
+			h.AssertNil(t, err)
+			h.AssertEq(t, value, "other-value")
+		})
+
+		it("does not find images with other ref names", func() {
+			saveImage("some-image", "some-value")
+
+			img, err := layout.NewImage(storePath+":other-image", layout.FromBaseImagePath(storePath+":other-image"))
+			h.AssertNil(t, err)
+			h.AssertEq(t, img.Found(), false)
+
+			_, err = layout.NewImage(storePath+":other-image", layout.FromBaseImagePath(storePath+":other-image"), imgutil.WithStrictBaseImage())
+			h.AssertEq(t, errors.Is(err, imgutil.ErrImageNotFound), true)
+		})
+	})
+
+	when("#WithPreviousImage", func() {
+		it("reuses layers from the image with the ref name", func() {
+			saveImage("some-image", "some-value")
+			img, err := layout.NewImage(storePath+":some-image", layout.WithPreviousImage(storePath+":some-image"))
+			h.AssertNil(t, err)
+
+			h.AssertNil(t, img.ReuseLayer(h.FileDiffID(t, layerPath)))
+			h.AssertNil(t, img.Save())
+
+			saved, err := layout.NewImage(filepath.Join(tmpDir, "new-image"), layout.FromBaseImagePath(storePath+":some-image"))
+			h.AssertNil(t, err)
+			layers, err := saved.Layers()
+			h.AssertNil(t, err)
+			h.AssertEq(t, len(layers), 1)
+		})
+	})
+
+	when("#Delete", func() {
+		it("removes only the image with the ref name", func() {
+			img := saveImage("some-image", "some-value")
+			saveImage("other-image", "other-value")
+
+			h.AssertNil(t, img.Delete())
+
+			h.AssertEq(t, img.Found(), false)
+			h.AssertEq(t, refNames(), []string{"other-image"})
+		})
+	})
+}
@@ -12,6 +12,11 @@ import (
 
 const ImageRefNameKey = "org.opencontainers.image.ref.name"
 
+// OmittedLayersKey annotates the descriptor of an image in the index with the comma-separated digests of the layers
+// that were intentionally not written to the layout, such as when saving without layers or from sparse images.
+// The manifest is not annotated, so that its digest does not depend on the layers being written.
+const OmittedLayersKey = "io.buildpacks.imgutil.omitted-layers"
+
 // ParseRefToPath parse the given image reference to local path directory following the rules:
 // An image reference refers to either a tag reference or digest reference.
 //   - A tag reference refers to an identifier of form <registry>/<repo>/<image>:<tag>
@@ -92,14 +92,14 @@ func (i *v1ImageFacade) LayerByDiffID(h v1.Hash) (v1.Layer, error) {
 	if layer, ok := i.diffIDMap[h]; ok {
 		return layer, nil
 	}
-	return nil, fmt.Errorf("failed to find layer with diffID %s", h) // shouldn't get here
+	return nil, fmt.Errorf("failed to find layer with diffID %s: %w", h, imgutil.ErrLayerMissing) // shouldn't get here
 }
 
 func (i *v1ImageFacade) LayerByDigest(h v1.Hash) (v1.Layer, error) {
 	if layer, ok := i.digestMap[h]; ok {
 		return layer, nil
 	}
-	return nil, fmt.Errorf("failed to find layer with digest %s", h) // shouldn't get here
+	return nil, fmt.Errorf("failed to find layer with digest %s: %w", h, imgutil.ErrLayerMissing) // shouldn't get here
 }
 
 type v1LayerFacade struct {
@@ -129,9 +129,28 @@ func (l *v1LayerFacade) Size() (int64, error) {
 	return l.size, nil
 }
 
+// WithoutData implements imgutil.LayerWithoutData, as the layer is not present in the layout.
+func (l *v1LayerFacade) WithoutData() bool {
+	return true
+}
+
+// v1LayerWithDiffID is a layer with the DiffID from the config file of its image, rather than the digest of its
+// uncompressed contents, which differ for eStargz layers (see imgutil.WithEstargz).
+type v1LayerWithDiffID struct {
+	v1.Layer
+	diffID v1.Hash
+}
+
+func (l *v1LayerWithDiffID) DiffID() (v1.Hash, error) {
+	return l.diffID, nil
+}
+
 func newLayerOrFacadeFrom(configFile v1.ConfigFile, manifestFile v1.Manifest, layerIndex int, originalLayer v1.Layer) (v1.Layer, error) {
 	if hasData(originalLayer) {
-		return originalLayer, nil
+		if layerIndex >= len(configFile.RootFS.DiffIDs) {
+			return originalLayer, nil
+		}
+		return &v1LayerWithDiffID{Layer: originalLayer, diffID: configFile.RootFS.DiffIDs[layerIndex]}, nil
 	}
 	if layerIndex > len(configFile.RootFS.DiffIDs) {
 		return nil, fmt.Errorf("failed to find layer for index %d in config file", layerIndex)
@@ -148,6 +167,9 @@ func newLayerOrFacadeFrom(configFile v1.ConfigFile, manifestFile v1.Manifest, la
 }
 
 func hasData(layer v1.Layer) bool {
+	if layer, ok := layer.(imgutil.LayerWithoutData); ok && layer.WithoutData() {
+		return false
+	}
 	if rc, err := layer.Compressed(); err == nil {
 		defer rc.Close()
 		return true
@@ -0,0 +1,252 @@
+package layout
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+
+	"github.com/buildpacks/imgutil"
+)
+
+type VerifyOption func(*verifyOptions)
+
+type verifyOptions struct {
+	diffIDs      bool
+	allLayers    bool
+	strictLayers bool
+	sizesOnly    bool
+}
+
+// WithDiffIDVerification also checks that the diff IDs in the config of each image match the uncompressed contents
+// of its layers, which requires reading every layer.
+func WithDiffIDVerification() VerifyOption {
+	return func(o *verifyOptions) {
+		o.diffIDs = true
+	}
+}
+
+// WithAllLayers fails the verification for layers that are not present in the layout,
+// including those recorded as intentionally omitted (see OmittedLayersKey).
+func WithAllLayers() VerifyOption {
+	return func(o *verifyOptions) {
+		o.allLayers = true
+	}
+}
+
+// WithStrictLayers fails the verification for layers that are not present in the layout
+// and were not recorded as intentionally omitted when the image was saved (see OmittedLayersKey).
+// Images of sparse layouts written before omitted layers were recorded fail the verification with this option.
+func WithStrictLayers() VerifyOption {
+	return func(o *verifyOptions) {
+		o.strictLayers = true
+	}
+}
+
+// withSizesOnly checks the blobs by their presence and size only, without reading their contents to check their digest,
+// except for manifests, indexes and configs, which are read anyway.
+func withSizesOnly() VerifyOption {
+	return func(o *verifyOptions) {
+		o.sizesOnly = true
+	}
+}
+
+// VerifyReport describes the blobs checked by Path.Verify.
+type VerifyReport struct {
+	// VerifiedBlobs is the number of blobs found with their declared size and digest.
+	VerifiedBlobs int
+	// AbsentLayers are the digests of the layers that are not present in the layout, sorted.
+	AbsentLayers []string
+}
+
+// Verify checks that every blob reachable from the index of the layout, through nested indexes and image manifests,
+// is present with the size and digest of its descriptor.
+//
+// Layers are left out of sparse layouts, such as those written with WithoutLayers or from sparse base images,
+// so layers that are not present are reported rather than failing the verification,
+// unless WithStrictLayers (for layers not recorded as omitted, see OmittedLayersKey) or WithAllLayers is provided;
+// manifests, indexes and configs must always be present.
+// All the problems found are returned, joined in a single error.
+func (l Path) Verify(ops ...VerifyOption) (VerifyReport, error) {
+	index, err := l.ImageIndex()
+	if err != nil {
+		return VerifyReport{}, fmt.Errorf("failed to load index: %w", err)
+	}
+	manifest, err := index.IndexManifest()
+	if err != nil {
+		return VerifyReport{}, fmt.Errorf("failed to load index: %w", err)
+	}
+	return l.verifyDescriptors(manifest.Manifests, ops...)
+}
+
+// verifyDescriptors is like Verify, for the provided descriptors of the index of the layout only.
+func (l Path) verifyDescriptors(descs []v1.Descriptor, ops ...VerifyOption) (VerifyReport, error) {
+	v := &verifier{
+		path:     l,
+		verified: make(map[v1.Hash]bool),
+		absent:   make(map[v1.Hash]bool),
+	}
+	for _, op := range ops {
+		op(&v.options)
+	}
+	v.verifyDescriptors(descs)
+
+	report := VerifyReport{VerifiedBlobs: len(v.verified)}
+	for digest := range v.absent {
+		report.AbsentLayers = append(report.AbsentLayers, digest.String())
+	}
+	sort.Strings(report.AbsentLayers)
+	return report, errors.Join(v.errs...)
+}
+
+type verifier struct {
+	path     Path
+	options  verifyOptions
+	verified map[v1.Hash]bool
+	absent   map[v1.Hash]bool
+	errs     []error
+}
+
+func (v *verifier) verifyDescriptors(descs []v1.Descriptor) {
+	for _, desc := range descs {
+		switch {
+		case desc.MediaType.IsIndex():
+			var index v1.IndexManifest
+			if v.readBlob(desc, "index", &index) {
+				v.verifyDescriptors(index.Manifests)
+			}
+		case desc.MediaType.IsImage():
+			v.verifyImage(desc)
+		default:
+			v.verifyBlob(desc, "blob")
+		}
+	}
+}
+
+func (v *verifier) verifyImage(desc v1.Descriptor) {
+	var manifest v1.Manifest
+	if !v.readBlob(desc, "manifest", &manifest) {
+		return
+	}
+	var config v1.ConfigFile
+	if !v.readBlob(manifest.Config, "config", &config) {
+		return
+	}
+	if v.options.diffIDs && len(config.RootFS.DiffIDs) != len(manifest.Layers) {
+		v.errs = append(v.errs, fmt.Errorf("manifest %s has %d layers, but its config has %d diff IDs", desc.Digest, len(manifest.Layers), len(config.RootFS.DiffIDs)))
+		return
+	}
+	omitted := make(map[string]bool)
+	if value := desc.Annotations[OmittedLayersKey]; value != "" {
+		for _, digest := range strings.Split(value, ",") {
+			omitted[digest] = true
+		}
+	}
+	for idx, layer := range manifest.Layers {
+		if _, err := os.Stat(v.path.blobPath(layer.Digest)); os.IsNotExist(err) {
+			if v.options.allLayers || (v.options.strictLayers && !omitted[layer.Digest.String()]) {
+				v.errs = append(v.errs, fmt.Errorf("failed to find layer %s of manifest %s: %w", layer.Digest, desc.Digest, imgutil.ErrLayerMissing))
+			} else {
+				v.absent[layer.Digest] = true
+			}
+			continue
+		}
+		if !v.verifyBlob(layer, "layer") || !v.options.diffIDs {
+			continue
+		}
+		diffID, err := v.path.diffIDOf(layer.Digest)
+		if err != nil {
+			v.errs = append(v.errs, fmt.Errorf("failed to read layer %s: %w", layer.Digest, err))
+			continue
+		}
+		if diffID != config.RootFS.DiffIDs[idx] {
+			v.errs = append(v.errs, fmt.Errorf("layer %s has diff ID %s, but the config of manifest %s declares %s", layer.Digest, diffID, desc.Digest, config.RootFS.DiffIDs[idx]))
+		}
+	}
+}
+
+// readBlob verifies the blob of the descriptor and parses it into the provided value,
+// returning false if it could not.
+func (v *verifier) readBlob(desc v1.Descriptor, kind string, value interface{}) bool {
+	if !v.verifyBlob(desc, kind) {
+		return false
+	}
+	contents, err := v.path.Bytes(desc.Digest)
+	if err != nil {
+		v.errs = append(v.errs, fmt.Errorf("failed to read %s %s: %w", kind, desc.Digest, err))
+		return false
+	}
+	if err = json.Unmarshal(contents, value); err != nil {
+		v.errs = append(v.errs, fmt.Errorf("failed to parse %s %s: %w", kind, desc.Digest, err))
+		return false
+	}
+	return true
+}
+
+// verifyBlob checks that the blob of the descriptor is present with the size and digest of the descriptor,
+// returning false if it is not.
+func (v *verifier) verifyBlob(desc v1.Descriptor, kind string) bool {
+	if v.verified[desc.Digest] {
+		return true
+	}
+	if err := v.path.verifyBlob(desc, !v.options.sizesOnly); err != nil {
+		v.errs = append(v.errs, fmt.Errorf("failed to verify %s %s: %w", kind, desc.Digest, err))
+		return false
+	}
+	v.verified[desc.Digest] = true
+	return true
+}
+
+// verifyBlob checks that the blob of the descriptor is present with its size and, if digests is true, its digest.
+func (l Path) verifyBlob(desc v1.Descriptor, digests bool) error {
+	if desc.Digest.Algorithm != "sha256" {
+		return fmt.Errorf("unsupported digest algorithm %q", desc.Digest.Algorithm)
+	}
+	if !digests {
+		info, err := os.Stat(l.blobPath(desc.Digest))
+		if err != nil {
+			return err
+		}
+		if info.Size() != desc.Size {
+			return fmt.Errorf("blob has size %d, expected %d", info.Size(), desc.Size)
+		}
+		return nil
+	}
+	file, err := os.Open(l.blobPath(desc.Digest))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	digest, size, err := v1.SHA256(file)
+	if err != nil {
+		return err
+	}
+	if size != desc.Size {
+		return fmt.Errorf("blob has size %d, expected %d", size, desc.Size)
+	}
+	if digest != desc.Digest {
+		return fmt.Errorf("blob has digest %s", digest)
+	}
+	return nil
+}
+
+// diffIDOf returns the digest of the uncompressed contents of the layer blob.
+func (l Path) diffIDOf(digest v1.Hash) (v1.Hash, error) {
+	layer, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return os.Open(l.blobPath(digest))
+	})
+	if err != nil {
+		return v1.Hash{}, err
+	}
+	return layer.DiffID()
+}
+
+func (l Path) blobPath(digest v1.Hash) string {
+	return l.append("blobs", digest.Algorithm, digest.Hex)
+}
@@ -0,0 +1,247 @@
+package layout_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil"
+	"github.com/buildpacks/imgutil/layout"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestLayoutVerify(t *testing.T) {
+	spec.Run(t, "Verify", testVerify, spec.Sequential(), spec.Report(report.Terminal{}))
+}
+
+func testVerify(t *testing.T, when spec.G, it spec.S) {
+	var (
+		tmpDir    string
+		storePath string
+		layerPath string
+		err       error
+	)
+
+	it.Before(func() {
+		tmpDir, err = os.MkdirTemp("", "layout-verify")
+		h.AssertNil(t, err)
+		storePath = filepath.Join(tmpDir, "store")
+		_, err = layout.Write(storePath, empty.Index)
+		h.AssertNil(t, err)
+		layerPath, err = h.CreateSingleFileLayerTar("/some-file.txt", "some-content", "linux")
+		h.AssertNil(t, err)
+	})
+
+	it.After(func() {
+		os.RemoveAll(tmpDir)
+		os.Remove(layerPath)
+	})
+
+	// saveImage saves an image with a layer to the store under the ref name
+	saveImage := func(refName string, ops ...imgutil.ImageOption) *layout.Image {
+		img, err := layout.NewImage(storePath+":"+refName, ops...)
+		h.AssertNil(t, err)
+		h.AssertNil(t, img.AddLayer(layerPath))
+		h.AssertNil(t, img.Save())
+		return img
+	}
+
+	storeLayout := func() layout.Path {
+		path, err := layout.FromPath(storePath)
+		h.AssertNil(t, err)
+		return path
+	}
+
+	blobPath := func(digest v1.Hash) string {
+		return filepath.Join(storePath, "blobs", digest.Algorithm, digest.Hex)
+	}
+
+	layerDigest := func(img *layout.Image) v1.Hash {
+		manifest, err := img.UnderlyingImage().Manifest()
+		h.AssertNil(t, err)
+		return manifest.Layers[0].Digest
+	}
+
+	when("#Verify", func() {
+		it("verifies the blobs of the images", func() {
+			img := saveImage("some-image")
+
+			verifyReport, err := storeLayout().Verify(layout.WithDiffIDVerification(), layout.WithAllLayers())
+			h.AssertNil(t, err)
+
+			h.AssertEq(t, verifyReport.VerifiedBlobs, 3) // the manifest, config and layer
+			h.AssertEq(t, len(verifyReport.AbsentLayers), 0)
+			h.AssertEq(t, img.Valid(), true)
+		})
+
+		it("reports the layers of sparse images as absent", func() {
+			img := saveImage("some-image", layout.WithoutLayersWhenSaved())
+
+			verifyReport, err := storeLayout().Verify()
+			h.AssertNil(t, err)
+
+			h.AssertEq(t, verifyReport.VerifiedBlobs, 2)
+			h.AssertEq(t, verifyReport.AbsentLayers, []string{layerDigest(img).String()})
+			h.AssertEq(t, img.Valid(), true)
+			h.AssertEqAnnotation(t, h.ReadIndexManifest(t, storePath).Manifests[0], layout.OmittedLayersKey, layerDigest(img).String())
+
+			_, err = storeLayout().Verify(layout.WithAllLayers())
+			h.AssertEq(t, errors.Is(err, imgutil.ErrLayerMissing), true)
+		})
+
+		it("reports the missing layers that were not recorded as omitted as absent", func() {
+			img := saveImage("some-image")
+			h.AssertNil(t, os.Remove(blobPath(layerDigest(img))))
+
+			verifyReport, err := storeLayout().Verify()
+			h.AssertNil(t, err)
+
+			h.AssertEq(t, verifyReport.AbsentLayers, []string{layerDigest(img).String()})
+			h.AssertEq(t, img.Valid(), true)
+		})
+
+		when("#WithStrictLayers", func() {
+			it("fails if a layer that was not recorded as omitted is missing", func() {
+				img := saveImage("some-image")
+				h.AssertNil(t, os.Remove(blobPath(layerDigest(img))))
+
+				verifyReport, err := storeLayout().Verify(layout.WithStrictLayers())
+				h.AssertEq(t, errors.Is(err, imgutil.ErrLayerMissing), true)
+				h.AssertError(t, err, "failed to find layer "+layerDigest(img).String())
+				h.AssertEq(t, len(verifyReport.AbsentLayers), 0)
+			})
+
+			it("accepts the layers omitted by sparse images that were present in the layout when saved", func() {
+				img := saveImage("some-image")
+				saveImage("sparse-image", layout.WithoutLayersWhenSaved())
+				h.AssertNil(t, img.Delete())
+				h.AssertNil(t, os.Remove(blobPath(layerDigest(img)))) // e.g., collected along with the other image
+
+				verifyReport, err := storeLayout().Verify(layout.WithStrictLayers())
+				h.AssertNil(t, err)
+
+				h.AssertEq(t, verifyReport.AbsentLayers, []string{layerDigest(img).String()})
+			})
+
+			it("reports the layers recorded as omitted as absent", func() {
+				img := saveImage("some-image", layout.WithoutLayersWhenSaved())
+
+				verifyReport, err := storeLayout().Verify(layout.WithStrictLayers())
+				h.AssertNil(t, err)
+
+				h.AssertEq(t, verifyReport.AbsentLayers, []string{layerDigest(img).String()})
+			})
+		})
+
+		it("fails if a blob does not have its declared digest", func() {
+			img := saveImage("some-image")
+			contents, err := os.ReadFile(blobPath(layerDigest(img)))
+			h.AssertNil(t, err)
+			contents[len(contents)-1]++
+			h.AssertNil(t, os.WriteFile(blobPath(layerDigest(img)), contents, 0600))
+
+			_, err = storeLayout().Verify()
+			h.AssertError(t, err, "failed to verify layer "+layerDigest(img).String()+": blob has digest")
+			h.AssertEq(t, img.Valid(), true) // only the size is checked
+		})
+
+		it("fails if a blob does not have its declared size", func() {
+			img := saveImage("some-image")
+			h.AssertNil(t, os.Truncate(blobPath(layerDigest(img)), 10))
+
+			_, err = storeLayout().Verify()
+			h.AssertError(t, err, "blob has size 10")
+			h.AssertEq(t, img.Valid(), false)
+		})
+
+		it("fails if a config is missing", func() {
+			img := saveImage("some-image")
+			configName, err := img.ConfigName()
+			h.AssertNil(t, err)
+			h.AssertNil(t, os.Remove(blobPath(configName)))
+
+			_, err = storeLayout().Verify()
+			h.AssertError(t, err, "failed to verify config "+configName.String())
+			h.AssertEq(t, errors.Is(err, os.ErrNotExist), true)
+			h.AssertEq(t, img.Valid(), false)
+		})
+
+		it("reports the problems of every image", func() {
+			var configNames []string
+			for _, refName := range []string{"some-image", "other-image"} {
+				img, err := layout.NewImage(storePath + ":" + refName)
+				h.AssertNil(t, err)
+				h.AssertNil(t, img.SetLabel("some-label", refName))
+				h.AssertNil(t, img.Save())
+				configName, err := img.ConfigName()
+				h.AssertNil(t, err)
+				h.AssertNil(t, os.Remove(blobPath(configName)))
+				configNames = append(configNames, configName.String())
+			}
+
+			_, err = storeLayout().Verify()
+			for _, configName := range configNames {
+				h.AssertError(t, err, "failed to verify config "+configName)
+			}
+		})
+
+		when("#WithDiffIDVerification", func() {
+			it("fails if the diff IDs of the config do not match the layers", func() {
+				image, err := random.Image(1024, 1)
+				h.AssertNil(t, err)
+				configFile, err := image.ConfigFile()
+				h.AssertNil(t, err)
+				configFile = configFile.DeepCopy()
+				configFile.RootFS.DiffIDs[0] = v1.Hash{Algorithm: "sha256", Hex: "0123456789012345678901234567890123456789012345678901234567890123"}
+				mismatched, err := mutate.ConfigFile(image, configFile)
+				h.AssertNil(t, err)
+				path, err := layout.Write(storePath, empty.Index)
+				h.AssertNil(t, err)
+				layers, err := image.Layers()
+				h.AssertNil(t, err)
+				layerHash, err := layers[0].Digest()
+				h.AssertNil(t, err)
+				compressed, err := layers[0].Compressed()
+				h.AssertNil(t, err)
+				h.AssertNil(t, path.WriteBlob(layerHash, compressed))
+				rawConfig, err := mismatched.RawConfigFile()
+				h.AssertNil(t, err)
+				configName, err := mismatched.ConfigName()
+				h.AssertNil(t, err)
+				h.AssertNil(t, path.WriteBlob(configName, io.NopCloser(bytes.NewReader(rawConfig))))
+				rawManifest, err := mismatched.RawManifest()
+				h.AssertNil(t, err)
+				digest, err := mismatched.Digest()
+				h.AssertNil(t, err)
+				h.AssertNil(t, path.WriteBlob(digest, io.NopCloser(bytes.NewReader(rawManifest))))
+				h.AssertNil(t, path.AppendDescriptor(v1.Descriptor{MediaType: types.OCIManifestSchema1, Size: int64(len(rawManifest)), Digest: digest}))
+
+				_, err = path.Verify()
+				h.AssertNil(t, err)
+
+				_, err = path.Verify(layout.WithDiffIDVerification())
+				h.AssertError(t, err, "but the config of manifest")
+			})
+		})
+	})
+
+	when("#Valid", func() {
+		it("returns false if the image is not saved", func() {
+			img, err := layout.NewImage(storePath + ":some-image")
+			h.AssertNil(t, err)
+
+			h.AssertEq(t, img.Valid(), false)
+		})
+	})
+}
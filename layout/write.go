@@ -2,11 +2,13 @@ package layout
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/google/go-containerregistry/pkg/logs"
 	"github.com/google/go-containerregistry/pkg/v1/stream"
@@ -14,6 +16,9 @@ import (
 
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/match"
+
+	"github.com/buildpacks/imgutil"
 )
 
 type AppendOption func(*appendOptions)
@@ -21,6 +26,8 @@ type AppendOption func(*appendOptions)
 type appendOptions struct {
 	withoutLayers bool
 	annotations   map[string]string
+	progress      imgutil.ProgressFunc
+	refName       string
 }
 
 func WithoutLayers() AppendOption {
@@ -35,6 +42,22 @@ func WithAnnotations(annotations map[string]string) AppendOption {
 	}
 }
 
+// WithLayerProgress reports the bytes written for each layer blob to the provided function.
+// Blobs that already exist in the layout are not reported.
+func WithLayerProgress(f imgutil.ProgressFunc) AppendOption {
+	return func(i *appendOptions) {
+		i.progress = f
+	}
+}
+
+// WithRefName annotates the descriptor of the image with the provided ref name (see ImageRefNameKey),
+// replacing the descriptors in the index that have the same ref name.
+func WithRefName(refName string) AppendOption {
+	return func(i *appendOptions) {
+		i.refName = refName
+	}
+}
+
 // AppendImage mimics GGCR's AppendImage in that it appends an image to a `layout.Path`,
 // but the image appended does not include any layers in the `blobs` directory.
 // The returned image will return layers when Layers(), LayerByDiffID(), or LayerByDigest() are called,
@@ -46,22 +69,38 @@ func (l Path) AppendImage(img v1.Image, ops ...AppendOption) error {
 		op(o)
 	}
 	annotations := map[string]string{}
-	if o.annotations != nil {
-		annotations = o.annotations
+	for k, v := range o.annotations {
+		annotations[k] = v
+	}
+	if o.refName != "" {
+		annotations[ImageRefNameKey] = o.refName
 	}
 
 	if o.withoutLayers {
-		return l.writeImageWithoutLayers(img, annotations)
+		manifest, err := img.Manifest()
+		if err != nil {
+			return err
+		}
+		var omitted []string
+		for _, layer := range manifest.Layers {
+			omitted = append(omitted, layer.Digest.String())
+		}
+		return l.writeImageWithoutLayers(img, annotations, o.refName, omitted)
 	}
-	return l.appendImage(img, annotations)
+	return l.appendImage(img, annotations, o.refName, o.progress)
 }
 
-// writeImageWithoutLayers is the same implementation of ggcr layout writeImage method, removing the writeLayer code
-func (l Path) writeImageWithoutLayers(img v1.Image, annotations map[string]string) error {
+// writeImageWithoutLayers is the same implementation of ggcr layout writeImage method, removing the writeLayer code.
+// The digests of the layers that were omitted are recorded in the descriptor of the image (see OmittedLayersKey).
+func (l Path) writeImageWithoutLayers(img v1.Image, annotations map[string]string, refName string, omitted []string) error {
 	if err := l.writeImage(img); err != nil {
 		return err
 	}
 
+	if len(omitted) > 0 {
+		annotations[OmittedLayersKey] = strings.Join(omitted, ",")
+	}
+
 	mt, err := img.MediaType()
 	if err != nil {
 		return err
@@ -83,28 +122,67 @@ func (l Path) writeImageWithoutLayers(img v1.Image, annotations map[string]strin
 		Digest:      d,
 		Annotations: annotations,
 	}
+	if refName != "" {
+		return l.replaceDescriptors(match.Annotation(ImageRefNameKey, refName), desc)
+	}
 	return l.AppendDescriptor(desc)
 }
 
-func (l Path) appendImage(img v1.Image, annotations map[string]string) error {
+// replaceDescriptors replaces the descriptors of the index that match with the provided descriptor,
+// rewriting `index.json` once, so that it holds either the replaced descriptors or the new one at any time.
+func (l Path) replaceDescriptors(matcher match.Matcher, desc v1.Descriptor) error {
+	index, err := l.ImageIndex()
+	if err != nil {
+		return err
+	}
+	indexManifest, err := index.IndexManifest()
+	if err != nil {
+		return err
+	}
+	var manifests []v1.Descriptor
+	for _, existing := range indexManifest.Manifests {
+		if !matcher(existing) {
+			manifests = append(manifests, existing)
+		}
+	}
+	indexManifest.Manifests = append(manifests, desc)
+	rawIndex, err := json.MarshalIndent(indexManifest, "", "   ")
+	if err != nil {
+		return err
+	}
+	return l.WriteFile("index.json", rawIndex, os.ModePerm)
+}
+
+func (l Path) appendImage(img v1.Image, annotations map[string]string, refName string, withProgress imgutil.ProgressFunc) error {
 	layers, err := img.Layers()
 	if err != nil {
 		return err
 	}
 
-	// Write the layers concurrently.
-	var g errgroup.Group
+	// Write the layers concurrently, omitting those without data (e.g., from sparse images).
+	var (
+		g       errgroup.Group
+		omitted []string
+	)
 	for _, layer := range layers {
 		layer := layer
+		if !hasData(layer) {
+			digest, err := layer.Digest()
+			if err != nil {
+				return err
+			}
+			omitted = append(omitted, digest.String())
+			continue
+		}
 		g.Go(func() error {
-			return l.writeLayer(layer)
+			return l.writeLayer(layer, withProgress)
 		})
 	}
 	if err := g.Wait(); err != nil {
 		return err
 	}
 
-	return l.writeImageWithoutLayers(img, annotations)
+	return l.writeImageWithoutLayers(img, annotations, refName, omitted)
 }
 
 func (l Path) writeImage(img v1.Image) error {
@@ -154,7 +232,7 @@ func FromPath(path string) (Path, error) {
 
 // writeLayer is the same internal implementation from ggcr layout package, but because it is calling an internal
 // writeBlob method we need to override we copied here.
-func (l Path) writeLayer(layer v1.Layer) error {
+func (l Path) writeLayer(layer v1.Layer, withProgress imgutil.ProgressFunc) error {
 	d, err := layer.Digest()
 
 	if errors.Is(err, stream.ErrNotComputed) {
@@ -186,6 +264,7 @@ func (l Path) writeLayer(layer v1.Layer) error {
 		return err
 	}
 
+	r = imgutil.NewProgressReadCloser(r, d.String(), s, withProgress)
 	if err := l.writeBlob(d, s, r, layer.Digest); err != nil {
 		return fmt.Errorf("error writing layer: %w", err)
 	}
@@ -270,3 +349,75 @@ func (l Path) writeBlob(hash v1.Hash, size int64, rc io.ReadCloser, renamer func
 	renamePath := l.append("blobs", finalHash.Algorithm, finalHash.Hex)
 	return os.Rename(w.Name(), renamePath)
 }
+
+// writeIndex writes the blobs of every manifest referenced by the given index,
+// and then replaces `index.json` with the given index, so that the layout holds exactly that index.
+// Layers without data (e.g. from sparse images) are skipped.
+func (l Path) writeIndex(ii v1.ImageIndex) error {
+	if err := l.writeIndexChildren(ii); err != nil {
+		return err
+	}
+	rawIndex, err := ii.RawManifest()
+	if err != nil {
+		return err
+	}
+	return l.WriteFile("index.json", rawIndex, os.ModePerm)
+}
+
+// writeIndexChildren writes the blobs of every manifest referenced by the given index,
+// including nested indexes and their manifests, skipping the layers without data.
+func (l Path) writeIndexChildren(ii v1.ImageIndex) error {
+	indexManifest, err := ii.IndexManifest()
+	if err != nil {
+		return err
+	}
+	for _, desc := range indexManifest.Manifests {
+		switch {
+		case desc.MediaType.IsImage():
+			img, err := ii.Image(desc.Digest)
+			if err != nil {
+				return err
+			}
+			if err = l.writeImageWithAvailableLayers(img); err != nil {
+				return err
+			}
+		case desc.MediaType.IsIndex():
+			child, err := ii.ImageIndex(desc.Digest)
+			if err != nil {
+				return err
+			}
+			if err = l.writeIndexChildren(child); err != nil {
+				return err
+			}
+			rawChild, err := child.RawManifest()
+			if err != nil {
+				return err
+			}
+			if err = l.WriteBlob(desc.Digest, io.NopCloser(bytes.NewReader(rawChild))); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (l Path) writeImageWithAvailableLayers(img v1.Image) error {
+	layers, err := img.Layers()
+	if err != nil {
+		return err
+	}
+	var g errgroup.Group
+	for _, layer := range layers {
+		layer := layer
+		if !hasData(layer) {
+			continue
+		}
+		g.Go(func() error {
+			return l.writeLayer(layer, nil)
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return err
+	}
+	return l.writeImage(img)
+}
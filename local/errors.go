@@ -0,0 +1,26 @@
+package local
+
+import (
+	"fmt"
+
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+
+	"github.com/buildpacks/imgutil"
+)
+
+// daemonError wraps the provided error from a daemon operation with the corresponding imgutil error, if any.
+func daemonError(err error) error {
+	if err == nil {
+		return nil
+	}
+	switch {
+	case client.IsErrConnectionFailed(err):
+		return fmt.Errorf("%w: %w", imgutil.ErrDaemonUnavailable, err)
+	case client.IsErrNotFound(err):
+		return fmt.Errorf("%w: %w", imgutil.ErrImageNotFound, err)
+	case errdefs.IsUnauthorized(err), errdefs.IsForbidden(err):
+		return fmt.Errorf("%w: %w", imgutil.ErrUnauthorized, err)
+	}
+	return err
+}
@@ -1,6 +1,7 @@
 package local
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
@@ -15,9 +16,12 @@ import (
 	"github.com/buildpacks/imgutil"
 )
 
+var _ imgutil.ImageWithContext = (*Image)(nil)
+
 // Image wraps an imgutil.CNBImageCore and implements the methods needed to complete the imgutil.Image interface.
 type Image struct {
 	*imgutil.CNBImageCore
+	ctx            context.Context
 	repoName       string
 	store          *Store
 	lastIdentifier string
@@ -40,6 +44,11 @@ func (i *Image) Found() bool {
 	return i.lastIdentifier != ""
 }
 
+// FoundWithContext is like Found; it does not make any daemon calls.
+func (i *Image) FoundWithContext(_ context.Context) bool {
+	return i.Found()
+}
+
 func (i *Image) Identifier() (imgutil.Identifier, error) {
 	return IDIdentifier{
 		ImageID: strings.TrimPrefix(i.lastIdentifier, "sha256:"),
@@ -54,6 +63,15 @@ func (i *Image) Valid() bool {
 // GetLayer returns an io.ReadCloser with uncompressed layer data.
 // The layer will always have data, even if that means downloading ALL the image layers from the daemon.
 func (i *Image) GetLayer(diffID string) (io.ReadCloser, error) {
+	return i.GetLayerWithContext(i.ctx, diffID)
+}
+
+// GetLayerWithContext is like GetLayer, but returns an error if the context is done
+// and returns a reader that fails once the context is done.
+func (i *Image) GetLayerWithContext(ctx context.Context, diffID string) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	layerHash, err := v1.NewHash(diffID)
 	if err != nil {
 		return nil, err
@@ -71,17 +89,25 @@ func (i *Image) GetLayer(diffID string) (io.ReadCloser, error) {
 		// if the layer is available locally
 		// (e.g., it was added using AddLayer).
 		if size, err := layer.Size(); err != nil && size != -1 {
-			return layer.Uncompressed()
+			rc, err := layer.Uncompressed()
+			if err != nil {
+				return nil, err
+			}
+			return imgutil.NewContextReadCloser(ctx, rc), nil
 		}
 	}
-	if err = i.ensureLayers(); err != nil {
+	if err = i.ensureLayers(ctx); err != nil {
 		return nil, err
 	}
 	layer, err = i.LayerByDiffID(layerHash)
 	if err != nil {
 		return nil, err
 	}
-	return layer.Uncompressed()
+	rc, err := layer.Uncompressed()
+	if err != nil {
+		return nil, err
+	}
+	return imgutil.NewContextReadCloser(ctx, rc), nil
 }
 
 func contains(diffIDs []v1.Hash, hash v1.Hash) bool {
@@ -93,8 +119,8 @@ func contains(diffIDs []v1.Hash, hash v1.Hash) bool {
 	return false
 }
 
-func (i *Image) ensureLayers() error {
-	if err := i.store.downloadLayersFor(i.lastIdentifier); err != nil {
+func (i *Image) ensureLayers(ctx context.Context) error {
+	if err := i.store.downloadLayersFor(ctx, i.lastIdentifier); err != nil {
 		return fmt.Errorf("failed to fetch base layers: %w", err)
 	}
 	return nil
@@ -183,34 +209,45 @@ func (i *Image) AddOrReuseLayerWithHistory(path string, diffID string, history v
 }
 
 func (i *Image) Rebase(baseTopLayerDiffID string, withNewBase imgutil.Image) error {
-	if err := i.ensureLayers(); err != nil {
+	return i.RebaseWithContext(i.ctx, baseTopLayerDiffID, withNewBase)
+}
+
+func (i *Image) RebaseWithContext(ctx context.Context, baseTopLayerDiffID string, withNewBase imgutil.Image) error {
+	if err := i.ensureLayers(ctx); err != nil {
 		return err
 	}
 	return i.CNBImageCore.Rebase(baseTopLayerDiffID, withNewBase)
 }
 
 func (i *Image) Save(additionalNames ...string) error {
-	err := i.SetCreatedAtAndHistory()
-	if err != nil {
-		return err
-	}
-	i.lastIdentifier, err = i.store.Save(i, i.Name(), additionalNames...)
-	return err
+	return i.SaveAsWithContext(i.ctx, i.Name(), additionalNames...)
+}
+
+func (i *Image) SaveWithContext(ctx context.Context, additionalNames ...string) error {
+	return i.SaveAsWithContext(ctx, i.Name(), additionalNames...)
 }
 
 func (i *Image) SaveAs(name string, additionalNames ...string) error {
+	return i.SaveAsWithContext(i.ctx, name, additionalNames...)
+}
+
+func (i *Image) SaveAsWithContext(ctx context.Context, name string, additionalNames ...string) error {
 	err := i.SetCreatedAtAndHistory()
 	if err != nil {
 		return err
 	}
-	i.lastIdentifier, err = i.store.Save(i, name, additionalNames...)
+	i.lastIdentifier, err = i.store.save(ctx, i, name, additionalNames...)
 	return err
 }
 
 func (i *Image) SaveFile() (string, error) {
-	return i.store.SaveFile(i, i.Name())
+	return i.store.saveFile(i.ctx, i, i.Name())
 }
 
 func (i *Image) Delete() error {
-	return i.store.Delete(i.lastIdentifier)
+	return i.DeleteWithContext(i.ctx)
+}
+
+func (i *Image) DeleteWithContext(ctx context.Context) error {
+	return i.store.delete(ctx, i.lastIdentifier)
 }
@@ -3,6 +3,7 @@ package local_test
 import (
 	"archive/tar"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -19,6 +20,7 @@ import (
 
 	"github.com/buildpacks/imgutil"
 	local "github.com/buildpacks/imgutil/local"
+	"github.com/buildpacks/imgutil/local/localtest"
 	"github.com/buildpacks/imgutil/remote"
 	h "github.com/buildpacks/imgutil/testhelpers"
 )
@@ -188,6 +190,18 @@ func testImage(t *testing.T, when spec.G, it spec.S) {
 							h.AssertError(t, err, "has no layers")
 						}
 					})
+
+					it("returns an error with #WithStrictBaseImage", func() {
+						_, err := local.NewImage(
+							newTestImageName(),
+							dockerClient,
+							local.FromBaseImage("some-bad-repo-name"),
+							imgutil.WithStrictBaseImage(),
+						)
+
+						h.AssertError(t, err, `failed to find image "some-bad-repo-name" in the daemon`)
+						h.AssertEq(t, errors.Is(err, imgutil.ErrImageNotFound), true)
+					})
 				})
 
 				when("base image and daemon os/architecture match", func() {
@@ -391,6 +405,18 @@ func testImage(t *testing.T, when spec.G, it spec.S) {
 
 					h.AssertNil(t, err)
 				})
+
+				it("returns an error with #WithStrictPreviousImage", func() {
+					_, err := local.NewImage(
+						newTestImageName(),
+						dockerClient,
+						local.WithPreviousImage("some-bad-repo-name"),
+						imgutil.WithStrictPreviousImage(),
+					)
+
+					h.AssertError(t, err, `failed to find image "some-bad-repo-name" in the daemon`)
+					h.AssertEq(t, errors.Is(err, imgutil.ErrImageNotFound), true)
+				})
 			})
 		})
 
@@ -432,6 +458,39 @@ func testImage(t *testing.T, when spec.G, it spec.S) {
 				})
 			})
 		})
+
+		when("the platform does not match the daemon os", func() {
+			it("returns imgutil.ErrPlatformMismatch", func() {
+				fakeClient := localtest.NewDockerClient()
+				fakeClient.SetPlatform("linux", "amd64")
+
+				_, err := local.NewImage(newTestImageName(), fakeClient, local.WithDefaultPlatform(imgutil.Platform{OS: "windows", Architecture: "amd64"}))
+				h.AssertError(t, err, `platform os "windows" must match the daemon os "linux"`)
+				h.AssertEq(t, errors.Is(err, imgutil.ErrPlatformMismatch), true)
+			})
+
+			it("accepts other architectures", func() {
+				fakeClient := localtest.NewDockerClient()
+				fakeClient.SetPlatform("linux", "amd64")
+
+				img, err := local.NewImage(newTestImageName(), fakeClient, local.WithDefaultPlatform(imgutil.Platform{OS: "linux", Architecture: "arm64"}))
+				h.AssertNil(t, err)
+
+				arch, err := img.Architecture()
+				h.AssertNil(t, err)
+				h.AssertEq(t, arch, "arm64")
+			})
+		})
+
+		when("the daemon is unavailable", func() {
+			it("returns imgutil.ErrDaemonUnavailable", func() {
+				fakeClient := localtest.NewDockerClient()
+				fakeClient.SetUnavailable(true)
+
+				_, err := local.NewImage(newTestImageName(), fakeClient)
+				h.AssertEq(t, errors.Is(err, imgutil.ErrDaemonUnavailable), true)
+			})
+		})
 	})
 
 	when("#Labels", func() {
@@ -1855,6 +1914,18 @@ func testImage(t *testing.T, when spec.G, it spec.S) {
 	})
 
 	when("#Save", func() {
+		when("the daemon is unavailable", func() {
+			it("returns imgutil.ErrDaemonUnavailable", func() {
+				fakeClient := localtest.NewDockerClient()
+				img, err := local.NewImage(newTestImageName(), fakeClient)
+				h.AssertNil(t, err)
+				fakeClient.SetUnavailable(true)
+
+				err = img.Save()
+				h.AssertEq(t, errors.Is(err, imgutil.ErrDaemonUnavailable), true)
+			})
+		})
+
 		when("image is valid", func() {
 			var (
 				img      imgutil.Image
@@ -2189,6 +2260,82 @@ func testImage(t *testing.T, when spec.G, it spec.S) {
 		})
 	})
 
+	when("#WithContext", func() {
+		var fakeClient *localtest.DockerClient
+
+		it.Before(func() {
+			fakeClient = localtest.NewDockerClient()
+		})
+
+		when("the construction context is done", func() {
+			it("returns an error when calling the daemon", func() {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+
+				_, err := local.NewImage(newTestImageName(), fakeClient, imgutil.WithContext(ctx))
+				h.AssertError(t, err, context.Canceled.Error())
+			})
+		})
+
+		when("the context provided to a method is done", func() {
+			it("does not save the image", func() {
+				img, err := local.NewImage(newTestImageName(), fakeClient)
+				h.AssertNil(t, err)
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+
+				h.AssertError(t, img.SaveWithContext(ctx), context.Canceled.Error())
+				h.AssertEq(t, img.Found(), false)
+
+				h.AssertNil(t, img.Save())
+				h.AssertEq(t, img.Found(), true)
+			})
+
+			when("#GetLayerWithContext", func() {
+				var (
+					baseImageName string
+					layerDiffID   string
+				)
+
+				it.Before(func() {
+					baseImageName = newTestImageName()
+					layerPath, err := h.CreateSingleFileLayerTar("/some-file.txt", "some-content", "linux")
+					h.AssertNil(t, err)
+					defer os.Remove(layerPath)
+					baseImage, err := local.NewImage(baseImageName, fakeClient)
+					h.AssertNil(t, err)
+					h.AssertNil(t, baseImage.AddLayer(layerPath))
+					h.AssertNil(t, baseImage.Save())
+					layerDiffID, err = baseImage.TopLayer()
+					h.AssertNil(t, err)
+				})
+
+				it("returns an error", func() {
+					img, err := local.NewImage(newTestImageName(), fakeClient, local.FromBaseImage(baseImageName))
+					h.AssertNil(t, err)
+					ctx, cancel := context.WithCancel(context.Background())
+					cancel()
+
+					_, err = img.GetLayerWithContext(ctx, layerDiffID)
+					h.AssertError(t, err, context.Canceled.Error())
+				})
+
+				it("returns a reader that fails once the context is done", func() {
+					img, err := local.NewImage(newTestImageName(), fakeClient, local.FromBaseImage(baseImageName))
+					h.AssertNil(t, err)
+					ctx, cancel := context.WithCancel(context.Background())
+					rc, err := img.GetLayerWithContext(ctx, layerDiffID)
+					h.AssertNil(t, err)
+					defer rc.Close()
+
+					cancel()
+					_, err = io.ReadAll(rc)
+					h.AssertError(t, err, context.Canceled.Error())
+				})
+			})
+		})
+	})
+
 	when("#Delete", func() {
 		when("the image does not exist", func() {
 			it("should not error", func() {
@@ -0,0 +1,613 @@
+// Package localtest provides an in-memory fake of local.DockerClient,
+// so that images from the local package can be tested without a docker daemon.
+package localtest
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/system"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+
+	"github.com/buildpacks/imgutil/local"
+)
+
+var _ local.DockerClient = (*DockerClient)(nil)
+
+const containerdSnapshotter = "io.containerd.snapshotter.v1"
+
+// DockerClient is an in-memory fake of a docker daemon.
+// It loads images from docker-save tars, keeps images by ID and tag, and answers inspect, history and save requests
+// the way the daemon does.
+// Like the daemon with its classic storage, it accepts tars that omit (as empty files) layers it already has,
+// unless it emulates the containerd snapshotter (see SetContainerdStorage).
+// Image IDs are always the digest of the image config.
+// It is safe for concurrent use.
+type DockerClient struct {
+	mutex             sync.Mutex
+	images            map[string]*storedImage // by ID (e.g., "sha256:...")
+	tags              map[string]string       // tag reference (e.g., "index.docker.io/some/repo:latest") to ID
+	layers            map[v1.Hash][]byte      // uncompressed layer tars by diff ID
+	os                string
+	architecture      string
+	containerdStorage bool
+	unavailable       bool
+}
+
+type storedImage struct {
+	id         string
+	rawConfig  []byte
+	configFile *v1.ConfigFile
+}
+
+// NewDockerClient returns a fake daemon without images, for the linux/amd64 platform, using the classic storage.
+func NewDockerClient() *DockerClient {
+	return &DockerClient{
+		images:       make(map[string]*storedImage),
+		tags:         make(map[string]string),
+		layers:       make(map[v1.Hash][]byte),
+		os:           "linux",
+		architecture: "amd64",
+	}
+}
+
+// SetPlatform sets the os and architecture reported by the fake daemon.
+func (c *DockerClient) SetPlatform(os, architecture string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.os = os
+	c.architecture = architecture
+}
+
+// SetContainerdStorage toggles the emulation of the containerd snapshotter:
+// when enabled, the reported `DriverStatus` says that the containerd snapshotter is used,
+// and loading a tar that omits layers fails.
+func (c *DockerClient) SetContainerdStorage(enabled bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.containerdStorage = enabled
+}
+
+// SetUnavailable toggles the emulation of a daemon that cannot be reached:
+// when enabled, every request fails with the error returned by the docker client when the connection fails.
+func (c *DockerClient) SetUnavailable(unavailable bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.unavailable = unavailable
+}
+
+// available returns an error if the context is done or if the fake daemon cannot be reached.
+func (c *DockerClient) available(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.unavailable {
+		return client.ErrorConnectionFailed("")
+	}
+	return nil
+}
+
+func (c *DockerClient) Info(ctx context.Context) (system.Info, error) {
+	if err := c.available(ctx); err != nil {
+		return system.Info{}, err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	info := system.Info{
+		OSType:       c.os,
+		Architecture: c.architecture,
+		Driver:       "overlay2",
+		DriverStatus: [][2]string{{"Backing Filesystem", "extfs"}},
+	}
+	if c.containerdStorage {
+		info.Driver = "overlayfs"
+		info.DriverStatus = [][2]string{{"driver-type", containerdSnapshotter}}
+	}
+	return info, nil
+}
+
+func (c *DockerClient) ServerVersion(ctx context.Context) (types.Version, error) {
+	if err := c.available(ctx); err != nil {
+		return types.Version{}, err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return types.Version{
+		Os:   c.os,
+		Arch: c.architecture,
+	}, nil
+}
+
+// images
+
+func (c *DockerClient) ImageInspectWithRaw(ctx context.Context, ref string) (types.ImageInspect, []byte, error) {
+	if err := c.available(ctx); err != nil {
+		return types.ImageInspect{}, nil, err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	img, err := c.find(ref)
+	if err != nil {
+		return types.ImageInspect{}, nil, err
+	}
+	inspect, err := c.inspect(img)
+	if err != nil {
+		return types.ImageInspect{}, nil, err
+	}
+	raw, err := json.Marshal(inspect)
+	if err != nil {
+		return types.ImageInspect{}, nil, err
+	}
+	return inspect, raw, nil
+}
+
+func (c *DockerClient) inspect(img *storedImage) (types.ImageInspect, error) {
+	config, err := toDockerConfig(img.configFile.Config)
+	if err != nil {
+		return types.ImageInspect{}, err
+	}
+	var (
+		layers []string
+		size   int64
+	)
+	for _, diffID := range img.configFile.RootFS.DiffIDs {
+		layers = append(layers, diffID.String())
+		size += int64(len(c.layers[diffID]))
+	}
+	var created string
+	if !img.configFile.Created.IsZero() {
+		created = img.configFile.Created.UTC().Format(time.RFC3339Nano)
+	}
+	return types.ImageInspect{
+		ID:            img.id,
+		RepoTags:      c.repoTagsFor(img.id),
+		RepoDigests:   []string{},
+		Created:       created,
+		Container:     img.configFile.Container,
+		DockerVersion: img.configFile.DockerVersion,
+		Author:        img.configFile.Author,
+		Config:        config,
+		Architecture:  img.configFile.Architecture,
+		Variant:       img.configFile.Variant,
+		Os:            img.configFile.OS,
+		OsVersion:     img.configFile.OSVersion,
+		Size:          size,
+		RootFS: types.RootFS{
+			Type:   "layers",
+			Layers: layers,
+		},
+	}, nil
+}
+
+// toDockerConfig converts the config through JSON, as the daemon types use the same JSON fields as the v1 types.
+func toDockerConfig(config v1.Config) (*container.Config, error) {
+	raw, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	dockerConfig := &container.Config{}
+	if err = json.Unmarshal(raw, dockerConfig); err != nil {
+		return nil, err
+	}
+	return dockerConfig, nil
+}
+
+func (c *DockerClient) ImageHistory(ctx context.Context, ref string) ([]image.HistoryResponseItem, error) {
+	if err := c.available(ctx); err != nil {
+		return nil, err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	img, err := c.find(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	// the daemon reports history in reverse order, with the image ID and tags on the most recent entry
+	var (
+		history  []image.HistoryResponseItem
+		layerIdx int
+	)
+	for _, h := range img.configFile.History {
+		var size int64
+		if !h.EmptyLayer && layerIdx < len(img.configFile.RootFS.DiffIDs) {
+			size = int64(len(c.layers[img.configFile.RootFS.DiffIDs[layerIdx]]))
+			layerIdx++
+		}
+		history = append([]image.HistoryResponseItem{{
+			ID:        "<missing>",
+			Created:   h.Created.Unix(),
+			CreatedBy: h.CreatedBy,
+			Comment:   h.Comment,
+			Size:      size,
+		}}, history...)
+	}
+	if len(history) > 0 {
+		history[0].ID = img.id
+		history[0].Tags = c.repoTagsFor(img.id)
+	}
+	return history, nil
+}
+
+func (c *DockerClient) ImageTag(ctx context.Context, source, target string) error {
+	if err := c.available(ctx); err != nil {
+		return err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	img, err := c.find(source)
+	if err != nil {
+		return err
+	}
+	tag, err := name.NewTag(target, name.WeakValidation)
+	if err != nil {
+		return fmt.Errorf("invalid reference format: %w", err)
+	}
+	c.tags[tag.Name()] = img.id
+	return nil
+}
+
+// ImageRemove removes the tag if the image is referenced by a tag and has other tags;
+// otherwise it removes the image and all its tags.
+// Layers are kept, as they may be shared with other images.
+func (c *DockerClient) ImageRemove(ctx context.Context, ref string, options image.RemoveOptions) ([]image.DeleteResponse, error) {
+	if err := c.available(ctx); err != nil {
+		return nil, err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	img, err := c.find(ref)
+	if err != nil {
+		return nil, err
+	}
+	tags := c.tagsFor(img.id)
+	if tag, ok := c.tagFor(ref); ok && len(tags) > 1 {
+		delete(c.tags, tag)
+		return []image.DeleteResponse{{Untagged: familiarName(tag)}}, nil
+	}
+	if len(tags) > 1 && !options.Force {
+		return nil, fmt.Errorf("conflict: unable to delete %s (must be forced) - image is referenced in multiple repositories", ref)
+	}
+	var deleted []image.DeleteResponse
+	for _, tag := range tags {
+		delete(c.tags, tag)
+		deleted = append(deleted, image.DeleteResponse{Untagged: familiarName(tag)})
+	}
+	delete(c.images, img.id)
+	return append(deleted, image.DeleteResponse{Deleted: img.id}), nil
+}
+
+// find returns the image with the provided ID (with or without the algorithm, possibly truncated) or tag.
+// The caller must hold the mutex.
+func (c *DockerClient) find(ref string) (*storedImage, error) {
+	if tag, ok := c.tagFor(ref); ok {
+		if id, ok := c.tags[tag]; ok {
+			return c.images[id], nil
+		}
+	}
+	if img, ok := c.images[ref]; ok {
+		return img, nil
+	}
+	if prefix := strings.TrimPrefix(ref, "sha256:"); len(prefix) > 0 && isHex(prefix) {
+		var found []*storedImage
+		for id, img := range c.images {
+			if strings.HasPrefix(strings.TrimPrefix(id, "sha256:"), prefix) {
+				found = append(found, img)
+			}
+		}
+		if len(found) == 1 {
+			return found[0], nil
+		}
+	}
+	return nil, errNotFound{ref: ref}
+}
+
+// tagFor returns the normalized tag for the provided reference, if it is a tag reference.
+func (c *DockerClient) tagFor(ref string) (string, bool) {
+	if strings.HasPrefix(ref, "sha256:") || strings.Contains(ref, "@") {
+		return "", false
+	}
+	tag, err := name.NewTag(ref, name.WeakValidation)
+	if err != nil {
+		return "", false
+	}
+	return tag.Name(), true
+}
+
+func (c *DockerClient) tagsFor(id string) []string {
+	var tags []string
+	for tag, taggedID := range c.tags {
+		if taggedID == id {
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// repoTagsFor returns the tags of the image in the short form reported by the daemon (e.g., "some/repo:latest").
+func (c *DockerClient) repoTagsFor(id string) []string {
+	repoTags := []string{}
+	for _, tag := range c.tagsFor(id) {
+		repoTags = append(repoTags, familiarName(tag))
+	}
+	return repoTags
+}
+
+func familiarName(tag string) string {
+	ref, err := name.NewTag(tag, name.WeakValidation)
+	if err != nil {
+		return tag
+	}
+	if ref.RegistryStr() != name.DefaultRegistry {
+		return ref.Name()
+	}
+	return strings.TrimPrefix(ref.RepositoryStr(), "library/") + ":" + ref.TagStr()
+}
+
+func isHex(s string) bool {
+	for _, r := range s {
+		if !strings.ContainsRune("0123456789abcdef", r) {
+			return false
+		}
+	}
+	return true
+}
+
+type errNotFound struct {
+	ref string
+}
+
+// NotFound makes the error recognizable with client.IsErrNotFound.
+func (e errNotFound) NotFound() {}
+
+func (e errNotFound) Error() string {
+	return fmt.Sprintf("Error: No such image: %s", e.ref)
+}
+
+// load & save
+
+// ImageLoad loads the images from a docker-save tar.
+// Like the daemon, errors found in the tar are reported in the response body.
+func (c *DockerClient) ImageLoad(ctx context.Context, input io.Reader, _ bool) (types.ImageLoadResponse, error) {
+	if err := c.available(ctx); err != nil {
+		return types.ImageLoadResponse{}, err
+	}
+	var messages []jsonmessage.JSONMessage
+	loaded, err := c.load(input)
+	_, _ = io.Copy(io.Discard, input) // the client keeps sending the tar until the request is done
+	if err != nil {
+		messages = append(messages, jsonmessage.JSONMessage{Error: &jsonmessage.JSONError{Message: err.Error()}})
+	}
+	for _, l := range loaded {
+		messages = append(messages, jsonmessage.JSONMessage{Stream: fmt.Sprintf("Loaded image: %s\n", l)})
+	}
+	var body bytes.Buffer
+	for _, message := range messages {
+		if err = json.NewEncoder(&body).Encode(message); err != nil {
+			return types.ImageLoadResponse{}, err
+		}
+	}
+	return types.ImageLoadResponse{Body: io.NopCloser(&body), JSON: true}, nil
+}
+
+func (c *DockerClient) load(input io.Reader) ([]string, error) {
+	files := make(map[string][]byte)
+	tr := tar.NewReader(input)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading tar: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		contents, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("reading tar: %w", err)
+		}
+		files[cleanName(hdr.Name)] = contents
+	}
+
+	rawManifest, ok := files["manifest.json"]
+	if !ok {
+		return nil, errors.New("invalid tar: manifest.json not found")
+	}
+	var manifest []struct {
+		Config   string
+		RepoTags []string
+		Layers   []string
+	}
+	if err := json.Unmarshal(rawManifest, &manifest); err != nil {
+		return nil, fmt.Errorf("parsing manifest.json: %w", err)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	var loaded []string
+	for _, entry := range manifest {
+		img, err := c.loadImage(files, entry.Config, entry.Layers)
+		if err != nil {
+			return loaded, err
+		}
+		if len(entry.RepoTags) == 0 {
+			loaded = append(loaded, "ID: "+img.id)
+		}
+		for _, repoTag := range entry.RepoTags {
+			tag, err := name.NewTag(repoTag, name.WeakValidation)
+			if err != nil {
+				return loaded, fmt.Errorf("invalid tag %q: %w", repoTag, err)
+			}
+			c.tags[tag.Name()] = img.id
+			loaded = append(loaded, familiarName(tag.Name()))
+		}
+	}
+	return loaded, nil
+}
+
+// loadImage stores the image with the provided config and layers from the tar files.
+// The caller must hold the mutex.
+func (c *DockerClient) loadImage(files map[string][]byte, configName string, layerNames []string) (*storedImage, error) {
+	rawConfig, ok := files[cleanName(configName)]
+	if !ok {
+		return nil, fmt.Errorf("invalid tar: config %q not found", configName)
+	}
+	configFile, err := v1.ParseConfigFile(bytes.NewReader(rawConfig))
+	if err != nil {
+		return nil, fmt.Errorf("parsing config: %w", err)
+	}
+	diffIDs := configFile.RootFS.DiffIDs
+	if len(diffIDs) != len(layerNames) {
+		return nil, fmt.Errorf("invalid tar: config has %d diff IDs, but %d layers were provided", len(diffIDs), len(layerNames))
+	}
+
+	layers := make(map[v1.Hash][]byte)
+	for idx, layerName := range layerNames {
+		contents, ok := files[cleanName(layerName)]
+		if !ok {
+			return nil, fmt.Errorf("invalid tar: layer %q not found", layerName)
+		}
+		if len(contents) == 0 { // the layer was omitted, as it should be known by the daemon
+			if _, known := c.layers[diffIDs[idx]]; !known || c.containerdStorage {
+				return nil, fmt.Errorf("layer %s does not exist", diffIDs[idx])
+			}
+			continue
+		}
+		uncompressed, diffID, err := uncompress(contents)
+		if err != nil {
+			return nil, fmt.Errorf("reading layer %q: %w", layerName, err)
+		}
+		if diffID != diffIDs[idx] {
+			return nil, fmt.Errorf("layer %q has diff ID %s, but the config expects %s", layerName, diffID, diffIDs[idx])
+		}
+		layers[diffID] = uncompressed
+	}
+
+	for diffID, contents := range layers {
+		c.layers[diffID] = contents
+	}
+	configHash, _, err := v1.SHA256(bytes.NewReader(rawConfig))
+	if err != nil {
+		return nil, err
+	}
+	img := &storedImage{
+		id:         configHash.String(),
+		rawConfig:  rawConfig,
+		configFile: configFile,
+	}
+	c.images[img.id] = img
+	return img, nil
+}
+
+// uncompress returns the uncompressed layer tar (the daemon also accepts compressed layers) and its diff ID.
+func uncompress(contents []byte) ([]byte, v1.Hash, error) {
+	layer, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(contents)), nil
+	})
+	if err != nil {
+		return nil, v1.Hash{}, err
+	}
+	rc, err := layer.Uncompressed()
+	if err != nil {
+		return nil, v1.Hash{}, err
+	}
+	defer rc.Close()
+	uncompressed, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, v1.Hash{}, err
+	}
+	diffID, _, err := v1.SHA256(bytes.NewReader(uncompressed))
+	return uncompressed, diffID, err
+}
+
+func cleanName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// ImageSave returns a docker-save tar with the provided images.
+// Like the daemon, the tags of images referenced by ID are not included.
+func (c *DockerClient) ImageSave(ctx context.Context, refs []string) (io.ReadCloser, error) {
+	if err := c.available(ctx); err != nil {
+		return nil, err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	type manifestEntry struct {
+		Config   string
+		RepoTags []string
+		Layers   []string
+	}
+	var (
+		buf      bytes.Buffer
+		manifest []manifestEntry
+		written  = make(map[string]bool)
+	)
+	tw := tar.NewWriter(&buf)
+	for _, ref := range refs {
+		img, err := c.find(ref)
+		if err != nil {
+			return nil, err
+		}
+		entry := manifestEntry{Config: strings.TrimPrefix(img.id, "sha256:") + ".json"}
+		if tag, ok := c.tagFor(ref); ok {
+			entry.RepoTags = []string{familiarName(tag)}
+		}
+		if err = addFileToTar(tw, entry.Config, img.rawConfig, written); err != nil {
+			return nil, err
+		}
+		for _, diffID := range img.configFile.RootFS.DiffIDs {
+			layerName := diffID.Hex + "/layer.tar"
+			if err = addFileToTar(tw, layerName, c.layers[diffID], written); err != nil {
+				return nil, err
+			}
+			entry.Layers = append(entry.Layers, layerName)
+		}
+		manifest = append(manifest, entry)
+	}
+	rawManifest, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+	if err = addFileToTar(tw, "manifest.json", rawManifest, written); err != nil {
+		return nil, err
+	}
+	if err = tw.Close(); err != nil {
+		return nil, err
+	}
+	return io.NopCloser(&buf), nil
+}
+
+func addFileToTar(tw *tar.Writer, name string, contents []byte, written map[string]bool) error {
+	if written[name] {
+		return nil
+	}
+	written[name] = true
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(contents))}); err != nil {
+		return err
+	}
+	_, err := tw.Write(contents)
+	return err
+}
@@ -0,0 +1,245 @@
+package localtest_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil"
+	"github.com/buildpacks/imgutil/local"
+	"github.com/buildpacks/imgutil/local/localtest"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestDockerClient(t *testing.T) {
+	spec.Run(t, "DockerClient", testDockerClient, spec.Sequential(), spec.Report(report.Terminal{}))
+}
+
+func testDockerClient(t *testing.T, when spec.G, it spec.S) {
+	var (
+		dockerClient *localtest.DockerClient
+		tmpDir       string
+		layerPath    string
+		layerSHA     string
+		err          error
+	)
+
+	it.Before(func() {
+		dockerClient = localtest.NewDockerClient()
+		tmpDir, err = os.MkdirTemp("", "localtest")
+		h.AssertNil(t, err)
+		layerPath, layerSHA, _ = h.RandomLayer(t, tmpDir)
+	})
+
+	it.After(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	// saveBaseImage saves an image with a label and a layer to the fake daemon
+	saveBaseImage := func(repoName string) {
+		baseImage, err := local.NewImage(repoName, dockerClient)
+		h.AssertNil(t, err)
+		h.AssertNil(t, baseImage.SetLabel("some-label", "some-value"))
+		h.AssertNil(t, baseImage.AddLayer(layerPath))
+		h.AssertNil(t, baseImage.Save())
+	}
+
+	when("#ServerVersion", func() {
+		it("reports the platform", func() {
+			dockerClient.SetPlatform("windows", "arm64")
+
+			img, err := local.NewImage("some/image", dockerClient)
+			h.AssertNil(t, err)
+
+			osName, err := img.OS()
+			h.AssertNil(t, err)
+			h.AssertEq(t, osName, "windows")
+			arch, err := img.Architecture()
+			h.AssertNil(t, err)
+			h.AssertEq(t, arch, "arm64")
+		})
+	})
+
+	when("#ImageLoad", func() {
+		it("keeps saved images by ID and tag", func() {
+			img, err := local.NewImage("some/image", dockerClient)
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.SetLabel("some-label", "some-value"))
+			h.AssertNil(t, img.AddLayer(layerPath))
+
+			h.AssertNil(t, img.Save("some/image:other-tag"))
+
+			id, err := img.Identifier()
+			h.AssertNil(t, err)
+			for _, ref := range []string{"some/image", "index.docker.io/some/image:other-tag", id.String(), "sha256:" + id.String()} {
+				inspect, _, err := dockerClient.ImageInspectWithRaw(context.TODO(), ref)
+				h.AssertNil(t, err)
+				h.AssertEq(t, inspect.ID, "sha256:"+id.String())
+				h.AssertEq(t, inspect.RepoTags, []string{"some/image:latest", "some/image:other-tag"})
+				h.AssertEq(t, inspect.Config.Labels["some-label"], "some-value")
+				h.AssertEq(t, inspect.RootFS.Layers, []string{layerSHA})
+				h.AssertEq(t, inspect.Os, "linux")
+			}
+		})
+
+		it("reports the history", func() {
+			img, err := local.NewImage("some/image", dockerClient, imgutil.WithHistory())
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.AddLayerWithDiffIDAndHistory(layerPath, layerSHA, v1.History{CreatedBy: "some-history"}))
+			h.AssertNil(t, img.Save())
+
+			history, err := dockerClient.ImageHistory(context.TODO(), "some/image")
+			h.AssertNil(t, err)
+			h.AssertEq(t, len(history), 1)
+			h.AssertEq(t, history[0].CreatedBy, "some-history")
+			h.AssertEq(t, history[0].Tags, []string{"some/image:latest"})
+		})
+
+		when("the image has a base image", func() {
+			it.Before(func() {
+				saveBaseImage("some/base-image")
+			})
+
+			for _, containerdStorage := range []bool{false, true} {
+				containerdStorage := containerdStorage
+
+				it(fmt.Sprintf("saves the image (containerd storage: %t)", containerdStorage), func() {
+					dockerClient.SetContainerdStorage(containerdStorage)
+					img, err := local.NewImage("some/image", dockerClient, imgutil.FromBaseImage("some/base-image"))
+					h.AssertNil(t, err)
+					otherLayerPath, otherLayerSHA, _ := h.RandomLayer(t, tmpDir)
+					h.AssertNil(t, img.AddLayer(otherLayerPath))
+
+					h.AssertNil(t, img.Save())
+
+					inspect, _, err := dockerClient.ImageInspectWithRaw(context.TODO(), "some/image")
+					h.AssertNil(t, err)
+					h.AssertEq(t, inspect.Config.Labels["some-label"], "some-value")
+					h.AssertEq(t, inspect.RootFS.Layers, []string{layerSHA, otherLayerSHA})
+				})
+			}
+		})
+
+		when("layers are omitted", func() {
+			var tarWithOmittedLayer func() io.Reader
+
+			it.Before(func() {
+				tarWithOmittedLayer = func() io.Reader {
+					var buf bytes.Buffer
+					tw := tar.NewWriter(&buf)
+					config := []byte(`{"os":"linux","architecture":"amd64","rootfs":{"type":"layers","diff_ids":["` + layerSHA + `"]}}`)
+					manifest := []byte(`[{"Config":"config.json","RepoTags":["some/image:latest"],"Layers":["blank_0"]}]`)
+					for _, file := range []struct {
+						name     string
+						contents []byte
+					}{{"config.json", config}, {"blank_0", nil}, {"manifest.json", manifest}} {
+						h.AssertNil(t, tw.WriteHeader(&tar.Header{Name: file.name, Mode: 0644, Size: int64(len(file.contents))}))
+						_, err := tw.Write(file.contents)
+						h.AssertNil(t, err)
+					}
+					h.AssertNil(t, tw.Close())
+					return &buf
+				}
+			})
+
+			it("loads the image if the layers are known", func() {
+				saveBaseImage("some/base-image")
+
+				res, err := dockerClient.ImageLoad(context.TODO(), tarWithOmittedLayer(), true)
+				h.AssertNil(t, err)
+				body, err := io.ReadAll(res.Body)
+				h.AssertNil(t, err)
+				h.AssertEq(t, string(body), `{"stream":"Loaded image: some/image:latest\n"}`+"\n")
+			})
+
+			it("reports an error if the layers are unknown", func() {
+				res, err := dockerClient.ImageLoad(context.TODO(), tarWithOmittedLayer(), true)
+				h.AssertNil(t, err)
+				body, err := io.ReadAll(res.Body)
+				h.AssertNil(t, err)
+				h.AssertEq(t, strings.Contains(string(body), "does not exist"), true)
+			})
+
+			it("reports an error when emulating the containerd snapshotter", func() {
+				saveBaseImage("some/base-image")
+				dockerClient.SetContainerdStorage(true)
+
+				res, err := dockerClient.ImageLoad(context.TODO(), tarWithOmittedLayer(), true)
+				h.AssertNil(t, err)
+				body, err := io.ReadAll(res.Body)
+				h.AssertNil(t, err)
+				h.AssertEq(t, strings.Contains(string(body), "does not exist"), true)
+			})
+		})
+	})
+
+	when("#ImageSave", func() {
+		it("returns the layers of the image", func() {
+			saveBaseImage("some/base-image")
+			img, err := local.NewImage("some/image", dockerClient, imgutil.FromBaseImage("some/base-image"))
+			h.AssertNil(t, err)
+
+			rc, err := img.GetLayer(layerSHA)
+			h.AssertNil(t, err)
+			defer rc.Close()
+			contents, err := io.ReadAll(rc)
+			h.AssertNil(t, err)
+			expected, err := os.ReadFile(layerPath)
+			h.AssertNil(t, err)
+			h.AssertEq(t, contents, expected)
+		})
+
+		it("provides the layers of previous images", func() {
+			saveBaseImage("some/image")
+			img, err := local.NewImage("some/image", dockerClient, imgutil.WithPreviousImage("some/image"))
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.ReuseLayer(layerSHA))
+
+			h.AssertNil(t, img.Save())
+
+			inspect, _, err := dockerClient.ImageInspectWithRaw(context.TODO(), "some/image")
+			h.AssertNil(t, err)
+			h.AssertEq(t, inspect.RootFS.Layers, []string{layerSHA})
+		})
+	})
+
+	when("#ImageRemove", func() {
+		it("removes the image and its tags", func() {
+			img, err := local.NewImage("some/image", dockerClient)
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.Save("some/image:other-tag"))
+
+			h.AssertNil(t, img.Delete())
+
+			h.AssertEq(t, img.Found(), true) // like other local images, the working image is unchanged
+			for _, ref := range []string{"some/image", "some/image:other-tag"} {
+				_, _, err = dockerClient.ImageInspectWithRaw(context.TODO(), ref)
+				h.AssertEq(t, client.IsErrNotFound(err), true)
+			}
+		})
+
+		it("only removes the tag if the image has other tags", func() {
+			img, err := local.NewImage("some/image", dockerClient)
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.Save("some/image:other-tag"))
+
+			_, err = dockerClient.ImageRemove(context.TODO(), "some/image:other-tag", image.RemoveOptions{})
+			h.AssertNil(t, err)
+
+			inspect, _, err := dockerClient.ImageInspectWithRaw(context.TODO(), "some/image")
+			h.AssertNil(t, err)
+			h.AssertEq(t, inspect.RepoTags, []string{"some/image:latest"})
+		})
+	})
+}
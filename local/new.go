@@ -7,6 +7,7 @@ import (
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/client"
+	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 
 	"github.com/buildpacks/imgutil"
@@ -20,13 +21,15 @@ func NewImage(repoName string, dockerClient DockerClient, ops ...imgutil.ImageOp
 		op(options)
 	}
 
+	ctx := imgutil.ContextOrBackground(options.Context)
+
 	var err error
-	options.Platform, err = processPlatformOption(options.Platform, dockerClient)
+	options.Platform, err = processPlatformOption(ctx, options.Platform, dockerClient)
 	if err != nil {
 		return nil, err
 	}
 
-	previousImage, err := processImageOption(options.PreviousImageRepoName, dockerClient, true)
+	previousImage, err := processImageOption(ctx, options.PreviousImageRepoName, dockerClient, true, options.StrictPreviousImage, options.Progress)
 	if err != nil {
 		return nil, err
 	}
@@ -38,16 +41,17 @@ func NewImage(repoName string, dockerClient DockerClient, ops ...imgutil.ImageOp
 		baseIdentifier string
 		store          *Store
 	)
-	baseImage, err := processImageOption(options.BaseImageRepoName, dockerClient, false)
+	baseImage, err := processImageOption(ctx, options.BaseImageRepoName, dockerClient, false, options.StrictBaseImage, options.Progress)
 	if err != nil {
 		return nil, err
 	}
 	if baseImage.image != nil {
 		options.BaseImage = baseImage.image
+		options.BaseImageDigest = baseImage.repoDigest
 		baseIdentifier = baseImage.identifier
 		store = baseImage.layerStore
 	} else {
-		store = NewStore(dockerClient)
+		store = newStore(ctx, dockerClient, options.Progress)
 	}
 
 	cnbImage, err := imgutil.NewCNBImage(*options)
@@ -57,6 +61,7 @@ func NewImage(repoName string, dockerClient DockerClient, ops ...imgutil.ImageOp
 
 	return &Image{
 		CNBImageCore:   cnbImage,
+		ctx:            ctx,
 		repoName:       repoName,
 		store:          store,
 		lastIdentifier: baseIdentifier,
@@ -64,10 +69,10 @@ func NewImage(repoName string, dockerClient DockerClient, ops ...imgutil.ImageOp
 	}, nil
 }
 
-func defaultPlatform(dockerClient DockerClient) (imgutil.Platform, error) {
-	daemonInfo, err := dockerClient.ServerVersion(context.Background())
+func defaultPlatform(ctx context.Context, dockerClient DockerClient) (imgutil.Platform, error) {
+	daemonInfo, err := dockerClient.ServerVersion(ctx)
 	if err != nil {
-		return imgutil.Platform{}, err
+		return imgutil.Platform{}, daemonError(err)
 	}
 	return imgutil.Platform{
 		OS:           daemonInfo.Os,
@@ -75,17 +80,18 @@ func defaultPlatform(dockerClient DockerClient) (imgutil.Platform, error) {
 	}, nil
 }
 
-func processPlatformOption(requestedPlatform imgutil.Platform, dockerClient DockerClient) (imgutil.Platform, error) {
-	dockerPlatform, err := defaultPlatform(dockerClient)
+func processPlatformOption(ctx context.Context, requestedPlatform imgutil.Platform, dockerClient DockerClient) (imgutil.Platform, error) {
+	dockerPlatform, err := defaultPlatform(ctx, dockerClient)
 	if err != nil {
 		return imgutil.Platform{}, err
 	}
-	if (requestedPlatform == imgutil.Platform{}) {
+	if requestedPlatform.IsZero() {
 		return dockerPlatform, nil
 	}
-	if requestedPlatform.OS != "" && requestedPlatform.OS != dockerPlatform.OS {
-		return imgutil.Platform{},
-			fmt.Errorf("invalid os: platform os %q must match the daemon os %q", requestedPlatform.OS, dockerPlatform.OS)
+	// the daemon can run images for other architectures (through emulation), so only the OS has to match
+	if !(imgutil.Platform{OS: requestedPlatform.OS}).Matches(v1.Platform{OS: dockerPlatform.OS}) {
+		return imgutil.Platform{}, fmt.Errorf("invalid os: platform os %q must match the daemon os %q: %w",
+			requestedPlatform.OS, dockerPlatform.OS, imgutil.ErrPlatformMismatch)
 	}
 	return requestedPlatform, nil
 }
@@ -93,21 +99,26 @@ func processPlatformOption(requestedPlatform imgutil.Platform, dockerClient Dock
 type imageResult struct {
 	image      v1.Image
 	identifier string
+	repoDigest string // the digest of the image in the registry it was pulled from, if known
 	layerStore *Store
 }
 
-func processImageOption(repoName string, dockerClient DockerClient, downloadLayersOnAccess bool) (imageResult, error) {
+// processImageOption returns the image with the provided name, or no image if it is not found, unless strict is true.
+func processImageOption(ctx context.Context, repoName string, dockerClient DockerClient, downloadLayersOnAccess, strict bool, withProgress imgutil.ProgressFunc) (imageResult, error) {
 	if repoName == "" {
 		return imageResult{}, nil
 	}
-	inspect, history, err := getInspectAndHistory(repoName, dockerClient)
+	inspect, history, err := getInspectAndHistory(ctx, repoName, dockerClient)
 	if err != nil {
 		return imageResult{}, err
 	}
 	if inspect == nil {
+		if strict {
+			return imageResult{}, fmt.Errorf("failed to find image %q in the daemon: %w", repoName, imgutil.ErrImageNotFound)
+		}
 		return imageResult{}, nil
 	}
-	layerStore := NewStore(dockerClient)
+	layerStore := newStore(ctx, dockerClient, withProgress)
 	v1Image, err := newV1ImageFacadeFromInspect(*inspect, history, layerStore, downloadLayersOnAccess)
 	if err != nil {
 		return imageResult{}, err
@@ -115,21 +126,41 @@ func processImageOption(repoName string, dockerClient DockerClient, downloadLaye
 	return imageResult{
 		image:      v1Image,
 		identifier: inspect.ID,
+		repoDigest: repoDigestFor(repoName, inspect.RepoDigests),
 		layerStore: layerStore,
 	}, nil
 }
 
-func getInspectAndHistory(repoName string, dockerClient DockerClient) (*types.ImageInspect, []image.HistoryResponseItem, error) {
-	inspect, _, err := dockerClient.ImageInspectWithRaw(context.Background(), repoName)
+// repoDigestFor returns the digest from the repo digests reported by the daemon
+// that matches the repository of the provided name, or empty if there is none.
+func repoDigestFor(repoName string, repoDigests []string) string {
+	ref, err := name.ParseReference(repoName, name.WeakValidation)
+	if err != nil {
+		return ""
+	}
+	for _, repoDigest := range repoDigests {
+		digestRef, err := name.NewDigest(repoDigest, name.WeakValidation)
+		if err != nil {
+			continue
+		}
+		if digestRef.Context().Name() == ref.Context().Name() {
+			return digestRef.DigestStr()
+		}
+	}
+	return ""
+}
+
+func getInspectAndHistory(ctx context.Context, repoName string, dockerClient DockerClient) (*types.ImageInspect, []image.HistoryResponseItem, error) {
+	inspect, _, err := dockerClient.ImageInspectWithRaw(ctx, repoName)
 	if err != nil {
 		if client.IsErrNotFound(err) {
 			return nil, nil, nil
 		}
-		return nil, nil, fmt.Errorf("inspecting image %q: %w", repoName, err)
+		return nil, nil, fmt.Errorf("inspecting image %q: %w", repoName, daemonError(err))
 	}
-	history, err := dockerClient.ImageHistory(context.Background(), repoName)
+	history, err := dockerClient.ImageHistory(ctx, repoName)
 	if err != nil {
-		return nil, nil, fmt.Errorf("get history for image %q: %w", repoName, err)
+		return nil, nil, fmt.Errorf("get history for image %q: %w", repoName, daemonError(err))
 	}
 	return &inspect, history, nil
 }
@@ -31,7 +31,10 @@ type Store struct {
 	// required
 	dockerClient DockerClient
 	// optional
-	downloadOnce         *sync.Once
+	ctx                  context.Context // used by methods that don't accept a context
+	progress             imgutil.ProgressFunc
+	downloadMutex        *sync.Mutex
+	layersDownloaded     bool
 	onDiskLayersByDiffID map[v1.Hash]annotatedLayer
 }
 
@@ -53,9 +56,15 @@ type annotatedLayer struct {
 }
 
 func NewStore(dockerClient DockerClient) *Store {
+	return newStore(context.Background(), dockerClient, nil)
+}
+
+func newStore(ctx context.Context, dockerClient DockerClient, withProgress imgutil.ProgressFunc) *Store {
 	return &Store{
 		dockerClient:         dockerClient,
-		downloadOnce:         &sync.Once{},
+		ctx:                  ctx,
+		progress:             withProgress,
+		downloadMutex:        &sync.Mutex{},
 		onDiskLayersByDiffID: make(map[v1.Hash]annotatedLayer),
 	}
 }
@@ -63,23 +72,35 @@ func NewStore(dockerClient DockerClient) *Store {
 // images
 
 func (s *Store) Contains(identifier string) bool {
-	_, _, err := s.dockerClient.ImageInspectWithRaw(context.Background(), identifier)
+	return s.contains(s.ctx, identifier)
+}
+
+func (s *Store) contains(ctx context.Context, identifier string) bool {
+	_, _, err := s.dockerClient.ImageInspectWithRaw(ctx, identifier)
 	return err == nil
 }
 
 func (s *Store) Delete(identifier string) error {
-	if !s.Contains(identifier) {
+	return s.delete(s.ctx, identifier)
+}
+
+func (s *Store) delete(ctx context.Context, identifier string) error {
+	if !s.contains(ctx, identifier) {
 		return nil
 	}
 	options := image.RemoveOptions{
 		Force:         true,
 		PruneChildren: true,
 	}
-	_, err := s.dockerClient.ImageRemove(context.Background(), identifier, options)
-	return err
+	_, err := s.dockerClient.ImageRemove(ctx, identifier, options)
+	return daemonError(err)
 }
 
 func (s *Store) Save(image *Image, withName string, withAdditionalNames ...string) (string, error) {
+	return s.save(s.ctx, image, withName, withAdditionalNames...)
+}
+
+func (s *Store) save(ctx context.Context, image *Image, withName string, withAdditionalNames ...string) (string, error) {
 	withName = tryNormalizing(withName)
 	var (
 		inspect types.ImageInspect
@@ -87,17 +108,17 @@ func (s *Store) Save(image *Image, withName string, withAdditionalNames ...strin
 	)
 
 	// save
-	canOmitBaseLayers := !usesContainerdStorage(s.dockerClient)
+	canOmitBaseLayers := !usesContainerdStorage(ctx, s.dockerClient)
 	if canOmitBaseLayers {
 		// During the first save attempt some layers may be excluded.
 		// The docker daemon allows this if the given set of layers already exists in the daemon in the given order.
-		inspect, err = s.doSave(image, withName)
+		inspect, err = s.doSave(ctx, image, withName)
 	}
 	if !canOmitBaseLayers || err != nil {
-		if err = image.ensureLayers(); err != nil {
+		if err = image.ensureLayers(ctx); err != nil {
 			return "", err
 		}
-		inspect, err = s.doSave(image, withName)
+		inspect, err = s.doSave(ctx, image, withName)
 		if err != nil {
 			saveErr := imgutil.SaveError{}
 			for _, n := range append([]string{withName}, withAdditionalNames...) {
@@ -110,8 +131,8 @@ func (s *Store) Save(image *Image, withName string, withAdditionalNames ...strin
 	// tag additional names
 	var errs []imgutil.SaveDiagnostic
 	for _, n := range append([]string{withName}, withAdditionalNames...) {
-		if err = s.dockerClient.ImageTag(context.Background(), inspect.ID, n); err != nil {
-			errs = append(errs, imgutil.SaveDiagnostic{ImageName: n, Cause: err})
+		if err = s.dockerClient.ImageTag(ctx, inspect.ID, n); err != nil {
+			errs = append(errs, imgutil.SaveDiagnostic{ImageName: n, Cause: daemonError(err)})
 		}
 	}
 	if len(errs) > 0 {
@@ -130,8 +151,8 @@ func tryNormalizing(name string) string {
 	return t.Name() // returns valid 'name:tag' appending 'latest', if missing tag
 }
 
-func usesContainerdStorage(docker DockerClient) bool {
-	info, err := docker.Info(context.Background())
+func usesContainerdStorage(ctx context.Context, docker DockerClient) bool {
+	info, err := docker.Info(ctx)
 	if err != nil {
 		return false
 	}
@@ -145,9 +166,8 @@ func usesContainerdStorage(docker DockerClient) bool {
 	return false
 }
 
-func (s *Store) doSave(image v1.Image, withName string) (types.ImageInspect, error) {
-	ctx := context.Background()
-	done := make(chan error)
+func (s *Store) doSave(ctx context.Context, image v1.Image, withName string) (types.ImageInspect, error) {
+	done := make(chan error, 1)
 
 	var err error
 	pr, pw := io.Pipe()
@@ -157,7 +177,10 @@ func (s *Store) doSave(image v1.Image, withName string) (types.ImageInspect, err
 		var res types.ImageLoadResponse
 		res, err = s.dockerClient.ImageLoad(ctx, pr, true)
 		if err != nil {
-			done <- err
+			// unblock the tar writer, e.g., if the context is done, which then fails with the same error
+			loadErr := daemonError(err)
+			pr.CloseWithError(loadErr)
+			done <- loadErr
 			return
 		}
 
@@ -188,12 +211,12 @@ func (s *Store) doSave(image v1.Image, withName string) (types.ImageInspect, err
 		return types.ImageInspect{}, fmt.Errorf("loading image %q. first error: %w", withName, err)
 	}
 
-	inspect, _, err := s.dockerClient.ImageInspectWithRaw(context.Background(), withName)
+	inspect, _, err := s.dockerClient.ImageInspectWithRaw(ctx, withName)
 	if err != nil {
 		if client.IsErrNotFound(err) {
-			return types.ImageInspect{}, fmt.Errorf("saving image %q: %w", withName, err)
+			return types.ImageInspect{}, fmt.Errorf("saving image %q: %w", withName, daemonError(err))
 		}
-		return types.ImageInspect{}, err
+		return types.ImageInspect{}, daemonError(err)
 	}
 	return inspect, nil
 }
@@ -271,7 +294,7 @@ func (s *Store) addLayerToTar(tw *tar.Writer, layer v1.Layer, blankIdx *int) (st
 	if err = tw.WriteHeader(hdr); err != nil {
 		return "", err
 	}
-	if _, err = io.Copy(tw, layerReader); err != nil {
+	if _, err = io.Copy(tw, imgutil.NewProgressReadCloser(layerReader, layerDiffID.String(), uncompressedSize, s.progress)); err != nil {
 		return "", err
 	}
 
@@ -339,6 +362,10 @@ func ensureReaderClosed(r io.ReadCloser) error {
 }
 
 func (s *Store) SaveFile(image *Image, withName string) (string, error) {
+	return s.saveFile(s.ctx, image, withName)
+}
+
+func (s *Store) saveFile(ctx context.Context, image *Image, withName string) (string, error) {
 	withName = tryNormalizing(withName)
 
 	f, err := os.CreateTemp("", "imgutil.local.image.export.*.tar")
@@ -356,11 +383,11 @@ func (s *Store) SaveFile(image *Image, withName string) (string, error) {
 	// (1) WithPreviousImage(), or (2) FromBaseImage().
 	// The former is only relevant if ReuseLayers() has been called which takes care of resolving them.
 	// The latter case needs to be handled explicitly.
-	if err = image.ensureLayers(); err != nil {
+	if err = image.ensureLayers(ctx); err != nil {
 		return "", err
 	}
 
-	errs, _ := errgroup.WithContext(context.Background())
+	errs, _ := errgroup.WithContext(ctx)
 	pr, pw := io.Pipe()
 
 	// File writer
@@ -389,23 +416,29 @@ func (s *Store) SaveFile(image *Image, withName string) (string, error) {
 
 // layers
 
-func (s *Store) downloadLayersFor(identifier string) error {
-	var err error
-	s.downloadOnce.Do(func() {
-		err = s.doDownloadLayersFor(identifier)
-	})
-	return err
+// downloadLayersFor downloads the layers for the image with the provided identifier, unless already downloaded.
+// If the download fails (e.g., because the context is done), it is attempted again on the next call.
+func (s *Store) downloadLayersFor(ctx context.Context, identifier string) error {
+	s.downloadMutex.Lock()
+	defer s.downloadMutex.Unlock()
+	if s.layersDownloaded {
+		return nil
+	}
+	if err := s.doDownloadLayersFor(ctx, identifier); err != nil {
+		return err
+	}
+	s.layersDownloaded = true
+	return nil
 }
 
-func (s *Store) doDownloadLayersFor(identifier string) error {
+func (s *Store) doDownloadLayersFor(ctx context.Context, identifier string) error {
 	if identifier == "" {
 		return nil
 	}
-	ctx := context.Background()
 
 	imageReader, err := s.dockerClient.ImageSave(ctx, []string{identifier})
 	if err != nil {
-		return fmt.Errorf("saving image with ID %q from the docker daemon: %w", identifier, err)
+		return fmt.Errorf("saving image with ID %q from the docker daemon: %w", identifier, daemonError(err))
 	}
 	defer ensureReaderClosed(imageReader)
 
@@ -414,7 +447,7 @@ func (s *Store) doDownloadLayersFor(identifier string) error {
 		return fmt.Errorf("failed to create temp dir: %w", err)
 	}
 
-	err = untar(imageReader, tmpDir)
+	err = untar(imgutil.NewProgressReadCloser(imageReader, "", -1, s.progress), tmpDir)
 	if err != nil {
 		return err
 	}
@@ -533,7 +566,7 @@ func cleanPath(dest, header string) (string, error) {
 func (s *Store) LayerByDiffID(h v1.Hash) (v1.Layer, error) {
 	layer := s.findLayer(h)
 	if layer == nil {
-		return nil, fmt.Errorf("failed to find layer with diff ID %q", h.String())
+		return nil, fmt.Errorf("failed to find layer with diff ID %q: %w", h.String(), imgutil.ErrLayerMissing)
 	}
 	return layer, nil
 }
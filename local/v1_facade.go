@@ -215,7 +215,7 @@ func newDownloadableEmptyLayer(diffID v1.Hash, store *Store, imageID string) *v1
 			if err == nil {
 				return layer.Uncompressed()
 			}
-			if err = store.downloadLayersFor(imageID); err != nil {
+			if err = store.downloadLayersFor(store.ctx, imageID); err != nil {
 				return nil, err
 			}
 			layer, err = store.LayerByDiffID(diffID)
@@ -0,0 +1,257 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/pkg/errors"
+
+	"github.com/buildpacks/imgutil"
+)
+
+var _ imgutil.ImageWithContext = (*Image)(nil)
+
+// Image wraps an imgutil.CNBImageCore and implements the methods needed to complete the imgutil.Image interface,
+// for images saved to an in-process Store.
+type Image struct {
+	*imgutil.CNBImageCore
+	repoName      string
+	store         *Store
+	archiveFormat imgutil.ArchiveFormat
+}
+
+func (i *Image) Kind() string {
+	return "memory"
+}
+
+func (i *Image) Name() string {
+	return i.repoName
+}
+
+func (i *Image) Rename(name string) {
+	i.repoName = name
+}
+
+// Found reports if image exists in the store with `Name()`.
+func (i *Image) Found() bool {
+	return i.store.Contains(i.repoName)
+}
+
+// FoundWithContext is like Found; the store is in memory.
+func (i *Image) FoundWithContext(_ context.Context) bool {
+	return i.Found()
+}
+
+func (i *Image) Identifier() (imgutil.Identifier, error) {
+	ref, err := name.ParseReference(i.repoName, name.WeakValidation)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing reference for image %q", i.repoName)
+	}
+	hash, err := i.Digest()
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting digest for image %q", i.repoName)
+	}
+	return DigestIdentifier{
+		Digest: ref.Context().Digest(hash.String()),
+	}, nil
+}
+
+// Valid returns true if the image exists in the store with `Name()`.
+func (i *Image) Valid() bool {
+	return i.Found()
+}
+
+// Delete removes the image with the digest of the working image from the store, along with its tags.
+func (i *Image) Delete() error {
+	id, err := i.Identifier()
+	if err != nil {
+		return err
+	}
+	return i.store.Delete(id.String())
+}
+
+func (i *Image) DeleteWithContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return i.Delete()
+}
+
+func (i *Image) Save(additionalNames ...string) error {
+	return i.SaveAs(i.Name(), additionalNames...)
+}
+
+func (i *Image) SaveWithContext(ctx context.Context, additionalNames ...string) error {
+	return i.SaveAsWithContext(ctx, i.Name(), additionalNames...)
+}
+
+// SaveAs ignores the image `Name()` method and saves the image according to name & additional names provided to this method
+func (i *Image) SaveAs(name string, additionalNames ...string) error {
+	if err := i.SetCreatedAtAndHistory(); err != nil {
+		return err
+	}
+	// images are immutable, so the store is not affected by further changes,
+	// and their layers are read now, so the store does not depend on the files they were added from
+	image, err := materialize(i.UnderlyingImage())
+	if err != nil {
+		return err
+	}
+
+	var diagnostics []imgutil.SaveDiagnostic
+	for _, n := range append([]string{name}, additionalNames...) {
+		if err := i.doSave(n, image); err != nil {
+			diagnostics = append(diagnostics, imgutil.SaveDiagnostic{ImageName: n, Cause: err})
+		}
+	}
+	if len(diagnostics) > 0 {
+		return imgutil.SaveError{Errors: diagnostics}
+	}
+	return nil
+}
+
+func (i *Image) SaveAsWithContext(ctx context.Context, name string, additionalNames ...string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return i.SaveAs(name, additionalNames...)
+}
+
+func (i *Image) doSave(imageName string, image v1.Image) error {
+	ref, err := name.ParseReference(imageName, name.WeakValidation)
+	if err != nil {
+		return fmt.Errorf("could not parse reference: %w", err)
+	}
+	_, err = i.store.save(ref, image)
+	return err
+}
+
+// SaveFile saves the image as a tar file in the format chosen with imgutil.WithArchiveFormat (a docker archive by default),
+// and returns the path of the file.
+// Like Save, it sets the created at time and history of the image.
+func (i *Image) SaveFile() (string, error) {
+	if err := i.SetCreatedAtAndHistory(); err != nil {
+		return "", err
+	}
+	return imgutil.SaveArchiveFile(i.CNBImageCore, i.Name(), i.archiveFormat)
+}
+
+// materialize returns a copy of the image with the same manifest, whose config and layers are held in memory.
+func materialize(image v1.Image) (v1.Image, error) {
+	rawManifest, err := image.RawManifest()
+	if err != nil {
+		return nil, err
+	}
+	rawConfig, err := image.RawConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	mediaType, err := image.MediaType()
+	if err != nil {
+		return nil, err
+	}
+	layers, err := image.Layers()
+	if err != nil {
+		return nil, err
+	}
+	stored := &storedImage{
+		rawManifest: rawManifest,
+		rawConfig:   rawConfig,
+		mediaType:   mediaType,
+		layers:      make(map[v1.Hash]*storedLayer, len(layers)),
+	}
+	for _, layer := range layers {
+		storedLayer, err := materializeLayer(layer)
+		if err != nil {
+			return nil, err
+		}
+		stored.layers[storedLayer.digest] = storedLayer
+	}
+	return partial.CompressedToImage(stored)
+}
+
+func materializeLayer(layer v1.Layer) (*storedLayer, error) {
+	digest, err := layer.Digest()
+	if err != nil {
+		return nil, err
+	}
+	diffID, err := layer.DiffID()
+	if err != nil {
+		return nil, err
+	}
+	mediaType, err := layer.MediaType()
+	if err != nil {
+		return nil, err
+	}
+	rc, err := layer.Compressed()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read layer %s: %w", digest, err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read layer %s: %w", digest, err)
+	}
+	return &storedLayer{digest: digest, diffID: diffID, mediaType: mediaType, data: data}, nil
+}
+
+// storedImage implements partial.CompressedImageCore for an image held in memory.
+type storedImage struct {
+	rawManifest []byte
+	rawConfig   []byte
+	mediaType   types.MediaType
+	layers      map[v1.Hash]*storedLayer
+}
+
+func (i *storedImage) RawConfigFile() ([]byte, error) {
+	return i.rawConfig, nil
+}
+
+func (i *storedImage) MediaType() (types.MediaType, error) {
+	return i.mediaType, nil
+}
+
+func (i *storedImage) RawManifest() ([]byte, error) {
+	return i.rawManifest, nil
+}
+
+func (i *storedImage) LayerByDigest(digest v1.Hash) (partial.CompressedLayer, error) {
+	layer, ok := i.layers[digest]
+	if !ok {
+		return nil, fmt.Errorf("failed to find layer with digest %s", digest)
+	}
+	return layer, nil
+}
+
+type storedLayer struct {
+	digest    v1.Hash
+	diffID    v1.Hash
+	mediaType types.MediaType
+	data      []byte
+}
+
+func (l *storedLayer) Digest() (v1.Hash, error) {
+	return l.digest, nil
+}
+
+// DiffID is provided so that it is not computed by decompressing the layer.
+func (l *storedLayer) DiffID() (v1.Hash, error) {
+	return l.diffID, nil
+}
+
+func (l *storedLayer) Compressed() (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader(l.data)), nil
+}
+
+func (l *storedLayer) Size() (int64, error) {
+	return int64(len(l.data)), nil
+}
+
+func (l *storedLayer) MediaType() (types.MediaType, error) {
+	return l.mediaType, nil
+}
@@ -0,0 +1,285 @@
+package memory_test
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil"
+	"github.com/buildpacks/imgutil/memory"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestMemory(t *testing.T) {
+	spec.Run(t, "Image", testImage, spec.Sequential(), spec.Report(report.Terminal{}))
+}
+
+func testImage(t *testing.T, when spec.G, it spec.S) {
+	var (
+		store     *memory.Store
+		tmpDir    string
+		layerPath string
+		layerSHA  string
+		err       error
+	)
+
+	it.Before(func() {
+		store = memory.NewStore()
+		tmpDir, err = os.MkdirTemp("", "memory")
+		h.AssertNil(t, err)
+		layerPath, layerSHA, _ = h.RandomLayer(t, tmpDir)
+	})
+
+	it.After(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	// saveBaseImage saves an image with a label and a layer to the store
+	saveBaseImage := func(repoName string) *memory.Image {
+		baseImage, err := memory.NewImage(repoName, store)
+		h.AssertNil(t, err)
+		h.AssertNil(t, baseImage.SetLabel("some-label", "some-value"))
+		h.AssertNil(t, baseImage.AddLayer(layerPath))
+		h.AssertNil(t, baseImage.Save())
+		return baseImage
+	}
+
+	when("#NewImage", func() {
+		it("returns an empty image for the default platform", func() {
+			img, err := memory.NewImage("some/image", store)
+			h.AssertNil(t, err)
+
+			h.AssertEq(t, img.Kind(), "memory")
+			h.AssertEq(t, img.Found(), false)
+			osName, err := img.OS()
+			h.AssertNil(t, err)
+			h.AssertEq(t, osName, "linux")
+			layers, err := img.Layers()
+			h.AssertNil(t, err)
+			h.AssertEq(t, len(layers), 0)
+		})
+
+		when("#FromBaseImage", func() {
+			it("sets the initial state from the base image in the store", func() {
+				baseImage := saveBaseImage("some/base-image")
+
+				img, err := memory.NewImage("some/image", store, imgutil.FromBaseImage("some/base-image"))
+				h.AssertNil(t, err)
+
+				label, err := img.Label("some-label")
+				h.AssertNil(t, err)
+				h.AssertEq(t, label, "some-value")
+				topLayer, err := img.TopLayer()
+				h.AssertNil(t, err)
+				h.AssertEq(t, topLayer, layerSHA)
+				baseID, err := baseImage.Identifier()
+				h.AssertNil(t, err)
+				h.AssertEq(t, img.UnderlyingImage() != nil, true)
+
+				rc, err := img.GetLayer(layerSHA)
+				h.AssertNil(t, err)
+				h.AssertNil(t, rc.Close())
+
+				h.AssertNil(t, img.Save())
+				id, err := img.Identifier()
+				h.AssertNil(t, err)
+				h.AssertNotEq(t, id.String(), baseID.String())
+			})
+
+			when("the base image does not exist", func() {
+				it("returns an empty image", func() {
+					img, err := memory.NewImage("some/image", store, imgutil.FromBaseImage("some/does-not-exist"))
+					h.AssertNil(t, err)
+
+					layers, err := img.Layers()
+					h.AssertNil(t, err)
+					h.AssertEq(t, len(layers), 0)
+				})
+
+				it("returns an error with #WithStrictBaseImage", func() {
+					_, err := memory.NewImage("some/image", store, imgutil.FromBaseImage("some/does-not-exist"), imgutil.WithStrictBaseImage())
+					h.AssertError(t, err, `failed to find image "some/does-not-exist"`)
+					h.AssertEq(t, errors.Is(err, imgutil.ErrImageNotFound), true)
+				})
+			})
+		})
+
+		when("#WithPreviousImage", func() {
+			it("reuses layers from the previous image", func() {
+				saveBaseImage("some/image")
+
+				img, err := memory.NewImage("some/image", store, imgutil.WithPreviousImage("some/image"))
+				h.AssertNil(t, err)
+				h.AssertNil(t, img.ReuseLayer(layerSHA))
+				h.AssertNil(t, img.Save())
+
+				saved, ok := store.Lookup("some/image")
+				h.AssertEq(t, ok, true)
+				assertDiffIDs(t, saved, layerSHA)
+			})
+
+			when("the previous image does not exist", func() {
+				it("returns an error with #WithStrictPreviousImage", func() {
+					_, err := memory.NewImage("some/image", store, imgutil.WithPreviousImage("some/image"), imgutil.WithStrictPreviousImage())
+					h.AssertEq(t, errors.Is(err, imgutil.ErrImageNotFound), true)
+				})
+			})
+		})
+	})
+
+	when("#Save", func() {
+		it("stores the image under each name with its digest", func() {
+			img, err := memory.NewImage("some/image", store)
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.AddLayer(layerPath))
+
+			h.AssertNil(t, img.Save("some/image:other-tag"))
+
+			h.AssertEq(t, img.Found(), true)
+			h.AssertEq(t, img.Valid(), true)
+			h.AssertEq(t, store.Tags(), []string{
+				"index.docker.io/some/image:latest",
+				"index.docker.io/some/image:other-tag",
+			})
+			saved, ok := store.Lookup("some/image:other-tag")
+			h.AssertEq(t, ok, true)
+			digest, err := saved.Digest()
+			h.AssertNil(t, err)
+			id, err := img.Identifier()
+			h.AssertNil(t, err)
+			h.AssertEq(t, id.String(), "index.docker.io/some/image@"+digest.String())
+			h.AssertEq(t, store.Contains(id.String()), true)
+			assertDiffIDs(t, saved, layerSHA)
+		})
+
+		it("is not affected by later changes to the image", func() {
+			img, err := memory.NewImage("some/image", store)
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.Save())
+
+			h.AssertNil(t, img.SetLabel("some-label", "some-value"))
+
+			saved, ok := store.Lookup("some/image")
+			h.AssertEq(t, ok, true)
+			configFile, err := saved.ConfigFile()
+			h.AssertNil(t, err)
+			h.AssertEq(t, configFile.Config.Labels["some-label"], "")
+		})
+
+		it("does not depend on the files the layers were added from", func() {
+			img, err := memory.NewImage("some/image", store)
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.AddLayer(layerPath))
+			h.AssertNil(t, img.Save())
+
+			h.AssertNil(t, os.Remove(layerPath))
+
+			saved, ok := store.Lookup("some/image")
+			h.AssertEq(t, ok, true)
+			diffID, err := v1.NewHash(layerSHA)
+			h.AssertNil(t, err)
+			layer, err := saved.LayerByDiffID(diffID)
+			h.AssertNil(t, err)
+			rc, err := layer.Uncompressed()
+			h.AssertNil(t, err)
+			defer rc.Close()
+			uncompressedDiffID, _, err := v1.SHA256(rc)
+			h.AssertNil(t, err)
+			h.AssertEq(t, uncompressedDiffID.String(), layerSHA)
+		})
+
+		it("returns an error for an invalid name", func() {
+			img, err := memory.NewImage("some/image", store)
+			h.AssertNil(t, err)
+
+			err = img.Save("some/image:bad tag")
+			h.AssertError(t, err, "could not parse reference")
+			h.AssertEq(t, img.Found(), true)
+		})
+	})
+
+	when("#Rebase", func() {
+		it("replaces the layers of the old base image with the new base image", func() {
+			saveBaseImage("some/old-base")
+			oldBase, err := memory.NewImage("some/old-base", store, imgutil.FromBaseImage("some/old-base"))
+			h.AssertNil(t, err)
+			oldTopLayer, err := oldBase.TopLayer()
+			h.AssertNil(t, err)
+
+			img, err := memory.NewImage("some/image", store, imgutil.FromBaseImage("some/old-base"))
+			h.AssertNil(t, err)
+			appLayerPath, appLayerSHA, _ := h.RandomLayer(t, tmpDir)
+			h.AssertNil(t, img.AddLayer(appLayerPath))
+
+			newBase, err := memory.NewImage("some/new-base", store)
+			h.AssertNil(t, err)
+			newBaseLayerPath, newBaseLayerSHA, _ := h.RandomLayer(t, tmpDir)
+			h.AssertNil(t, newBase.AddLayer(newBaseLayerPath))
+			h.AssertNil(t, newBase.Save())
+
+			h.AssertNil(t, img.Rebase(oldTopLayer, newBase))
+			h.AssertNil(t, img.Save())
+
+			saved, ok := store.Lookup("some/image")
+			h.AssertEq(t, ok, true)
+			assertDiffIDs(t, saved, newBaseLayerSHA, appLayerSHA)
+		})
+	})
+
+	when("#SaveFile", func() {
+		it("writes the image to an archive", func() {
+			img, err := memory.NewImage("some/image", store)
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.AddLayer(layerPath))
+
+			path, err := img.SaveFile()
+			h.AssertNil(t, err)
+			defer os.Remove(path)
+
+			h.AssertPathExists(t, path)
+			h.AssertEq(t, img.Found(), false)
+		})
+	})
+
+	when("#Delete", func() {
+		it("removes the image and its tags", func() {
+			img, err := memory.NewImage("some/image", store)
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.Save("some/image:other-tag"))
+			other, err := memory.NewImage("some/other-image", store)
+			h.AssertNil(t, err)
+			h.AssertNil(t, other.SetLabel("some-label", "some-value"))
+			h.AssertNil(t, other.Save())
+
+			h.AssertNil(t, img.Delete())
+
+			h.AssertEq(t, img.Found(), false)
+			h.AssertEq(t, store.Contains("some/image:other-tag"), false)
+			h.AssertEq(t, store.Tags(), []string{"index.docker.io/some/other-image:latest"})
+		})
+
+		it("returns an error if the image does not exist", func() {
+			img, err := memory.NewImage("some/image", store)
+			h.AssertNil(t, err)
+
+			err = img.Delete()
+			h.AssertError(t, err, "not found")
+			h.AssertEq(t, errors.Is(err, imgutil.ErrImageNotFound), true)
+		})
+	})
+}
+
+func assertDiffIDs(t *testing.T, image v1.Image, expected ...string) {
+	t.Helper()
+	configFile, err := image.ConfigFile()
+	h.AssertNil(t, err)
+	var diffIDs []string
+	for _, diffID := range configFile.RootFS.DiffIDs {
+		diffIDs = append(diffIDs, diffID.String())
+	}
+	h.AssertEq(t, diffIDs, expected)
+}
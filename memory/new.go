@@ -0,0 +1,69 @@
+package memory
+
+import (
+	"fmt"
+
+	"github.com/buildpacks/imgutil"
+)
+
+// NewImage returns a new image that can be modified and saved to the provided in-memory store.
+// Base and previous images (provided with imgutil.FromBaseImage and imgutil.WithPreviousImage) are looked up in the store.
+func NewImage(repoName string, store *Store, ops ...imgutil.ImageOption) (*Image, error) {
+	options := &imgutil.ImageOptions{}
+	for _, op := range ops {
+		op(options)
+	}
+
+	options.Platform = processPlatformOption(options.Platform)
+
+	if options.BaseImage == nil && options.BaseImageRepoName != "" { // options.BaseImage supersedes options.BaseImageRepoName
+		if baseImage, ok := store.Lookup(options.BaseImageRepoName); ok {
+			digest, err := baseImage.Digest()
+			if err != nil {
+				return nil, err
+			}
+			options.BaseImage = baseImage
+			options.BaseImageDigest = digest.String()
+		} else if options.StrictBaseImage {
+			return nil, fmt.Errorf("failed to find image %q in the store: %w", options.BaseImageRepoName, imgutil.ErrImageNotFound)
+		}
+	}
+	options.MediaTypes = imgutil.GetPreferredMediaTypes(*options)
+	if options.BaseImage != nil {
+		var err error
+		options.BaseImage, _, err = imgutil.EnsureMediaTypesAndLayers(options.BaseImage, options.MediaTypes, imgutil.PreserveLayers)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if options.PreviousImage == nil && options.PreviousImageRepoName != "" {
+		if previousImage, ok := store.Lookup(options.PreviousImageRepoName); ok {
+			options.PreviousImage = previousImage
+		} else if options.StrictPreviousImage {
+			return nil, fmt.Errorf("failed to find image %q in the store: %w", options.PreviousImageRepoName, imgutil.ErrImageNotFound)
+		}
+	}
+
+	cnbImage, err := imgutil.NewCNBImage(*options)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Image{
+		CNBImageCore:  cnbImage,
+		repoName:      repoName,
+		store:         store,
+		archiveFormat: options.ArchiveFormat,
+	}, nil
+}
+
+func processPlatformOption(requestedPlatform imgutil.Platform) imgutil.Platform {
+	if !requestedPlatform.IsZero() {
+		return requestedPlatform
+	}
+	return imgutil.Platform{
+		OS:           "linux",
+		Architecture: "amd64",
+	}
+}
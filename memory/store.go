@@ -0,0 +1,119 @@
+package memory
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+
+	"github.com/buildpacks/imgutil"
+)
+
+// Store is an in-process, registry-like store of images.
+// Images are stored by tag and can be looked up by tag or by digest.
+// It is safe for concurrent use.
+type Store struct {
+	mutex  sync.RWMutex
+	tags   map[string]v1.Hash  // tag reference (e.g., "index.docker.io/some/repo:latest") to digest
+	images map[string]v1.Image // digest reference (e.g., "index.docker.io/some/repo@sha256:...") to image
+}
+
+func NewStore() *Store {
+	return &Store{
+		tags:   make(map[string]v1.Hash),
+		images: make(map[string]v1.Image),
+	}
+}
+
+// Lookup returns the image with the provided name (a tag or digest reference), if it exists.
+func (s *Store) Lookup(repoName string) (v1.Image, bool) {
+	ref, err := name.ParseReference(repoName, name.WeakValidation)
+	if err != nil {
+		return nil, false
+	}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	image, ok := s.images[s.digestRefFor(ref)]
+	return image, ok
+}
+
+// Contains reports if an image with the provided name (a tag or digest reference) exists.
+func (s *Store) Contains(repoName string) bool {
+	_, ok := s.Lookup(repoName)
+	return ok
+}
+
+// Tags returns the tag references of all images in the store, sorted.
+func (s *Store) Tags() []string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	var tags []string
+	for tag := range s.tags {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// Delete removes the image with the provided name (a tag or digest reference),
+// along with all the tags of its repository that reference it.
+func (s *Store) Delete(repoName string) error {
+	ref, err := name.ParseReference(repoName, name.WeakValidation)
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	digestRef := s.digestRefFor(ref)
+	if _, ok := s.images[digestRef]; !ok {
+		return fmt.Errorf("failed to find image %q in the store: %w", repoName, imgutil.ErrImageNotFound)
+	}
+	delete(s.images, digestRef)
+	for tag, digest := range s.tags {
+		if digestRefName(tag, digest) == digestRef {
+			delete(s.tags, tag)
+		}
+	}
+	return nil
+}
+
+// save stores the image with the provided tag (or digest) reference.
+func (s *Store) save(ref name.Reference, image v1.Image) (v1.Hash, error) {
+	digest, err := image.Digest()
+	if err != nil {
+		return v1.Hash{}, err
+	}
+	if digestRef, ok := ref.(name.Digest); ok && digestRef.DigestStr() != digest.String() {
+		return v1.Hash{}, fmt.Errorf("image digest %s does not match reference %q", digest, ref)
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.images[ref.Context().Digest(digest.String()).Name()] = image
+	if _, ok := ref.(name.Tag); ok {
+		s.tags[ref.Name()] = digest
+	}
+	return digest, nil
+}
+
+// digestRefFor returns the digest reference for the provided reference, or empty if the tag is unknown.
+// The caller must hold the mutex.
+func (s *Store) digestRefFor(ref name.Reference) string {
+	if _, ok := ref.(name.Digest); ok {
+		return ref.Name()
+	}
+	digest, ok := s.tags[ref.Name()]
+	if !ok {
+		return ""
+	}
+	return digestRefName(ref.Name(), digest)
+}
+
+func digestRefName(tag string, digest v1.Hash) string {
+	ref, err := name.NewTag(tag, name.WeakValidation)
+	if err != nil {
+		return ""
+	}
+	return ref.Context().Digest(digest.String()).Name()
+}
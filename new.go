@@ -18,11 +18,18 @@ import (
 
 func NewCNBImage(options ImageOptions) (*CNBImageCore, error) {
 	image := &CNBImageCore{
-		Image:               options.BaseImage, // the working image
-		createdAt:           getCreatedAt(options),
-		preferredMediaTypes: GetPreferredMediaTypes(options),
-		preserveHistory:     options.PreserveHistory,
-		previousImage:       options.PreviousImage,
+		Image:                options.BaseImage, // the working image
+		baseImageAnnotations: options.BaseImageAnnotations,
+		createdAt:            getCreatedAt(options),
+		estargz:              options.Estargz,
+		layerCompression:     options.LayerCompression,
+		preferredMediaTypes:  GetPreferredMediaTypes(options),
+		preserveHistory:      options.PreserveHistory,
+		previousImage:        options.PreviousImage,
+	}
+	if options.BaseImage != nil && options.BaseImageDigest != "" {
+		image.baseImageName = options.BaseImageRepoName
+		image.baseImageDigest = options.BaseImageDigest
 	}
 
 	// ensure base image
@@ -48,9 +55,29 @@ func NewCNBImage(options ImageOptions) (*CNBImageCore, error) {
 		}
 	}
 
+	// set base image annotations if requested
+	if options.BaseImageAnnotations && image.baseImageName != "" {
+		if err = image.setBaseImageAnnotations(); err != nil {
+			return nil, err
+		}
+	}
+
 	return image, nil
 }
 
+func NewCNBIndex(options ImageOptions) (*CNBIndex, error) {
+	index := &CNBIndex{
+		index: options.BaseIndex, // the working index
+	}
+	if index.index == nil {
+		index.index = empty.Index // an OCI image index
+	}
+	if indexType := options.MediaTypes.IndexType(); indexType != "" {
+		index.index = mutate.IndexMediaType(index.index, indexType)
+	}
+	return index, nil
+}
+
 func getCreatedAt(options ImageOptions) time.Time {
 	if !options.CreatedAt.IsZero() {
 		return options.CreatedAt
@@ -91,6 +118,17 @@ func (t MediaTypes) ManifestType() types.MediaType {
 	}
 }
 
+func (t MediaTypes) IndexType() types.MediaType {
+	switch t {
+	case OCITypes:
+		return types.OCIImageIndex
+	case DockerTypes:
+		return types.DockerManifestList
+	default:
+		return ""
+	}
+}
+
 func (t MediaTypes) ConfigType() types.MediaType {
 	switch t {
 	case OCITypes:
@@ -119,6 +157,8 @@ func emptyV1(withPlatform Platform, withMediaTypes MediaTypes) (v1.Image, error)
 		History:      []v1.History{},
 		OS:           withPlatform.OS,
 		OSVersion:    withPlatform.OSVersion,
+		Variant:      withPlatform.Variant,
+		OSFeatures:   withPlatform.OSFeatureList(),
 		RootFS: v1.RootFS{
 			Type:    "layers",
 			DiffIDs: []v1.Hash{},
@@ -197,6 +237,19 @@ func EnsureMediaTypesAndLayers(image v1.Image, requestedTypes MediaTypes, mutate
 	if err != nil {
 		return nil, false, err
 	}
+	// keep annotations of the layers that were not recompressed (e.g., eStargz annotations)
+	for idx := range additions {
+		if idx >= len(beforeManifest.Layers) {
+			break
+		}
+		digest, err := layersToAdd[idx].Digest()
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to get layer digest: %w", err)
+		}
+		if digest == beforeManifest.Layers[idx].Digest {
+			additions[idx].Annotations = beforeManifest.Layers[idx].Annotations
+		}
+	}
 	retImage, err = mutate.Append(retImage, additions...)
 	if err != nil {
 		return nil, false, fmt.Errorf("failed to append layers: %w", err)
@@ -224,7 +277,7 @@ func layersAddendum(layers []v1.Layer, history []v1.History, requestedType types
 	}
 	var err error
 	for idx, l := range layers {
-		layerType := requestedType
+		layerType := layerTypeFor(l, requestedType)
 		if requestedType == "" {
 			// try to get a non-empty media type
 			if layerType, err = l.MediaType(); err != nil {
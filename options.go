@@ -1,6 +1,8 @@
 package imgutil
 
 import (
+	"context"
+	"net/http"
 	"time"
 
 	v1 "github.com/google/go-containerregistry/pkg/v1"
@@ -9,22 +11,39 @@ import (
 type ImageOption func(*ImageOptions)
 
 type ImageOptions struct {
+	ArchiveFormat         ArchiveFormat
+	BaseImageAnnotations  bool
 	BaseImageRepoName     string
+	BaseIndexRepoName     string
 	PreviousImageRepoName string
 	Config                *v1.Config
+	Context               context.Context
 	CreatedAt             time.Time
+	Estargz               bool
+	LayerCompression      LayerCompression
 	MediaTypes            MediaTypes
 	Platform              Platform
 	PreserveHistory       bool
+	Progress              ProgressFunc
+	StrictBaseImage       bool
+	StrictPreviousImage   bool
 	LayoutOptions
 	RemoteOptions
 
 	// These options must be specified in each implementation's image constructor
 	BaseImage     v1.Image
 	PreviousImage v1.Image
+	// BaseImageDigest is the digest of the base image manifest as found in its store (before any media type conversion),
+	// or empty if the base image was not found or its digest is unknown.
+	BaseImageDigest string
+
+	// This option must be specified in each implementation's index constructor
+	BaseIndex v1.ImageIndex
 }
 
 type LayoutOptions struct {
+	// LayerResolver returns the layer with the provided digest, for layers that are not present in the layout.
+	LayerResolver  func(digest v1.Hash) (v1.Layer, error)
 	PreserveDigest bool
 	WithoutLayers  bool
 }
@@ -32,20 +51,66 @@ type LayoutOptions struct {
 type RemoteOptions struct {
 	RegistrySettings    map[string]RegistrySetting
 	AddEmptyLayerOnSave bool
+	RetryPolicy         RetryPolicy
+	Transport           http.RoundTripper
+	PushReport          PushReportFunc
 }
 
 type RegistrySetting struct {
 	Insecure bool
+	// CACertificates are PEM-encoded certificates of authorities to trust, in addition to the system ones,
+	// when verifying the certificate of the registry.
+	CACertificates [][]byte
+	// ClientCertificate and ClientKey are the PEM-encoded certificate and key to authenticate to the registry with (mTLS).
+	ClientCertificate []byte
+	ClientKey         []byte
+	// Proxy is the URL of the proxy for requests to the registry; if empty, the proxy is taken from the environment.
+	Proxy string
+	// Transport is the transport for requests to the registry, overriding RemoteOptions.Transport.
+	Transport http.RoundTripper
+	// Mirrors are registries (e.g., "mirror.example.com" or "mirror.example.com/some-path") that images are read from,
+	// in order, before falling back to the registry of the image. They are not used to save or delete images.
+	Mirrors []string
+	// Rewrite replaces the prefix the setting is registered with when reading images
+	// (e.g., "mirror.example.com/dockerhub" for "docker.io/library").
+	Rewrite string
+}
+
+// WithArchiveFormat lets a caller choose the format of the tar file written by SaveFile.
+// If not provided, the default is DockerArchive, except for the `layout` implementation which defaults to OCIArchive.
+// The option is ignored by the `local` implementation, which always writes a docker archive.
+func WithArchiveFormat(f ArchiveFormat) func(*ImageOptions) {
+	return func(o *ImageOptions) {
+		o.ArchiveFormat = f
+	}
 }
 
 // FromBaseImage loads the provided image as the manifest, config, and layers for the working image.
-// If the image is not found, it does nothing.
+// If the image is not found, it does nothing, unless WithStrictBaseImage is provided.
 func FromBaseImage(name string) func(*ImageOptions) {
 	return func(o *ImageOptions) {
 		o.BaseImageRepoName = name
 	}
 }
 
+// WithBaseImageAnnotations if provided will configure the image to carry the `org.opencontainers.image.base.name`
+// and `org.opencontainers.image.base.digest` annotations when saved, referencing the image provided with FromBaseImage.
+// The annotations are updated when the image is rebased.
+// If the base image is not found, or its digest cannot be determined, the annotations are not set.
+func WithBaseImageAnnotations() func(*ImageOptions) {
+	return func(o *ImageOptions) {
+		o.BaseImageAnnotations = true
+	}
+}
+
+// FromBaseIndex loads the provided index as the manifest and referenced images for the working index.
+// If the index is not found, it does nothing.
+func FromBaseIndex(name string) func(*ImageOptions) {
+	return func(o *ImageOptions) {
+		o.BaseIndexRepoName = name
+	}
+}
+
 // WithConfig lets a caller provided a `config` object for the working image.
 func WithConfig(c *v1.Config) func(*ImageOptions) {
 	return func(o *ImageOptions) {
@@ -53,6 +118,17 @@ func WithConfig(c *v1.Config) func(*ImageOptions) {
 	}
 }
 
+// WithContext lets a caller provide a context that bounds all network and daemon calls made by the image,
+// including when the image is constructed.
+// Methods without a context argument (e.g., Save) use this context; methods with a context argument (e.g., SaveWithContext)
+// use the provided context instead, although lazily fetched layer data is always bound to this context.
+// If not provided, the default is context.Background().
+func WithContext(ctx context.Context) func(*ImageOptions) {
+	return func(o *ImageOptions) {
+		o.Context = ctx
+	}
+}
+
 // WithCreatedAt lets a caller set the "created at" timestamp for the working image when saved.
 // If not provided, the default is NormalizedDateTime.
 func WithCreatedAt(t time.Time) func(*ImageOptions) {
@@ -61,14 +137,26 @@ func WithCreatedAt(t time.Time) func(*ImageOptions) {
 	}
 }
 
-// WithDefaultPlatform provides the default Architecture/OS/OSVersion if no base image is provided,
-// or if the provided image inputs (base and previous) are manifest lists.
+// WithDefaultPlatform provides the default Architecture/OS/OSVersion/Variant/OSFeatures if no base image is provided,
+// or if the provided image inputs (base and previous) are manifest lists,
+// in which case the best matching image is used (see FindManifestForPlatform).
 func WithDefaultPlatform(p Platform) func(*ImageOptions) {
 	return func(o *ImageOptions) {
 		o.Platform = p
 	}
 }
 
+// WithEstargz if provided will cause layers added to the working image to be written as eStargz layers
+// that can be lazily pulled, with the TOC digest annotation on their manifest descriptors.
+// The config of the image keeps the DiffIDs of the added tars, so that layers can still be found by those DiffIDs
+// (in GetLayer, ReuseLayer, PreviousImageHasLayer and Rebase).
+// The option is ignored by the `local` implementation.
+func WithEstargz() func(*ImageOptions) {
+	return func(o *ImageOptions) {
+		o.Estargz = true
+	}
+}
+
 // WithHistory if provided will configure the image to preserve history when saved
 // (including any history from the base image if valid).
 func WithHistory() func(*ImageOptions) {
@@ -77,6 +165,17 @@ func WithHistory() func(*ImageOptions) {
 	}
 }
 
+// WithLayerCompression lets a caller choose how layers are compressed when written.
+// Layers added to the working image are compressed when added, and the `remote`, `layout` and `archive` implementations
+// recompress the layers from base and previous images when saving, unless they are already compressed with the requested algorithm.
+// If not provided, added layers are compressed with gzip and other layers are kept as they are.
+// Note that zstd compressed layers always use the OCI media type, and that the option is ignored by the `local` implementation.
+func WithLayerCompression(c LayerCompression) func(*ImageOptions) {
+	return func(o *ImageOptions) {
+		o.LayerCompression = c
+	}
+}
+
 // WithMediaTypes lets a caller set the desired media types for the manifest and config (including layers referenced in the manifest)
 // to be either OCI media types or Docker media types.
 func WithMediaTypes(m MediaTypes) func(*ImageOptions) {
@@ -87,9 +186,46 @@ func WithMediaTypes(m MediaTypes) func(*ImageOptions) {
 
 // WithPreviousImage loads an existing image as the source for reusable layers.
 // Use with ReuseLayer().
-// If the image is not found, it does nothing.
+// If the image is not found, it does nothing, unless WithStrictPreviousImage is provided.
 func WithPreviousImage(name string) func(*ImageOptions) {
 	return func(o *ImageOptions) {
 		o.PreviousImageRepoName = name
 	}
 }
+
+// WithProgress lets a caller receive progress updates for each layer written when the image is saved
+// (layers mounted or already present in a registry are not reported by the `remote` implementation),
+// and for all the layers downloaded from the daemon by the `local` implementation.
+// To receive updates on a channel, send them from the provided function.
+func WithProgress(f ProgressFunc) func(*ImageOptions) {
+	return func(o *ImageOptions) {
+		o.Progress = f
+	}
+}
+
+// WithRetryPolicy lets a caller choose how the `remote` implementation retries failed registry operations
+// (reading images, pushing and deleting them).
+// If not provided, or if the policy has no attempts, DefaultRetryPolicy is used.
+func WithRetryPolicy(policy RetryPolicy) func(*ImageOptions) {
+	return func(o *ImageOptions) {
+		o.RetryPolicy = policy
+	}
+}
+
+// WithStrictBaseImage makes the image constructors fail if the image provided with FromBaseImage cannot be found
+// (or, for the `remote` implementation, cannot be accessed or has no image for the platform),
+// instead of starting from an empty image.
+// The error wraps ErrImageNotFound, ErrUnauthorized or ErrPlatformMismatch.
+func WithStrictBaseImage() func(*ImageOptions) {
+	return func(o *ImageOptions) {
+		o.StrictBaseImage = true
+	}
+}
+
+// WithStrictPreviousImage makes the image constructors fail if the image provided with WithPreviousImage cannot be found,
+// like WithStrictBaseImage does for the base image.
+func WithStrictPreviousImage() func(*ImageOptions) {
+	return func(o *ImageOptions) {
+		o.StrictPreviousImage = true
+	}
+}
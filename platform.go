@@ -0,0 +1,214 @@
+package imgutil
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// IsZero returns true if no field of the platform is set.
+func (p Platform) IsZero() bool {
+	return p == Platform{}
+}
+
+// JoinOSFeatures returns the provided OS features in the form of Platform.OSFeatures:
+// sorted, without duplicates, and separated by commas.
+func JoinOSFeatures(features []string) string {
+	sorted := slices.Clone(features)
+	slices.Sort(sorted)
+	return strings.Join(slices.Compact(sorted), ",")
+}
+
+// OSFeatureList returns the OS features of the platform as a list.
+func (p Platform) OSFeatureList() []string {
+	if p.OSFeatures == "" {
+		return nil
+	}
+	return strings.Split(p.OSFeatures, ",")
+}
+
+// String returns the platform as "os/architecture[/variant][:os.version]" (e.g., "linux/arm/v7"),
+// followed by the OS features, if any.
+func (p Platform) String() string {
+	s := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+	if p.OSVersion != "" {
+		s += ":" + p.OSVersion
+	}
+	if p.OSFeatures != "" {
+		s += " (" + p.OSFeatures + ")"
+	}
+	return s
+}
+
+// Matches returns true if an image for the candidate platform can be used for the platform, that is if:
+//   - the OS and architecture are the same
+//   - the variant, if set, is the same or, for arm architectures, an earlier one (e.g., "v6" for "v7");
+//     the arm64 variant defaults to "v8"
+//   - the OS version, if set, is the same or, for Windows, has the same build number
+//     (e.g., "10.0.17763.1397" for "10.0.17763.5329")
+//   - the OS features, if set, are all supported
+//
+// Empty fields of the platform match any value.
+func (p Platform) Matches(candidate v1.Platform) bool {
+	if p.OS != "" && candidate.OS != p.OS {
+		return false
+	}
+	if p.Architecture != "" && normalizeArchitecture(candidate.Architecture) != normalizeArchitecture(p.Architecture) {
+		return false
+	}
+	if p.Variant != "" && !variantMatches(normalizeArchitecture(p.Architecture), p.Variant, candidate.Variant) {
+		return false
+	}
+	if p.OSVersion != "" && candidate.OSVersion != "" && !osVersionMatches(p.OS, p.OSVersion, candidate.OSVersion) {
+		return false
+	}
+	for _, feature := range p.OSFeatureList() {
+		if !slices.Contains(candidate.OSFeatures, feature) {
+			return false
+		}
+	}
+	return true
+}
+
+// FindManifestForPlatform returns the descriptor of the image (or nested index) that best matches the platform
+// among the provided descriptors of an index: the one with the requested variant and OS version, if any,
+// or else the one with the latest compatible variant and OS version; otherwise the first matching one.
+// If none matches, the error wraps ErrPlatformMismatch and lists the available platforms.
+func FindManifestForPlatform(descs []v1.Descriptor, platform Platform) (v1.Descriptor, error) {
+	var (
+		found     *v1.Descriptor
+		available []string
+	)
+	for idx, desc := range descs {
+		if desc.Platform == nil {
+			continue
+		}
+		available = append(available, PlatformFrom(*desc.Platform).String())
+		if !platform.Matches(*desc.Platform) {
+			continue
+		}
+		if found == nil || platform.prefers(*desc.Platform, *found.Platform) {
+			found = &descs[idx]
+		}
+	}
+	if found == nil {
+		if len(available) == 0 {
+			return v1.Descriptor{}, fmt.Errorf("failed to find manifest matching platform %s: no platforms available: %w", platform, ErrPlatformMismatch)
+		}
+		return v1.Descriptor{}, fmt.Errorf("failed to find manifest matching platform %s: available platforms are %s: %w",
+			platform, strings.Join(available, ", "), ErrPlatformMismatch)
+	}
+	return *found, nil
+}
+
+// PlatformFrom returns the platform of an index descriptor or image config.
+func PlatformFrom(platform v1.Platform) Platform {
+	return Platform{
+		Architecture: platform.Architecture,
+		OS:           platform.OS,
+		OSVersion:    platform.OSVersion,
+		Variant:      platform.Variant,
+		OSFeatures:   JoinOSFeatures(platform.OSFeatures),
+	}
+}
+
+// prefers returns true if an image for platform a is a better match for the platform than an image for platform b,
+// both matching it: the requested variant and OS version are preferred, then the latest ones.
+func (p Platform) prefers(a, b v1.Platform) bool {
+	arch := normalizeArchitecture(p.Architecture)
+	if requested := variantOf(arch, p.Variant); requested != "" {
+		aVariant, bVariant := variantOf(arch, a.Variant), variantOf(arch, b.Variant)
+		if (aVariant == requested) != (bVariant == requested) {
+			return aVariant == requested
+		}
+		if variantNumber(aVariant) != variantNumber(bVariant) {
+			return variantNumber(aVariant) > variantNumber(bVariant)
+		}
+	}
+	if p.OSVersion != "" {
+		if (a.OSVersion == p.OSVersion) != (b.OSVersion == p.OSVersion) {
+			return a.OSVersion == p.OSVersion
+		}
+		return osRevision(a.OSVersion) > osRevision(b.OSVersion)
+	}
+	return false
+}
+
+func normalizeArchitecture(arch string) string {
+	switch arch {
+	case "x86_64", "x86-64":
+		return "amd64"
+	case "aarch64":
+		return "arm64"
+	}
+	return arch
+}
+
+func variantOf(arch, variant string) string {
+	if arch == "arm64" && variant == "" {
+		return "v8"
+	}
+	return variant
+}
+
+// variantMatches returns true if an image with the candidate variant can be used for the requested variant.
+func variantMatches(arch, requested, candidate string) bool {
+	requested, candidate = variantOf(arch, requested), variantOf(arch, candidate)
+	if requested == candidate {
+		return true
+	}
+	if arch != "arm" && arch != "arm64" {
+		return false
+	}
+	requestedNumber, candidateNumber := variantNumber(requested), variantNumber(candidate)
+	return requestedNumber > 0 && candidateNumber > 0 && candidateNumber <= requestedNumber
+}
+
+// variantNumber returns the number of an arm variant (e.g., 7 for "v7"), or 0.
+func variantNumber(variant string) int {
+	number, err := strconv.Atoi(strings.TrimPrefix(variant, "v"))
+	if err != nil {
+		return 0
+	}
+	return number
+}
+
+// osVersionMatches returns true if an image with the candidate OS version can be used for the requested OS version.
+// Windows images can be used on hosts with the same build number, whatever their revision.
+func osVersionMatches(os, requested, candidate string) bool {
+	if requested == candidate {
+		return true
+	}
+	if os != "windows" {
+		return false
+	}
+	return osBuild(requested) != "" && osBuild(requested) == osBuild(candidate)
+}
+
+// osBuild returns the build of a Windows OS version (e.g., "10.0.17763" for "10.0.17763.1397"), or empty.
+func osBuild(version string) string {
+	parts := strings.Split(version, ".")
+	if len(parts) < 3 {
+		return ""
+	}
+	return strings.Join(parts[:3], ".")
+}
+
+// osRevision returns the revision of a Windows OS version (e.g., 1397 for "10.0.17763.1397"), or 0.
+func osRevision(version string) int {
+	parts := strings.Split(version, ".")
+	if len(parts) < 4 {
+		return 0
+	}
+	revision, err := strconv.Atoi(parts[3])
+	if err != nil {
+		return 0
+	}
+	return revision
+}
@@ -0,0 +1,73 @@
+package imgutil
+
+import "io"
+
+// Progress describes the progress of transferring image data,
+// such as pushing to a registry, loading into or downloading layers from a daemon, or writing to a layout.
+type Progress struct {
+	// Layer is the digest of the layer being transferred (the diff ID for the `local` implementation).
+	// It is empty when the update covers all the data being transferred, such as when downloading layers from a daemon.
+	Layer string
+	// Complete is the number of bytes transferred so far.
+	Complete int64
+	// Total is the number of bytes to transfer, or -1 if unknown.
+	Total int64
+}
+
+// ProgressFunc receives progress updates.
+// It may be called concurrently (e.g., when layers are written in parallel) and should return quickly.
+type ProgressFunc func(Progress)
+
+// PushReport describes how the blobs of an image were pushed to a registry.
+type PushReport struct {
+	// ImageName is the name the image was pushed with.
+	ImageName string
+	// Mounted are the blobs mounted from other repositories of the registry.
+	Mounted []BlobMount
+	// Uploaded are the digests of the blobs that were uploaded.
+	Uploaded []string
+	// Existing are the digests of the blobs that were already present in the repository.
+	Existing []string
+}
+
+// BlobMount describes a blob mounted from another repository of the registry.
+type BlobMount struct {
+	Digest string
+	// From is the repository the blob was mounted from (e.g., "some-org/some-base-image").
+	From string
+}
+
+// PushReportFunc receives a report for each name an image is pushed with.
+// It may be called concurrently (e.g., when an image is pushed to several repositories) and should return quickly.
+type PushReportFunc func(PushReport)
+
+// NewProgressReadCloser returns an io.ReadCloser that reports the number of bytes read from rc to the provided function.
+// If the function is nil, rc is returned as is.
+func NewProgressReadCloser(rc io.ReadCloser, layer string, total int64, withProgress ProgressFunc) io.ReadCloser {
+	if withProgress == nil {
+		return rc
+	}
+	return &progressReadCloser{
+		ReadCloser: rc,
+		progress:   withProgress,
+		layer:      layer,
+		total:      total,
+	}
+}
+
+type progressReadCloser struct {
+	io.ReadCloser
+	progress ProgressFunc
+	layer    string
+	complete int64
+	total    int64
+}
+
+func (r *progressReadCloser) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.complete += int64(n)
+		r.progress(Progress{Layer: r.layer, Complete: r.complete, Total: r.total})
+	}
+	return n, err
+}
@@ -0,0 +1,41 @@
+package remote
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+
+	"github.com/buildpacks/imgutil"
+)
+
+// registryError wraps the provided error from a registry operation with the corresponding imgutil error, if any.
+func registryError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var transportErr *transport.Error
+	if errors.As(err, &transportErr) {
+		switch {
+		case hasErrorCode(transportErr, transport.BlobUnknownErrorCode, transport.ManifestBlobUnknownErrorCode):
+			return fmt.Errorf("%w: %w", imgutil.ErrLayerMissing, err)
+		case transportErr.StatusCode == http.StatusNotFound:
+			return fmt.Errorf("%w: %w", imgutil.ErrImageNotFound, err)
+		case transportErr.StatusCode == http.StatusUnauthorized, transportErr.StatusCode == http.StatusForbidden:
+			return fmt.Errorf("%w: %w", imgutil.ErrUnauthorized, err)
+		}
+	}
+	return err
+}
+
+func hasErrorCode(transportErr *transport.Error, codes ...transport.ErrorCode) bool {
+	for _, diagnostic := range transportErr.Errors {
+		for _, code := range codes {
+			if diagnostic.Code == code {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,216 @@
+package remote
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/pkg/errors"
+
+	"github.com/buildpacks/imgutil"
+)
+
+var _ imgutil.ImageIndex = (*Index)(nil)
+
+// Index wraps an imgutil.CNBIndex and implements the methods needed to complete the imgutil.ImageIndex interface.
+type Index struct {
+	*imgutil.CNBIndex
+	ctx              context.Context
+	repoName         string
+	keychain         authn.Keychain
+	registrySettings map[string]imgutil.RegistrySetting
+	retryPolicy      imgutil.RetryPolicy
+	transport        http.RoundTripper
+}
+
+// NewIndex returns a new index that can be modified and saved to an OCI image registry.
+func NewIndex(repoName string, keychain authn.Keychain, ops ...imgutil.ImageOption) (*Index, error) {
+	options := &imgutil.ImageOptions{}
+	for _, op := range ops {
+		op(options)
+	}
+
+	options.RetryPolicy = processRetryPolicyOption(options.RetryPolicy)
+	ctx := imgutil.ContextOrBackground(options.Context)
+
+	var err error
+	options.BaseIndex, err = processIndexOption(ctx, options.BaseIndexRepoName, keychain, options.RemoteOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	cnbIndex, err := imgutil.NewCNBIndex(*options)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Index{
+		CNBIndex:         cnbIndex,
+		ctx:              ctx,
+		repoName:         repoName,
+		keychain:         keychain,
+		registrySettings: options.RegistrySettings,
+		retryPolicy:      options.RetryPolicy,
+		transport:        options.Transport,
+	}, nil
+}
+
+// processIndexOption returns the index with the provided name, read from the mirrors of its registry (if any)
+// before the registry itself, or nil if it is not found (or cannot be accessed).
+func processIndexOption(ctx context.Context, repoName string, keychain authn.Keychain, withRemoteOptions imgutil.RemoteOptions) (v1.ImageIndex, error) {
+	if repoName == "" {
+		return nil, nil
+	}
+	var (
+		index v1.ImageIndex
+		err   error
+	)
+	for _, candidate := range readCandidates(repoName, withRemoteOptions.RegistrySettings) {
+		if index, err = fetchIndex(ctx, candidate, keychain, withRemoteOptions); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		if transportErr, ok := err.(*transport.Error); ok && len(transportErr.Errors) > 0 {
+			switch transportErr.StatusCode {
+			case http.StatusNotFound, http.StatusUnauthorized:
+				return nil, nil
+			}
+		}
+		return nil, errors.Wrapf(registryError(err), "connect to repo store %q", repoName)
+	}
+	return index, nil
+}
+
+func fetchIndex(ctx context.Context, repoName string, keychain authn.Keychain, withRemoteOptions imgutil.RemoteOptions) (v1.ImageIndex, error) {
+	reg := getRegistrySetting(repoName, withRemoteOptions.RegistrySettings)
+	ref, auth, err := referenceForRepoName(keychain, repoName, reg.Insecure)
+	if err != nil {
+		return nil, err
+	}
+	rt, err := getTransport(reg, withRemoteOptions.Transport)
+	if err != nil {
+		return nil, err
+	}
+	var index v1.ImageIndex
+	err = retry(ctx, withRemoteOptions.RetryPolicy, func() error {
+		index, err = remote.Index(ref, remoteOptions(ctx, auth, rt, withRemoteOptions.RetryPolicy)...)
+		return err
+	})
+	return index, err
+}
+
+func (i *Index) Kind() string {
+	return `remote`
+}
+
+func (i *Index) Name() string {
+	return i.repoName
+}
+
+func (i *Index) Rename(name string) {
+	i.repoName = name
+}
+
+// Found returns true if the index exists on the mirrors of its registry (if any) or on the registry itself.
+func (i *Index) Found() bool {
+	for _, candidate := range readCandidates(i.repoName, i.registrySettings) {
+		if i.headFrom(candidate) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (i *Index) headFrom(repoName string) error {
+	reg := getRegistrySetting(repoName, i.registrySettings)
+	ref, auth, err := referenceForRepoName(i.keychain, repoName, reg.Insecure)
+	if err != nil {
+		return err
+	}
+	rt, err := getTransport(reg, i.transport)
+	if err != nil {
+		return err
+	}
+	return retry(i.ctx, i.retryPolicy, func() error {
+		_, err := remote.Head(ref, remoteOptions(i.ctx, auth, rt, i.retryPolicy)...)
+		return err
+	})
+}
+
+func (i *Index) Identifier() (imgutil.Identifier, error) {
+	ref, err := name.ParseReference(i.repoName, name.WeakValidation)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing reference for index %q", i.repoName)
+	}
+
+	hash, err := i.Digest()
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting digest for index %q", i.repoName)
+	}
+
+	digestRef, err := name.NewDigest(fmt.Sprintf("%s@%s", ref.Context().Name(), hash.String()), name.WeakValidation)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating digest reference")
+	}
+
+	return DigestIdentifier{
+		Digest: digestRef,
+	}, nil
+}
+
+func (i *Index) Delete() error {
+	id, err := i.Identifier()
+	if err != nil {
+		return err
+	}
+	reg := getRegistrySetting(i.repoName, i.registrySettings)
+	ref, auth, err := referenceForRepoName(i.keychain, id.String(), reg.Insecure)
+	if err != nil {
+		return err
+	}
+	rt, err := getTransport(reg, i.transport)
+	if err != nil {
+		return err
+	}
+	return registryError(retry(i.ctx, i.retryPolicy, func() error {
+		return remote.Delete(ref, remoteOptions(i.ctx, auth, rt, i.retryPolicy)...)
+	}))
+}
+
+func (i *Index) Save(additionalNames ...string) error {
+	return i.SaveAs(i.Name(), additionalNames...)
+}
+
+func (i *Index) SaveAs(name string, additionalNames ...string) error {
+	var diagnostics []imgutil.SaveDiagnostic
+	allNames := append([]string{name}, additionalNames...)
+	for _, n := range allNames {
+		if err := i.doSave(n); err != nil {
+			diagnostics = append(diagnostics, imgutil.SaveDiagnostic{ImageName: n, Cause: err})
+		}
+	}
+	if len(diagnostics) > 0 {
+		return imgutil.SaveError{Errors: diagnostics}
+	}
+	return nil
+}
+
+func (i *Index) doSave(indexName string) error {
+	reg := getRegistrySetting(indexName, i.registrySettings)
+	ref, auth, err := referenceForRepoName(i.keychain, indexName, reg.Insecure)
+	if err != nil {
+		return err
+	}
+	rt, err := getTransport(reg, i.transport)
+	if err != nil {
+		return err
+	}
+
+	return registryError(remote.WriteIndex(ref, i.CNBIndex, remoteOptions(i.ctx, auth, rt, i.retryPolicy)...))
+}
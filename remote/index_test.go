@@ -0,0 +1,242 @@
+package remote_test
+
+import (
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	ggcrremote "github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil"
+	"github.com/buildpacks/imgutil/remote"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+var indexRegistry *h.DockerRegistry
+
+func TestRemoteIndex(t *testing.T) {
+	dockerConfigDir, err := os.MkdirTemp("", "test.docker.config.index.dir")
+	h.AssertNil(t, err)
+	defer os.RemoveAll(dockerConfigDir)
+
+	indexRegistry = h.NewDockerRegistry(h.WithAuth(dockerConfigDir))
+	indexRegistry.Start(t)
+	defer indexRegistry.Stop(t)
+
+	os.Setenv("DOCKER_CONFIG", indexRegistry.DockerDirectory)
+	defer os.Unsetenv("DOCKER_CONFIG")
+
+	spec.Run(t, "Index", testIndex, spec.Sequential(), spec.Report(report.Terminal{}))
+}
+
+func testIndex(t *testing.T, when spec.G, it spec.S) {
+	var (
+		repoName string
+		amd64    = imgutil.Platform{OS: "linux", Architecture: "amd64"}
+		arm64    = imgutil.Platform{OS: "linux", Architecture: "arm64"}
+	)
+
+	newPlatformImage := func(platform imgutil.Platform) *remote.Image {
+		img, err := remote.NewImage(
+			indexRegistry.RepoName("index-image-test-"+h.RandString(10)),
+			authn.DefaultKeychain,
+			remote.WithDefaultPlatform(platform),
+		)
+		h.AssertNil(t, err)
+		h.AssertNil(t, img.Save())
+		return img
+	}
+
+	// found returns true if the registry has a manifest with the provided name
+	found := func(repoName string) bool {
+		ref, err := name.ParseReference(repoName, name.WeakValidation)
+		h.AssertNil(t, err)
+		_, err = ggcrremote.Head(ref, ggcrremote.WithAuthFromKeychain(authn.DefaultKeychain))
+		return err == nil
+	}
+
+	it.Before(func() {
+		repoName = indexRegistry.RepoName("index-test-" + h.RandString(10))
+	})
+
+	when("#NewIndex", func() {
+		it("returns an empty OCI image index", func() {
+			idx, err := remote.NewIndex(repoName, authn.DefaultKeychain)
+			h.AssertNil(t, err)
+
+			mediaType, err := idx.MediaType()
+			h.AssertNil(t, err)
+			h.AssertEq(t, mediaType, types.OCIImageIndex)
+			h.AssertEq(t, idx.Found(), false)
+		})
+
+		when("#FromBaseIndex", func() {
+			it("loads the manifests of the existing index", func() {
+				base, err := remote.NewIndex(repoName, authn.DefaultKeychain)
+				h.AssertNil(t, err)
+				h.AssertNil(t, base.AddManifest(newPlatformImage(amd64)))
+				h.AssertNil(t, base.AddManifest(newPlatformImage(arm64)))
+				h.AssertNil(t, base.Save())
+
+				idx, err := remote.NewIndex(
+					indexRegistry.RepoName("index-test-"+h.RandString(10)),
+					authn.DefaultKeychain,
+					imgutil.FromBaseIndex(repoName),
+				)
+				h.AssertNil(t, err)
+
+				platforms, err := idx.Platforms()
+				h.AssertNil(t, err)
+				h.AssertEq(t, platforms, []imgutil.Platform{amd64, arm64})
+			})
+
+			it("reads the base index from the mirror", func() {
+				mirror := indexRegistry.RepoName("mirror-" + h.RandString(10))
+				ref, err := name.ParseReference(repoName, name.WeakValidation)
+				h.AssertNil(t, err)
+				base, err := remote.NewIndex(mirror+"/"+ref.Context().RepositoryStr(), authn.DefaultKeychain)
+				h.AssertNil(t, err)
+				h.AssertNil(t, base.AddManifest(newPlatformImage(arm64)))
+				h.AssertNil(t, base.Save())
+
+				idx, err := remote.NewIndex(
+					indexRegistry.RepoName("index-test-"+h.RandString(10)),
+					authn.DefaultKeychain,
+					imgutil.FromBaseIndex(repoName),
+					remote.WithRegistryMirrors(indexRegistry.RepoName(""), mirror),
+				)
+				h.AssertNil(t, err)
+
+				platforms, err := idx.Platforms()
+				h.AssertNil(t, err)
+				h.AssertEq(t, platforms, []imgutil.Platform{arm64})
+			})
+
+			when("base index does not exist", func() {
+				it("returns an empty index", func() {
+					idx, err := remote.NewIndex(repoName, authn.DefaultKeychain, imgutil.FromBaseIndex(repoName))
+					h.AssertNil(t, err)
+
+					platforms, err := idx.Platforms()
+					h.AssertNil(t, err)
+					h.AssertEq(t, len(platforms), 0)
+				})
+			})
+		})
+	})
+
+	when("#Save", func() {
+		it("pushes the index and the referenced images", func() {
+			idx, err := remote.NewIndex(repoName, authn.DefaultKeychain)
+			h.AssertNil(t, err)
+			h.AssertNil(t, idx.AddManifest(newPlatformImage(amd64)))
+			h.AssertNil(t, idx.AddManifest(newPlatformImage(arm64)))
+			h.AssertNil(t, idx.RemoveManifest(amd64))
+			h.AssertNil(t, idx.SetAnnotation("some-key", "some-value"))
+			additionalName := indexRegistry.RepoName("index-test-" + h.RandString(10))
+
+			h.AssertNil(t, idx.Save(additionalName))
+
+			for _, n := range []string{repoName, additionalName} {
+				ref, err := name.ParseReference(n, name.WeakValidation)
+				h.AssertNil(t, err)
+				pushed, err := ggcrremote.Index(ref, ggcrremote.WithAuthFromKeychain(authn.DefaultKeychain))
+				h.AssertNil(t, err)
+				manifest, err := pushed.IndexManifest()
+				h.AssertNil(t, err)
+				h.AssertEq(t, len(manifest.Manifests), 1)
+				h.AssertEq(t, manifest.Manifests[0].Platform.Architecture, "arm64")
+				h.AssertEq(t, manifest.Annotations["some-key"], "some-value")
+			}
+			h.AssertEq(t, idx.Found(), true)
+		})
+	})
+
+	when("#WithRetryPolicy", func() {
+		var retryPolicy = imgutil.RetryPolicy{Attempts: 4, InitialBackoff: 10 * time.Millisecond, Factor: 2}
+
+		it.After(func() {
+			indexRegistry.ClearFaults()
+		})
+
+		it("retries index pushes", func() {
+			idx, err := remote.NewIndex(repoName, authn.DefaultKeychain, imgutil.WithRetryPolicy(retryPolicy))
+			h.AssertNil(t, err)
+			h.AssertNil(t, idx.AddManifest(newPlatformImage(amd64)))
+			injected := indexRegistry.InjectedFaultCount()
+			indexRegistry.InjectFault(h.Fault{Method: http.MethodPut, PathPattern: h.ManifestPathPattern, Times: 3, StatusCode: http.StatusTooManyRequests})
+
+			h.AssertNil(t, idx.Save())
+
+			h.AssertEq(t, indexRegistry.InjectedFaultCount(), injected+3)
+			h.AssertEq(t, idx.Found(), true)
+		})
+
+		it("retries index reads", func() {
+			base, err := remote.NewIndex(repoName, authn.DefaultKeychain)
+			h.AssertNil(t, err)
+			h.AssertNil(t, base.AddManifest(newPlatformImage(amd64)))
+			h.AssertNil(t, base.Save())
+			injected := indexRegistry.InjectedFaultCount()
+			indexRegistry.InjectFault(h.Fault{Method: http.MethodGet, PathPattern: h.ManifestPathPattern, Times: 1, CloseConnection: true})
+			indexRegistry.InjectFault(h.Fault{Method: http.MethodGet, PathPattern: h.ManifestPathPattern, Times: 1, StatusCode: http.StatusServiceUnavailable})
+
+			idx, err := remote.NewIndex(
+				indexRegistry.RepoName("index-test-"+h.RandString(10)),
+				authn.DefaultKeychain,
+				imgutil.FromBaseIndex(repoName),
+				imgutil.WithRetryPolicy(retryPolicy),
+			)
+			h.AssertNil(t, err)
+
+			h.AssertEq(t, indexRegistry.InjectedFaultCount(), injected+2)
+			platforms, err := idx.Platforms()
+			h.AssertNil(t, err)
+			h.AssertEq(t, platforms, []imgutil.Platform{amd64})
+		})
+
+		it("gives up reading after the attempts of the policy", func() {
+			injected := indexRegistry.InjectedFaultCount()
+			indexRegistry.InjectFault(h.Fault{Method: http.MethodGet, PathPattern: h.ManifestPathPattern, StatusCode: http.StatusServiceUnavailable})
+
+			_, err := remote.NewIndex(repoName, authn.DefaultKeychain, imgutil.FromBaseIndex(repoName), imgutil.WithRetryPolicy(retryPolicy))
+			h.AssertError(t, err, "Service Unavailable")
+
+			h.AssertEq(t, indexRegistry.InjectedFaultCount(), injected+4)
+		})
+
+		it("retries index deletes", func() {
+			idx, err := remote.NewIndex(repoName, authn.DefaultKeychain, imgutil.WithRetryPolicy(retryPolicy))
+			h.AssertNil(t, err)
+			h.AssertNil(t, idx.AddManifest(newPlatformImage(amd64)))
+			h.AssertNil(t, idx.Save())
+			identifier, err := idx.Identifier()
+			h.AssertNil(t, err)
+			indexRegistry.InjectFault(h.Fault{Method: http.MethodDelete, Times: 1, StatusCode: http.StatusBadGateway})
+
+			h.AssertNil(t, idx.Delete())
+
+			h.AssertEq(t, found(identifier.String()), false)
+		})
+	})
+
+	when("#Delete", func() {
+		it("deletes the index from the registry", func() {
+			idx, err := remote.NewIndex(repoName, authn.DefaultKeychain)
+			h.AssertNil(t, err)
+			h.AssertNil(t, idx.AddManifest(newPlatformImage(amd64)))
+			h.AssertNil(t, idx.Save())
+			identifier, err := idx.Identifier()
+			h.AssertNil(t, err)
+
+			h.AssertNil(t, idx.Delete())
+			h.AssertEq(t, found(identifier.String()), false)
+		})
+	})
+}
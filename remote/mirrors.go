@@ -0,0 +1,75 @@
+package remote
+
+import (
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+
+	"github.com/buildpacks/imgutil"
+)
+
+// readCandidates returns the names to try in order when reading the image with the provided name:
+// the repository of the image on each mirror of its registry setting, then the image itself,
+// with the prefix of the setting replaced by its rewrite prefix, if any.
+func readCandidates(repoName string, withRegistrySettings map[string]imgutil.RegistrySetting) []string {
+	prefix, matchedName, reg, ok := registrySettingFor(repoName, withRegistrySettings)
+	if !ok {
+		return []string{repoName}
+	}
+	upstream := repoName
+	if reg.Rewrite != "" {
+		upstream = strings.TrimSuffix(reg.Rewrite, "/") + "/" + strings.TrimPrefix(strings.TrimPrefix(matchedName, prefix), "/")
+	}
+	if len(reg.Mirrors) == 0 {
+		return []string{upstream}
+	}
+	ref, err := name.ParseReference(repoName, name.WeakValidation)
+	if err != nil {
+		return []string{upstream}
+	}
+	var candidates []string
+	for _, mirror := range reg.Mirrors {
+		candidates = append(candidates, strings.TrimSuffix(mirror, "/")+"/"+ref.Context().RepositoryStr()+identifierSuffix(ref))
+	}
+	return append(candidates, upstream)
+}
+
+func identifierSuffix(ref name.Reference) string {
+	if _, ok := ref.(name.Digest); ok {
+		return "@" + ref.Identifier()
+	}
+	return ":" + ref.Identifier()
+}
+
+// registrySettingFor returns the setting registered with the longest prefix of the provided name,
+// along with the prefix and the form of the name it matched:
+// the name as provided, or fully qualified (e.g., "index.docker.io/library/busybox:latest"
+// or "docker.io/library/busybox:latest" for "busybox").
+func registrySettingFor(repoName string, withRegistrySettings map[string]imgutil.RegistrySetting) (string, string, imgutil.RegistrySetting, bool) {
+	var (
+		matchedPrefix, matchedName string
+		matchedSetting             imgutil.RegistrySetting
+		found                      bool
+	)
+	for _, form := range nameForms(repoName) {
+		for prefix, reg := range withRegistrySettings {
+			if strings.HasPrefix(form, prefix) && (!found || len(prefix) > len(matchedPrefix)) {
+				matchedPrefix, matchedName, matchedSetting, found = prefix, form, reg, true
+			}
+		}
+	}
+	return matchedPrefix, matchedName, matchedSetting, found
+}
+
+func nameForms(repoName string) []string {
+	forms := []string{repoName}
+	ref, err := name.ParseReference(repoName, name.WeakValidation)
+	if err != nil {
+		return forms
+	}
+	forms = append(forms, ref.Name())
+	if ref.Context().RegistryStr() == name.DefaultRegistry {
+		forms = append(forms, "docker.io/"+strings.TrimPrefix(ref.Name(), name.DefaultRegistry+"/"))
+	}
+	return forms
+}
@@ -1,11 +1,9 @@
 package remote
 
 import (
-	"io"
+	"context"
 	"net/http"
 	"runtime"
-	"strings"
-	"time"
 
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
@@ -27,17 +25,21 @@ func NewImage(repoName string, keychain authn.Keychain, ops ...imgutil.ImageOpti
 	}
 
 	options.Platform = processPlatformOption(options.Platform)
+	options.RetryPolicy = processRetryPolicyOption(options.RetryPolicy)
+	ctx := imgutil.ContextOrBackground(options.Context)
 
-	var err error
-	options.PreviousImage, err = processImageOption(options.PreviousImageRepoName, keychain, options.Platform, options.RegistrySettings)
+	previousImage, err := processImageOption(ctx, options.PreviousImageRepoName, keychain, options.Platform, options.RemoteOptions, options.StrictPreviousImage)
 	if err != nil {
 		return nil, err
 	}
+	options.PreviousImage = previousImage.image
 
-	options.BaseImage, err = processImageOption(options.BaseImageRepoName, keychain, options.Platform, options.RegistrySettings)
+	baseImage, err := processImageOption(ctx, options.BaseImageRepoName, keychain, options.Platform, options.RemoteOptions, options.StrictBaseImage)
 	if err != nil {
 		return nil, err
 	}
+	options.BaseImage = baseImage.image
+	options.BaseImageDigest = baseImage.digest
 	options.MediaTypes = imgutil.GetPreferredMediaTypes(*options)
 	if options.BaseImage != nil {
 		options.BaseImage, _, err = imgutil.EnsureMediaTypesAndLayers(options.BaseImage, options.MediaTypes, imgutil.PreserveLayers)
@@ -53,10 +55,16 @@ func NewImage(repoName string, keychain authn.Keychain, ops ...imgutil.ImageOpti
 
 	return &Image{
 		CNBImageCore:        cnbImage,
+		ctx:                 ctx,
 		repoName:            repoName,
+		archiveFormat:       options.ArchiveFormat,
 		keychain:            keychain,
 		addEmptyLayerOnSave: options.AddEmptyLayerOnSave,
 		registrySettings:    options.RegistrySettings,
+		progress:            options.Progress,
+		retryPolicy:         options.RetryPolicy,
+		transport:           options.Transport,
+		pushReport:          options.PushReport,
 	}, nil
 }
 
@@ -68,66 +76,118 @@ func defaultPlatform() imgutil.Platform {
 }
 
 func processPlatformOption(requestedPlatform imgutil.Platform) imgutil.Platform {
-	if (requestedPlatform != imgutil.Platform{}) {
+	if !requestedPlatform.IsZero() {
 		return requestedPlatform
 	}
 	return defaultPlatform()
 }
 
-func processImageOption(repoName string, keychain authn.Keychain, withPlatform imgutil.Platform, withRegistrySettings map[string]imgutil.RegistrySetting) (v1.Image, error) {
+type imageResult struct {
+	image  v1.Image
+	digest string // empty if the image was not found
+}
+
+// processImageOption returns the image with the provided name, or an empty image if it is not found
+// (or cannot be accessed, or has no image for the platform), unless strict is true.
+func processImageOption(ctx context.Context, repoName string, keychain authn.Keychain, withPlatform imgutil.Platform, withRemoteOptions imgutil.RemoteOptions, strict bool) (imageResult, error) {
 	if repoName == "" {
-		return nil, nil
+		return imageResult{}, nil
 	}
 
-	platform := v1.Platform{
-		Architecture: withPlatform.Architecture,
-		OS:           withPlatform.OS,
-		OSVersion:    withPlatform.OSVersion,
+	var (
+		image v1.Image
+		err   error
+	)
+	for _, candidate := range readCandidates(repoName, withRemoteOptions.RegistrySettings) { // mirrors (if any), then the image registry
+		if image, err = fetchImage(ctx, candidate, keychain, withPlatform, withRemoteOptions); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		if isMissingImageError(err) {
+			if strict {
+				return imageResult{}, errors.Wrapf(registryError(err), "failed to find image %q", repoName)
+			}
+			return emptyImageResult(withPlatform)
+		}
+		return imageResult{}, errors.Wrapf(registryError(err), "connect to repo store %q", repoName)
+	}
+	digest, err := image.Digest()
+	if err != nil {
+		return imageResult{}, errors.Wrapf(err, "getting digest for image %q", repoName)
+	}
+	return imageResult{image: image, digest: digest.String()}, nil
+}
+
+// isMissingImageError returns true if the error means that the image does not exist, cannot be accessed,
+// or has no image for the platform.
+func isMissingImageError(err error) bool {
+	if transportErr, ok := err.(*transport.Error); ok && len(transportErr.Errors) > 0 {
+		switch transportErr.StatusCode {
+		case http.StatusNotFound, http.StatusUnauthorized:
+			return true
+		}
 	}
-	reg := getRegistrySetting(repoName, withRegistrySettings)
+	return errors.Is(err, imgutil.ErrPlatformMismatch)
+}
+
+func fetchImage(ctx context.Context, repoName string, keychain authn.Keychain, platform imgutil.Platform, withRemoteOptions imgutil.RemoteOptions) (v1.Image, error) {
+	reg := getRegistrySetting(repoName, withRemoteOptions.RegistrySettings)
 	ref, auth, err := referenceForRepoName(keychain, repoName, reg.Insecure)
 	if err != nil {
 		return nil, err
 	}
-
+	rt, err := getTransport(reg, withRemoteOptions.Transport)
+	if err != nil {
+		return nil, err
+	}
 	var image v1.Image
-	for i := 0; i <= maxRetries; i++ {
-		time.Sleep(100 * time.Duration(i) * time.Millisecond) // wait if retrying
-		image, err = remote.Image(ref,
-			remote.WithAuth(auth),
-			remote.WithPlatform(platform),
-			remote.WithTransport(getTransport(reg.Insecure)),
-		)
-		if err != nil {
-			if err == io.EOF && i != maxRetries {
-				continue // retry if EOF
-			}
-			if transportErr, ok := err.(*transport.Error); ok && len(transportErr.Errors) > 0 {
-				switch transportErr.StatusCode {
-				case http.StatusNotFound, http.StatusUnauthorized:
-					return emptyImage(withPlatform)
-				}
-			}
-			if strings.Contains(err.Error(), "no child with platform") {
-				return emptyImage(withPlatform)
-			}
-			return nil, errors.Wrapf(err, "connect to repo store %q", repoName)
-		}
-		break
+	err = retry(ctx, withRemoteOptions.RetryPolicy, func() error {
+		image, err = imageForPlatform(ref, platform, remoteOptions(ctx, auth, rt, withRemoteOptions.RetryPolicy))
+		return err
+	})
+	return image, err
+}
+
+// imageForPlatform returns the image with the reference or, if the reference is an index,
+// the image in the index that best matches the platform.
+func imageForPlatform(ref name.Reference, platform imgutil.Platform, options []remote.Option) (v1.Image, error) {
+	desc, err := remote.Get(ref, options...)
+	if err != nil {
+		return nil, err
+	}
+	if !desc.MediaType.IsIndex() {
+		return desc.Image()
+	}
+	index, err := desc.ImageIndex()
+	if err != nil {
+		return nil, err
 	}
-	return image, nil
+	return imageFromIndex(index, platform)
 }
 
-func getRegistrySetting(forRepoName string, givenSettings map[string]imgutil.RegistrySetting) imgutil.RegistrySetting {
-	if givenSettings == nil {
-		return imgutil.RegistrySetting{}
+func imageFromIndex(index v1.ImageIndex, platform imgutil.Platform) (v1.Image, error) {
+	manifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+	desc, err := imgutil.FindManifestForPlatform(manifest.Manifests, platform)
+	if err != nil {
+		return nil, err
 	}
-	for prefix, r := range givenSettings {
-		if strings.HasPrefix(forRepoName, prefix) {
-			return r
+	if desc.MediaType.IsIndex() {
+		child, err := index.ImageIndex(desc.Digest)
+		if err != nil {
+			return nil, err
 		}
+		return imageFromIndex(child, platform)
 	}
-	return imgutil.RegistrySetting{}
+	return index.Image(desc.Digest)
+}
+
+func getRegistrySetting(forRepoName string, givenSettings map[string]imgutil.RegistrySetting) imgutil.RegistrySetting {
+	_, _, r, _ := registrySettingFor(forRepoName, givenSettings)
+	return r
 }
 
 func referenceForRepoName(keychain authn.Keychain, ref string, insecure bool) (name.Reference, authn.Authenticator, error) {
@@ -148,12 +208,22 @@ func referenceForRepoName(keychain authn.Keychain, ref string, insecure bool) (n
 	return r, auth, nil
 }
 
+func emptyImageResult(platform imgutil.Platform) (imageResult, error) {
+	image, err := emptyImage(platform)
+	if err != nil {
+		return imageResult{}, err
+	}
+	return imageResult{image: image}, nil
+}
+
 func emptyImage(platform imgutil.Platform) (v1.Image, error) {
 	cfg := &v1.ConfigFile{
 		Architecture: platform.Architecture,
 		History:      []v1.History{},
 		OS:           platform.OS,
 		OSVersion:    platform.OSVersion,
+		Variant:      platform.Variant,
+		OSFeatures:   platform.OSFeatureList(),
 		RootFS: v1.RootFS{
 			Type:    "layers",
 			DiffIDs: []v1.Hash{},
@@ -173,5 +243,10 @@ func NewV1Image(baseImageRepoName string, keychain authn.Keychain, ops ...func(*
 		op(options)
 	}
 	options.Platform = processPlatformOption(options.Platform)
-	return processImageOption(baseImageRepoName, keychain, options.Platform, options.RegistrySettings)
+	options.RetryPolicy = processRetryPolicyOption(options.RetryPolicy)
+	result, err := processImageOption(imgutil.ContextOrBackground(options.Context), baseImageRepoName, keychain, options.Platform, options.RemoteOptions, options.StrictBaseImage)
+	if err != nil {
+		return nil, err
+	}
+	return result.image, nil
 }
@@ -1,6 +1,7 @@
 package remote
 
 import (
+	"net/http"
 	"time"
 
 	v1 "github.com/google/go-containerregistry/pkg/v1"
@@ -22,13 +23,88 @@ func AddEmptyLayerOnSave() func(*imgutil.ImageOptions) {
 // The referenced images could include the base image, a previous image, or the image itself.
 // The insecure parameter allows image references to be fetched without TLS.
 func WithRegistrySetting(repository string, insecure bool) func(*imgutil.ImageOptions) {
+	return withRegistrySetting(repository, func(reg *imgutil.RegistrySetting) {
+		reg.Insecure = insecure
+	})
+}
+
+// WithRegistryMirrors registers mirrors to read images in a registry from, in order,
+// before falling back to the registry itself.
+// The repository is matched as a prefix of image names, as given or fully qualified (e.g., "docker.io" or "index.docker.io/library").
+// Mirrors are only used to read the base image, the previous image, or the image itself; images are always saved to and deleted from their registry.
+func WithRegistryMirrors(repository string, mirrors ...string) func(*imgutil.ImageOptions) {
+	return withRegistrySetting(repository, func(reg *imgutil.RegistrySetting) {
+		reg.Mirrors = append(reg.Mirrors, mirrors...)
+	})
+}
+
+// WithRegistryRewrite replaces the repository prefix of image names with the provided prefix when reading images
+// (e.g., "docker.io/library" with "mirror.example.com/dockerhub").
+// Like mirrors, rewrites are only used to read images.
+func WithRegistryRewrite(repository string, prefix string) func(*imgutil.ImageOptions) {
+	return withRegistrySetting(repository, func(reg *imgutil.RegistrySetting) {
+		reg.Rewrite = prefix
+	})
+}
+
+// WithRegistryCACertificates registers PEM-encoded certificates of authorities to trust, in addition to the system ones,
+// when verifying the certificate of a registry.
+func WithRegistryCACertificates(repository string, pems ...[]byte) func(*imgutil.ImageOptions) {
+	return withRegistrySetting(repository, func(reg *imgutil.RegistrySetting) {
+		reg.CACertificates = append(reg.CACertificates, pems...)
+	})
+}
+
+// WithRegistryClientCertificate registers the PEM-encoded certificate and key to authenticate to a registry with.
+func WithRegistryClientCertificate(repository string, certPEM, keyPEM []byte) func(*imgutil.ImageOptions) {
+	return withRegistrySetting(repository, func(reg *imgutil.RegistrySetting) {
+		reg.ClientCertificate = certPEM
+		reg.ClientKey = keyPEM
+	})
+}
+
+// WithRegistryProxy registers the URL of the proxy for requests to a registry (e.g., "http://proxy.example.com:3128").
+// If not provided, the proxy is taken from the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+func WithRegistryProxy(repository string, proxyURL string) func(*imgutil.ImageOptions) {
+	return withRegistrySetting(repository, func(reg *imgutil.RegistrySetting) {
+		reg.Proxy = proxyURL
+	})
+}
+
+// WithPushReport lets a caller receive a report of the blobs mounted from other repositories, uploaded,
+// or already present for each name the image is saved with.
+// Layers of the base image and the previous image are mounted from their repository when it is in the same registry.
+func WithPushReport(f imgutil.PushReportFunc) func(*imgutil.ImageOptions) {
+	return func(o *imgutil.ImageOptions) {
+		o.PushReport = f
+	}
+}
+
+// WithRegistryTransport registers the transport for requests to a registry, overriding the transport provided with WithTransport.
+func WithRegistryTransport(repository string, transport http.RoundTripper) func(*imgutil.ImageOptions) {
+	return withRegistrySetting(repository, func(reg *imgutil.RegistrySetting) {
+		reg.Transport = transport
+	})
+}
+
+// WithTransport lets a caller provide the transport for requests to registries (e.g., to trace or record them).
+// It is used to read, save and delete images, and to check access to them.
+// If it is an *http.Transport, the TLS and proxy settings of the registry are applied to a clone of it;
+// any other transport is used as is. If not provided, the default is http.DefaultTransport.
+func WithTransport(transport http.RoundTripper) func(*imgutil.ImageOptions) {
+	return func(o *imgutil.ImageOptions) {
+		o.Transport = transport
+	}
+}
+
+func withRegistrySetting(repository string, update func(*imgutil.RegistrySetting)) func(*imgutil.ImageOptions) {
 	return func(o *imgutil.ImageOptions) {
 		if o.RegistrySettings == nil {
 			o.RegistrySettings = make(map[string]imgutil.RegistrySetting)
 		}
-		o.RegistrySettings[repository] = imgutil.RegistrySetting{
-			Insecure: insecure,
-		}
+		reg := o.RegistrySettings[repository]
+		update(&reg)
+		o.RegistrySettings[repository] = reg
 	}
 }
 
@@ -0,0 +1,80 @@
+package remote
+
+import (
+	"io"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+
+	"github.com/buildpacks/imgutil"
+)
+
+// progressImage reports the bytes read from each layer of the image while it is pushed.
+// Layers that are mounted or that already exist in the repository are not read, and are not reported.
+type progressImage struct {
+	v1.Image
+	progress imgutil.ProgressFunc
+}
+
+func (i *progressImage) Layers() ([]v1.Layer, error) {
+	layers, err := i.Image.Layers()
+	if err != nil {
+		return nil, err
+	}
+	withProgress := make([]v1.Layer, len(layers))
+	for idx, layer := range layers {
+		withProgress[idx] = i.layerWithProgress(layer)
+	}
+	return withProgress, nil
+}
+
+func (i *progressImage) LayerByDigest(digest v1.Hash) (v1.Layer, error) {
+	layer, err := i.Image.LayerByDigest(digest)
+	if err != nil {
+		return nil, err
+	}
+	return i.layerWithProgress(layer), nil
+}
+
+// layerWithProgress keeps layers mountable, as go-containerregistry only mounts *remote.MountableLayer layers.
+func (i *progressImage) layerWithProgress(layer v1.Layer) v1.Layer {
+	if mountable, ok := layer.(*remote.MountableLayer); ok {
+		return &remote.MountableLayer{
+			Layer:     &progressLayer{Layer: mountable.Layer, progress: i.progress},
+			Reference: mountable.Reference,
+		}
+	}
+	return &progressLayer{Layer: layer, progress: i.progress}
+}
+
+type progressLayer struct {
+	v1.Layer
+	progress imgutil.ProgressFunc
+}
+
+func (l *progressLayer) Compressed() (io.ReadCloser, error) {
+	digest, err := l.Layer.Digest()
+	if err != nil {
+		return nil, err
+	}
+	size, err := l.Layer.Size()
+	if err != nil {
+		return nil, err
+	}
+	rc, err := l.Layer.Compressed()
+	if err != nil {
+		return nil, err
+	}
+	return imgutil.NewProgressReadCloser(rc, digest.String(), size, l.progress), nil
+}
+
+// Descriptor retains the descriptor of the layer (see partial.Descriptor).
+func (l *progressLayer) Descriptor() (*v1.Descriptor, error) {
+	return partial.Descriptor(l.Layer)
+}
+
+// Exists retains whether the layer is known to exist (see partial.Exists).
+func (l *progressLayer) Exists() (bool, error) {
+	return partial.Exists(l.Layer)
+}
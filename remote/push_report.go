@@ -0,0 +1,86 @@
+package remote
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/buildpacks/imgutil"
+)
+
+var (
+	blobPath       = regexp.MustCompile(`^/v2/.+/blobs/(sha256:[0-9a-f]+)$`)
+	blobUploadPath = regexp.MustCompile(`^/v2/.+/blobs/uploads/`)
+)
+
+// pushRecorder records the blobs that are mounted, uploaded, or found to exist
+// from the successful requests that go-containerregistry sends while pushing an image.
+type pushRecorder struct {
+	inner    http.RoundTripper
+	mutex    sync.Mutex
+	mounted  map[string]string // digest -> repository
+	uploaded map[string]struct{}
+	existing map[string]struct{}
+}
+
+func newPushRecorder(inner http.RoundTripper) *pushRecorder {
+	return &pushRecorder{
+		inner:    inner,
+		mounted:  make(map[string]string),
+		uploaded: make(map[string]struct{}),
+		existing: make(map[string]struct{}),
+	}
+}
+
+func (r *pushRecorder) RoundTrip(request *http.Request) (*http.Response, error) {
+	response, err := r.inner.RoundTrip(request)
+	if err != nil {
+		return response, err
+	}
+	r.record(request, response.StatusCode)
+	return response, nil
+}
+
+func (r *pushRecorder) record(request *http.Request, statusCode int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	query := request.URL.Query()
+	switch {
+	case request.Method == http.MethodHead && statusCode == http.StatusOK:
+		if matches := blobPath.FindStringSubmatch(request.URL.Path); matches != nil {
+			r.existing[matches[1]] = struct{}{}
+		}
+	case request.Method == http.MethodPost && statusCode == http.StatusCreated && query.Get("mount") != "":
+		if blobUploadPath.MatchString(request.URL.Path) {
+			r.mounted[query.Get("mount")] = query.Get("from")
+		}
+	case request.Method == http.MethodPut && statusCode == http.StatusCreated && query.Get("digest") != "":
+		if blobUploadPath.MatchString(request.URL.Path) {
+			r.uploaded[query.Get("digest")] = struct{}{}
+		}
+	}
+}
+
+// report returns the recorded blobs, sorted by digest.
+func (r *pushRecorder) report(imageName string) imgutil.PushReport {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	report := imgutil.PushReport{ImageName: imageName}
+	for digest, from := range r.mounted {
+		report.Mounted = append(report.Mounted, imgutil.BlobMount{Digest: digest, From: from})
+	}
+	sort.Slice(report.Mounted, func(i, j int) bool { return report.Mounted[i].Digest < report.Mounted[j].Digest })
+	report.Uploaded = sortedKeys(r.uploaded)
+	report.Existing = sortedKeys(r.existing)
+	return report
+}
+
+func sortedKeys(set map[string]struct{}) []string {
+	var keys []string
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -1,6 +1,7 @@
 package remote
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 
@@ -16,14 +17,20 @@ import (
 	"github.com/buildpacks/imgutil"
 )
 
-const maxRetries = 2
+var _ imgutil.ImageWithContext = (*Image)(nil)
 
 type Image struct {
 	*imgutil.CNBImageCore
+	ctx                 context.Context
 	repoName            string
+	archiveFormat       imgutil.ArchiveFormat
 	keychain            authn.Keychain
 	addEmptyLayerOnSave bool
 	registrySettings    map[string]imgutil.RegistrySetting
+	progress            imgutil.ProgressFunc
+	retryPolicy         imgutil.RetryPolicy
+	transport           http.RoundTripper
+	pushReport          imgutil.PushReportFunc
 }
 
 func (i *Image) Kind() string {
@@ -39,17 +46,43 @@ func (i *Image) Rename(name string) {
 }
 
 func (i *Image) Found() bool {
-	_, err := i.found()
+	return i.FoundWithContext(i.ctx)
+}
+
+func (i *Image) FoundWithContext(ctx context.Context) bool {
+	_, err := i.found(ctx)
 	return err == nil
 }
 
-func (i *Image) found() (*v1.Descriptor, error) {
-	reg := getRegistrySetting(i.repoName, i.registrySettings)
-	ref, auth, err := referenceForRepoName(i.keychain, i.repoName, reg.Insecure)
+func (i *Image) found(ctx context.Context) (*v1.Descriptor, error) {
+	var (
+		desc *v1.Descriptor
+		err  error
+	)
+	for _, candidate := range readCandidates(i.repoName, i.registrySettings) {
+		if desc, err = i.headFrom(ctx, candidate); err == nil {
+			break
+		}
+	}
+	return desc, registryError(err)
+}
+
+func (i *Image) headFrom(ctx context.Context, repoName string) (*v1.Descriptor, error) {
+	reg := getRegistrySetting(repoName, i.registrySettings)
+	ref, auth, err := referenceForRepoName(i.keychain, repoName, reg.Insecure)
 	if err != nil {
 		return nil, err
 	}
-	return remote.Head(ref, remote.WithAuth(auth), remote.WithTransport(getTransport(reg.Insecure)))
+	rt, err := getTransport(reg, i.transport)
+	if err != nil {
+		return nil, err
+	}
+	var desc *v1.Descriptor
+	err = retry(ctx, i.retryPolicy, func() error {
+		desc, err = remote.Head(ref, remoteOptions(ctx, auth, rt, i.retryPolicy)...)
+		return err
+	})
+	return desc, err
 }
 
 func (i *Image) Identifier() (imgutil.Identifier, error) {
@@ -79,12 +112,32 @@ func (i *Image) Valid() bool {
 }
 
 func (i *Image) valid() error {
-	reg := getRegistrySetting(i.repoName, i.registrySettings)
-	ref, auth, err := referenceForRepoName(i.keychain, i.repoName, reg.Insecure)
+	var err error
+	for _, candidate := range readCandidates(i.repoName, i.registrySettings) {
+		if err = i.validateFrom(candidate); err == nil {
+			break
+		}
+	}
+	return registryError(err)
+}
+
+func (i *Image) validateFrom(repoName string) error {
+	reg := getRegistrySetting(repoName, i.registrySettings)
+	ref, auth, err := referenceForRepoName(i.keychain, repoName, reg.Insecure)
 	if err != nil {
 		return err
 	}
-	desc, err := remote.Get(ref, remote.WithAuth(auth), remote.WithTransport(getTransport(reg.Insecure)))
+	rt, err := getTransport(reg, i.transport)
+	if err != nil {
+		return err
+	}
+	return retry(i.ctx, i.retryPolicy, func() error {
+		return validateRemote(ref, remoteOptions(i.ctx, auth, rt, i.retryPolicy))
+	})
+}
+
+func validateRemote(ref name.Reference, ops []remote.Option) error {
+	desc, err := remote.Get(ref, ops...)
 	if err != nil {
 		return err
 	}
@@ -103,6 +156,10 @@ func (i *Image) valid() error {
 }
 
 func (i *Image) Delete() error {
+	return i.DeleteWithContext(i.ctx)
+}
+
+func (i *Image) DeleteWithContext(ctx context.Context) error {
 	id, err := i.Identifier()
 	if err != nil {
 		return err
@@ -112,20 +169,28 @@ func (i *Image) Delete() error {
 	if err != nil {
 		return err
 	}
-	return remote.Delete(ref, remote.WithAuth(auth), remote.WithTransport(getTransport(reg.Insecure)))
+	rt, err := getTransport(reg, i.transport)
+	if err != nil {
+		return err
+	}
+	return registryError(retry(ctx, i.retryPolicy, func() error {
+		return remote.Delete(ref, remoteOptions(ctx, auth, rt, i.retryPolicy)...)
+	}))
 }
 
 // extras
 
 func (i *Image) CheckReadAccess() (bool, error) {
 	var err error
-	if _, err = i.found(); err == nil {
+	if _, err = i.found(i.ctx); err == nil {
 		return true, nil
 	}
-	var canRead bool
-	if transportErr, ok := err.(*transport.Error); ok {
-		if canRead = transportErr.StatusCode != http.StatusUnauthorized &&
-			transportErr.StatusCode != http.StatusForbidden; canRead {
+	var (
+		canRead      bool
+		transportErr *transport.Error
+	)
+	if errors.As(err, &transportErr) {
+		if canRead = !errors.Is(err, imgutil.ErrUnauthorized); canRead {
 			err = nil
 		}
 	}
@@ -141,9 +206,13 @@ func (i *Image) CheckReadWriteAccess() (bool, error) {
 	if err != nil {
 		return false, err
 	}
-	err = remote.CheckPushPermission(ref, i.keychain, http.DefaultTransport)
+	rt, err := getTransport(reg, i.transport)
 	if err != nil {
 		return false, err
 	}
+	err = remote.CheckPushPermission(ref, i.keychain, rt)
+	if err != nil {
+		return false, registryError(err)
+	}
 	return true, nil
 }
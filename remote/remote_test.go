@@ -1,9 +1,12 @@
 package remote_test
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"net/http"
 	"os"
 	"runtime"
 	"strings"
@@ -11,9 +14,14 @@ import (
 	"time"
 
 	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/compression"
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/go-containerregistry/pkg/registry"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	ggcrremote "github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/types"
 	"github.com/sclevine/spec"
 	"github.com/sclevine/spec/report"
 
@@ -297,6 +305,18 @@ func testImage(t *testing.T, when spec.G, it spec.S) {
 						_, err = img.TopLayer()
 						h.AssertError(t, err, "has no layers")
 					})
+
+					it("returns an error with #WithStrictBaseImage", func() {
+						_, err := remote.NewImage(
+							repoName,
+							authn.DefaultKeychain,
+							remote.FromBaseImage(newTestImageName()),
+							imgutil.WithStrictBaseImage(),
+						)
+
+						h.AssertError(t, err, "failed to find image")
+						h.AssertEq(t, errors.Is(err, imgutil.ErrImageNotFound), true)
+					})
 				})
 			})
 
@@ -425,6 +445,53 @@ func testImage(t *testing.T, when spec.G, it spec.S) {
 							h.AssertNotEq(t, topLayerDiffID, "")
 						})
 					})
+
+					when("images differ by variant", func() {
+						var indexName string
+
+						it.Before(func() {
+							indexName = newTestImageName()
+							idx, err := remote.NewIndex(indexName, authn.DefaultKeychain)
+							h.AssertNil(t, err)
+							for _, variant := range []string{"v6", "v7"} {
+								platformImage, err := remote.NewImage(
+									newTestImageName(),
+									authn.DefaultKeychain,
+									remote.WithDefaultPlatform(imgutil.Platform{OS: "linux", Architecture: "arm", Variant: variant}),
+								)
+								h.AssertNil(t, err)
+								h.AssertNil(t, platformImage.Save())
+								h.AssertNil(t, idx.AddManifest(platformImage))
+							}
+							h.AssertNil(t, idx.Save())
+						})
+
+						it("returns the image with the variant", func() {
+							img, err := remote.NewImage(
+								repoName,
+								authn.DefaultKeychain,
+								remote.FromBaseImage(indexName),
+								remote.WithDefaultPlatform(imgutil.Platform{OS: "linux", Architecture: "arm", Variant: "v6"}),
+							)
+							h.AssertNil(t, err)
+
+							variant, err := img.Variant()
+							h.AssertNil(t, err)
+							h.AssertEq(t, variant, "v6")
+						})
+
+						it("lists the available platforms with #WithStrictBaseImage", func() {
+							_, err := remote.NewImage(
+								repoName,
+								authn.DefaultKeychain,
+								remote.FromBaseImage(indexName),
+								remote.WithDefaultPlatform(imgutil.Platform{OS: "linux", Architecture: "arm64"}),
+								imgutil.WithStrictBaseImage(),
+							)
+							h.AssertError(t, err, "available platforms are linux/arm/v6, linux/arm/v7")
+							h.AssertEq(t, errors.Is(err, imgutil.ErrPlatformMismatch), true)
+						})
+					})
 				})
 
 				when("base image does not exist", func() {
@@ -491,6 +558,48 @@ func testImage(t *testing.T, when spec.G, it spec.S) {
 					})
 				})
 			})
+
+			when("#WithBaseImageAnnotations", func() {
+				it("sets the base image annotations on the saved manifest", func() {
+					baseImageName := newTestImageName()
+					baseImage, err := remote.NewImage(baseImageName, authn.DefaultKeychain)
+					h.AssertNil(t, err)
+					h.AssertNil(t, baseImage.Save())
+					baseIdentifier, err := baseImage.Identifier()
+					h.AssertNil(t, err)
+
+					img, err := remote.NewImage(
+						repoName,
+						authn.DefaultKeychain,
+						remote.FromBaseImage(baseImageName),
+						imgutil.WithBaseImageAnnotations(),
+					)
+					h.AssertNil(t, err)
+					h.AssertNil(t, img.Save())
+
+					annotations, err := img.Annotations()
+					h.AssertNil(t, err)
+					h.AssertEq(t, annotations[imgutil.BaseImageNameAnnotation], baseImageName)
+					h.AssertEq(t, annotations[imgutil.BaseImageDigestAnnotation], baseIdentifier.(remote.DigestIdentifier).Digest.DigestStr())
+				})
+
+				when("base image does not exist", func() {
+					it("does not set the base image annotations", func() {
+						img, err := remote.NewImage(
+							repoName,
+							authn.DefaultKeychain,
+							remote.FromBaseImage("some-bad-repo-name"),
+							imgutil.WithBaseImageAnnotations(),
+						)
+						h.AssertNil(t, err)
+
+						annotations, err := img.Annotations()
+						h.AssertNil(t, err)
+						_, exists := annotations[imgutil.BaseImageNameAnnotation]
+						h.AssertEq(t, exists, false)
+					})
+				})
+			})
 		})
 
 		when("#WithPreviousImage", func() {
@@ -541,6 +650,17 @@ func testImage(t *testing.T, when spec.G, it spec.S) {
 
 					h.AssertNil(t, err)
 				})
+
+				it("returns an error with #WithStrictPreviousImage", func() {
+					_, err := remote.NewImage(
+						repoName,
+						authn.DefaultKeychain,
+						remote.WithPreviousImage(newTestImageName()),
+						imgutil.WithStrictPreviousImage(),
+					)
+
+					h.AssertEq(t, errors.Is(err, imgutil.ErrImageNotFound), true)
+				})
 			})
 		})
 
@@ -610,6 +730,33 @@ func testImage(t *testing.T, when spec.G, it spec.S) {
 			})
 		})
 
+		when("#WithLayerCompression", func() {
+			it("pushes zstd compressed layers", func() {
+				img, err := remote.NewImage(
+					repoName,
+					authn.DefaultKeychain,
+					imgutil.WithLayerCompression(imgutil.LayerCompression{Algorithm: compression.ZStd}),
+				)
+				h.AssertNil(t, err)
+				newLayerPath, err := h.CreateSingleFileLayerTar("/new-layer.txt", "new-layer", "linux")
+				h.AssertNil(t, err)
+				defer os.Remove(newLayerPath)
+				h.AssertNil(t, img.AddLayer(newLayerPath))
+				h.AssertNil(t, img.Save())
+
+				ref, err := name.ParseReference(repoName, name.WeakValidation)
+				h.AssertNil(t, err)
+				pushed, err := ggcrremote.Image(ref, ggcrremote.WithAuthFromKeychain(authn.DefaultKeychain))
+				h.AssertNil(t, err)
+				manifest, err := pushed.Manifest()
+				h.AssertNil(t, err)
+				h.AssertEq(t, len(manifest.Layers), 1)
+				h.AssertEq(t, manifest.Layers[0].MediaType, types.OCILayerZStd)
+				diffIDs := h.FetchManifestImageConfigFile(t, repoName).RootFS.DiffIDs
+				h.AssertEq(t, diffIDs[0].String(), h.FileDiffID(t, newLayerPath))
+			})
+		})
+
 		when("#WithConfig", func() {
 			var config = &v1.Config{Entrypoint: []string{"some-entrypoint"}}
 
@@ -644,6 +791,195 @@ func testImage(t *testing.T, when spec.G, it spec.S) {
 				})
 			})
 		})
+
+		when("the registry fails transiently", func() {
+			var baseImageName string
+
+			it.Before(func() {
+				baseImageName = newTestImageName()
+				baseImage, err := remote.NewImage(baseImageName, authn.DefaultKeychain)
+				h.AssertNil(t, err)
+				h.AssertNil(t, baseImage.SetLabel("some-label", "some-value"))
+				h.AssertNil(t, baseImage.Save())
+			})
+
+			it.After(func() {
+				dockerRegistry.ClearFaults()
+			})
+
+			for _, fault := range []h.Fault{
+				{Method: http.MethodGet, PathPattern: h.ManifestPathPattern, Times: 1, CloseConnection: true},
+				{Method: http.MethodGet, PathPattern: h.ManifestPathPattern, Times: 1, TruncateBody: true},
+				{Method: http.MethodGet, PathPattern: h.ManifestPathPattern, Times: 1, StatusCode: http.StatusTooManyRequests},
+				{Method: http.MethodGet, PathPattern: h.ManifestPathPattern, Times: 1, StatusCode: http.StatusInternalServerError, Latency: 100 * time.Millisecond},
+			} {
+				fault := fault
+
+				it(fmt.Sprintf("fetches the base image after a failure (%+v)", fault), func() {
+					injected := dockerRegistry.InjectedFaultCount()
+					dockerRegistry.InjectFault(fault)
+
+					img, err := remote.NewImage(repoName, authn.DefaultKeychain, remote.FromBaseImage(baseImageName))
+					h.AssertNil(t, err)
+
+					h.AssertEq(t, dockerRegistry.InjectedFaultCount(), injected+1)
+					label, err := img.Label("some-label")
+					h.AssertNil(t, err)
+					h.AssertEq(t, label, "some-value")
+				})
+			}
+
+			it("pushes the image after a blob upload failure", func() {
+				injected := dockerRegistry.InjectedFaultCount()
+				dockerRegistry.InjectFault(h.Fault{Method: http.MethodPatch, PathPattern: h.BlobUploadPathPattern, Times: 1, StatusCode: http.StatusInternalServerError})
+				img, err := remote.NewImage(repoName, authn.DefaultKeychain, remote.FromBaseImage(baseImageName))
+				h.AssertNil(t, err)
+				layerPath, err := h.CreateSingleFileLayerTar("/new-layer.txt", "new-layer", "linux")
+				h.AssertNil(t, err)
+				defer os.Remove(layerPath)
+				h.AssertNil(t, img.AddLayer(layerPath))
+
+				h.AssertNil(t, img.Save())
+
+				h.AssertEq(t, dockerRegistry.InjectedFaultCount(), injected+1)
+				h.AssertEq(t, img.Found(), true)
+			})
+
+			when("#WithRetryPolicy", func() {
+				var retryPolicy = imgutil.RetryPolicy{Attempts: 4, InitialBackoff: 10 * time.Millisecond, Factor: 2}
+
+				it("gives up after the attempts of the policy", func() {
+					injected := dockerRegistry.InjectedFaultCount()
+					dockerRegistry.InjectFault(h.Fault{Method: http.MethodGet, PathPattern: h.ManifestPathPattern, StatusCode: http.StatusServiceUnavailable})
+
+					_, err := remote.NewImage(repoName, authn.DefaultKeychain, remote.FromBaseImage(baseImageName), imgutil.WithRetryPolicy(retryPolicy))
+					h.AssertError(t, err, "Service Unavailable")
+
+					h.AssertEq(t, dockerRegistry.InjectedFaultCount(), injected+4)
+				})
+
+				it("waits for the delay in Retry-After", func() {
+					dockerRegistry.InjectFault(h.Fault{Method: http.MethodGet, PathPattern: h.ManifestPathPattern, Times: 1, StatusCode: http.StatusTooManyRequests, RetryAfter: time.Second})
+
+					start := time.Now()
+					_, err := remote.NewImage(repoName, authn.DefaultKeychain, remote.FromBaseImage(baseImageName), imgutil.WithRetryPolicy(retryPolicy))
+					h.AssertNil(t, err)
+
+					h.AssertEq(t, time.Since(start) >= time.Second, true)
+				})
+
+				it("retries pushes", func() {
+					injected := dockerRegistry.InjectedFaultCount()
+					dockerRegistry.InjectFault(h.Fault{Method: http.MethodPut, PathPattern: h.ManifestPathPattern, Times: 3, StatusCode: http.StatusTooManyRequests})
+					img, err := remote.NewImage(repoName, authn.DefaultKeychain, imgutil.WithRetryPolicy(retryPolicy))
+					h.AssertNil(t, err)
+
+					h.AssertNil(t, img.Save())
+
+					h.AssertEq(t, dockerRegistry.InjectedFaultCount(), injected+3)
+				})
+
+				it("retries deletes", func() {
+					origImage, err := remote.NewImage(repoName, authn.DefaultKeychain)
+					h.AssertNil(t, err)
+					h.AssertNil(t, origImage.Save())
+					identifier, err := origImage.Identifier()
+					h.AssertNil(t, err)
+					img, err := remote.NewImage(identifier.String(), authn.DefaultKeychain, remote.FromBaseImage(identifier.String()), imgutil.WithRetryPolicy(retryPolicy))
+					h.AssertNil(t, err)
+					dockerRegistry.InjectFault(h.Fault{Method: http.MethodDelete, Times: 1, StatusCode: http.StatusBadGateway})
+
+					h.AssertNil(t, img.Delete())
+
+					h.AssertEq(t, img.Found(), false)
+				})
+			})
+		})
+
+		when("#WithRegistryMirrors", func() {
+			var (
+				baseImageName string
+				mirror        string
+			)
+
+			// saveLabeledImage saves an image with the label some-label set to the given value
+			saveLabeledImage := func(repoName, value string) {
+				img, err := remote.NewImage(repoName, authn.DefaultKeychain)
+				h.AssertNil(t, err)
+				h.AssertNil(t, img.SetLabel("some-label", value))
+				h.AssertNil(t, img.Save())
+			}
+
+			it.Before(func() {
+				baseImageName = newTestImageName()
+				mirror = dockerRegistry.RepoName("mirror-" + h.RandString(10))
+				saveLabeledImage(baseImageName, "upstream-value")
+			})
+
+			it("reads the base image from the mirror", func() {
+				ref, err := name.ParseReference(baseImageName, name.WeakValidation)
+				h.AssertNil(t, err)
+				saveLabeledImage(mirror+"/"+ref.Context().RepositoryStr(), "mirror-value")
+
+				img, err := remote.NewImage(
+					repoName,
+					authn.DefaultKeychain,
+					remote.FromBaseImage(baseImageName),
+					remote.WithRegistryMirrors(dockerRegistry.RepoName(""), mirror),
+				)
+				h.AssertNil(t, err)
+
+				label, err := img.Label("some-label")
+				h.AssertNil(t, err)
+				h.AssertEq(t, label, "mirror-value")
+			})
+
+			it("falls back to the registry if the mirror does not have the image", func() {
+				img, err := remote.NewImage(
+					repoName,
+					authn.DefaultKeychain,
+					remote.FromBaseImage(baseImageName),
+					remote.WithRegistryMirrors(dockerRegistry.RepoName(""), mirror),
+				)
+				h.AssertNil(t, err)
+
+				label, err := img.Label("some-label")
+				h.AssertNil(t, err)
+				h.AssertEq(t, label, "upstream-value")
+			})
+
+			it("saves the image to the registry", func() {
+				img, err := remote.NewImage(repoName, authn.DefaultKeychain, remote.WithRegistryMirrors(dockerRegistry.RepoName(""), mirror))
+				h.AssertNil(t, err)
+
+				h.AssertNil(t, img.Save())
+
+				h.AssertEq(t, img.Found(), true)
+				saved, err := remote.NewImage(repoName, authn.DefaultKeychain)
+				h.AssertNil(t, err)
+				h.AssertEq(t, saved.Found(), true)
+			})
+
+			when("#WithRegistryRewrite", func() {
+				it("reads the base image from the rewritten name", func() {
+					ref, err := name.ParseReference(baseImageName, name.WeakValidation)
+					h.AssertNil(t, err)
+					saveLabeledImage(mirror+"/"+ref.Context().RepositoryStr(), "rewritten-value")
+
+					img, err := remote.NewImage(
+						repoName,
+						authn.DefaultKeychain,
+						remote.FromBaseImage(baseImageName),
+						remote.WithRegistryRewrite(dockerRegistry.RepoName(""), mirror),
+					)
+					h.AssertNil(t, err)
+
+					label, err := img.Label("some-label")
+					h.AssertNil(t, err)
+					h.AssertEq(t, label, "rewritten-value")
+				})
+			})
+		})
 	})
 
 	when("#WorkingDir", func() {
@@ -1854,6 +2190,65 @@ func testImage(t *testing.T, when spec.G, it spec.S) {
 		})
 	})
 
+	when("#SaveFile", func() {
+		var (
+			img       *remote.Image
+			layerPath string
+		)
+
+		it.Before(func() {
+			var err error
+			layerPath, err = h.CreateSingleFileLayerTar("/new-layer.txt", "new-layer", "linux")
+			h.AssertNil(t, err)
+		})
+
+		it.After(func() {
+			os.Remove(layerPath)
+		})
+
+		it("saves a docker archive by default", func() {
+			var err error
+			img, err = remote.NewImage(repoName, authn.DefaultKeychain)
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.SetLabel("some-label", "some-value"))
+			h.AssertNil(t, img.AddLayer(layerPath))
+
+			path, err := img.SaveFile()
+			h.AssertNil(t, err)
+			defer os.Remove(path)
+
+			tag, err := name.NewTag(repoName)
+			h.AssertNil(t, err)
+			archived, err := tarball.ImageFromPath(path, &tag)
+			h.AssertNil(t, err)
+			assertSameImage(t, archived, img)
+		})
+
+		it("saves an OCI archive when requested", func() {
+			var err error
+			img, err = remote.NewImage(repoName, authn.DefaultKeychain, imgutil.WithArchiveFormat(imgutil.OCIArchive))
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.SetLabel("some-label", "some-value"))
+			h.AssertNil(t, img.AddLayer(layerPath))
+
+			path, err := img.SaveFile()
+			h.AssertNil(t, err)
+			defer os.Remove(path)
+
+			layoutDir := t.TempDir()
+			h.Untar(t, path, layoutDir)
+			index, err := layout.ImageIndexFromPath(layoutDir)
+			h.AssertNil(t, err)
+			indexManifest, err := index.IndexManifest()
+			h.AssertNil(t, err)
+			h.AssertEq(t, len(indexManifest.Manifests), 1)
+			h.AssertEq(t, indexManifest.Manifests[0].Annotations["org.opencontainers.image.ref.name"], "latest")
+			archived, err := index.Image(indexManifest.Manifests[0].Digest)
+			h.AssertNil(t, err)
+			assertSameImage(t, archived, img)
+		})
+	})
+
 	when("#Found", func() {
 		when("it exists", func() {
 			it("returns true, nil", func() {
@@ -1969,7 +2364,70 @@ func testImage(t *testing.T, when spec.G, it spec.S) {
 				h.AssertNil(t, err)
 
 				h.AssertEq(t, img.Found(), false)
-				h.AssertError(t, img.Delete(), "NAME_UNKNOWN")
+				err = img.Delete()
+				h.AssertError(t, err, "NAME_UNKNOWN")
+				h.AssertEq(t, errors.Is(err, imgutil.ErrImageNotFound), true)
+			})
+		})
+	})
+
+	when("#WithProgress", func() {
+		it("reports the bytes pushed for each layer", func() {
+			var updates []imgutil.Progress
+			img, err := remote.NewImage(repoName, authn.DefaultKeychain, imgutil.WithProgress(func(p imgutil.Progress) {
+				updates = append(updates, p)
+			}))
+			h.AssertNil(t, err)
+			layerPath, err := h.CreateSingleFileLayerTar("/new-layer.txt", "new-layer", "linux")
+			h.AssertNil(t, err)
+			defer os.Remove(layerPath)
+			h.AssertNil(t, img.AddLayer(layerPath))
+
+			h.AssertNil(t, img.Save())
+
+			h.AssertEq(t, len(updates) > 0, true)
+			layers, err := img.UnderlyingImage().Layers()
+			h.AssertNil(t, err)
+			digest, err := layers[0].Digest()
+			h.AssertNil(t, err)
+			last := updates[len(updates)-1]
+			h.AssertEq(t, last.Layer, digest.String())
+			h.AssertEq(t, last.Complete, last.Total)
+		})
+	})
+
+	when("#WithContext", func() {
+		when("the construction context is done", func() {
+			it("returns an error when loading the base image", func() {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+
+				_, err := remote.NewImage(
+					repoName,
+					authn.DefaultKeychain,
+					remote.FromBaseImage(repoName),
+					imgutil.WithContext(ctx),
+				)
+				h.AssertError(t, err, context.Canceled.Error())
+			})
+		})
+
+		when("the context provided to a method is done", func() {
+			it("does not make the registry calls", func() {
+				img, err := remote.NewImage(repoName, authn.DefaultKeychain)
+				h.AssertNil(t, err)
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+
+				h.AssertError(t, img.SaveWithContext(ctx), context.Canceled.Error())
+				h.AssertEq(t, img.FoundWithContext(ctx), false)
+				h.AssertEq(t, img.Found(), false)
+
+				h.AssertNil(t, img.Save())
+				h.AssertEq(t, img.FoundWithContext(context.Background()), true)
+				h.AssertEq(t, img.FoundWithContext(ctx), false)
+				h.AssertError(t, img.DeleteWithContext(ctx), context.Canceled.Error())
+				h.AssertEq(t, img.Found(), true)
 			})
 		})
 	})
@@ -2176,3 +2634,25 @@ func testImage(t *testing.T, when spec.G, it spec.S) {
 		})
 	})
 }
+
+func assertSameImage(t *testing.T, actual v1.Image, expected v1.Image) {
+	t.Helper()
+	actualConfig, err := actual.ConfigName()
+	h.AssertNil(t, err)
+	expectedConfig, err := expected.ConfigName()
+	h.AssertNil(t, err)
+	h.AssertEq(t, actualConfig, expectedConfig)
+
+	actualLayers, err := actual.Layers()
+	h.AssertNil(t, err)
+	expectedLayers, err := expected.Layers()
+	h.AssertNil(t, err)
+	h.AssertEq(t, len(actualLayers), len(expectedLayers))
+	for idx := range expectedLayers {
+		actualDiffID, err := actualLayers[idx].DiffID()
+		h.AssertNil(t, err)
+		expectedDiffID, err := expectedLayers[idx].DiffID()
+		h.AssertNil(t, err)
+		h.AssertEq(t, actualDiffID, expectedDiffID)
+	}
+}
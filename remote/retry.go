@@ -0,0 +1,182 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+
+	"github.com/buildpacks/imgutil"
+)
+
+func processRetryPolicyOption(policy imgutil.RetryPolicy) imgutil.RetryPolicy {
+	if policy.Attempts <= 0 {
+		return imgutil.DefaultRetryPolicy()
+	}
+	if policy.RetryableStatusCodes == nil {
+		policy.RetryableStatusCodes = imgutil.DefaultRetryPolicy().RetryableStatusCodes
+	}
+	return policy
+}
+
+// remoteOptions returns the options for registry operations, retrying according to the provided policy.
+// Each failure is retried by exactly one layer, so that a request is sent at most `policy.Attempts` times:
+//   - the retryTransport retries the requests that it can send again (i.e., all requests except for streamed blob uploads)
+//     on network errors and responses with a retryable status code, honoring `Retry-After`
+//   - go-containerregistry retries blob uploads as a whole when a request that the retryTransport cannot send again fails
+//   - retry retries operations that fail with a network error while reading a response body
+func remoteOptions(ctx context.Context, auth authn.Authenticator, transport http.RoundTripper, policy imgutil.RetryPolicy) []remote.Option {
+	return []remote.Option{
+		remote.WithAuth(auth),
+		remote.WithTransport(&retryTransport{inner: transport, policy: policy}),
+		remote.WithContext(ctx),
+		remote.WithRetryStatusCodes(), // retried by the retryTransport
+		remote.WithRetryBackoff(remote.Backoff{
+			Duration: policy.InitialBackoff,
+			Factor:   policy.Factor,
+			Jitter:   policy.Jitter,
+			Steps:    max(policy.Attempts, 1),
+			Cap:      policy.MaxBackoff,
+		}),
+		remote.WithRetryPredicate(func(err error) bool {
+			return isRetryableError(err, policy) && !retriedByTransport(err)
+		}),
+	}
+}
+
+// retry calls f until it succeeds, it fails with an error that is not a network error,
+// or the attempts of the policy are exhausted.
+// Network errors of requests are retried by the retryTransport, so only those while reading a response body are retried here.
+func retry(ctx context.Context, policy imgutil.RetryPolicy, f func() error) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = f(); err == nil || attempt >= policy.Attempts || !isNetworkError(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(policy.Backoff(attempt)):
+		}
+	}
+}
+
+func isRetryableError(err error, policy imgutil.RetryPolicy) bool {
+	var transportErr *transport.Error
+	if errors.As(err, &transportErr) {
+		return policy.IsRetryableStatusCode(transportErr.StatusCode)
+	}
+	return isNetworkError(err)
+}
+
+func isNetworkError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	return errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, net.ErrClosed)
+}
+
+// retryTransport retries requests on network errors and on responses with a retryable status code,
+// waiting for the backoff of the policy or for the delay in the `Retry-After` header of the response, whichever is longer.
+// Requests with a body that cannot be sent again (i.e., without `GetBody`, like streamed blob uploads) are not retried;
+// go-containerregistry retries the whole upload instead.
+type retryTransport struct {
+	inner  http.RoundTripper
+	policy imgutil.RetryPolicy
+}
+
+func (t *retryTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if !canSendAgain(request) {
+		return t.inner.RoundTrip(request)
+	}
+	for attempt := 1; ; attempt++ {
+		response, err := t.inner.RoundTrip(request)
+		if err != nil && !isNetworkError(err) {
+			return nil, err
+		}
+		if attempt >= t.policy.Attempts {
+			if err != nil {
+				return nil, &retriedError{err: err}
+			}
+			return response, nil
+		}
+		if err == nil && !t.policy.IsRetryableStatusCode(response.StatusCode) {
+			return response, nil
+		}
+
+		delay := t.policy.Backoff(attempt)
+		if response != nil {
+			if retryAfter := retryAfterFrom(response.Header); retryAfter > delay {
+				delay = retryAfter
+			}
+			_, _ = io.Copy(io.Discard, response.Body)
+			response.Body.Close()
+		}
+		select {
+		case <-request.Context().Done():
+			return nil, request.Context().Err()
+		case <-time.After(delay):
+		}
+
+		if request.GetBody != nil {
+			body, err := request.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			request = request.Clone(request.Context())
+			request.Body = body
+		}
+	}
+}
+
+// retriedError is returned by the retryTransport when a request still fails with a network error after all its attempts.
+// It does not unwrap to the network error, so that go-containerregistry (which also retries network errors of requests) and
+// retry do not retry the request again.
+type retriedError struct {
+	err error
+}
+
+func (e *retriedError) Error() string {
+	return e.err.Error()
+}
+
+// retriedByTransport returns true if the error is from a request that the retryTransport already retried.
+func retriedByTransport(err error) bool {
+	var transportErr *transport.Error
+	if errors.As(err, &transportErr) {
+		return transportErr.Request != nil && canSendAgain(transportErr.Request)
+	}
+	var retriedErr *retriedError
+	return errors.As(err, &retriedErr)
+}
+
+func canSendAgain(request *http.Request) bool {
+	return request.Body == nil || request.Body == http.NoBody || request.GetBody != nil
+}
+
+// retryAfterFrom returns the delay in the `Retry-After` header, given in seconds or as a date, or zero.
+func retryAfterFrom(header http.Header) time.Duration {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date)
+	}
+	return 0
+}
@@ -1,20 +1,27 @@
 package remote
 
 import (
-	"crypto/tls"
+	"context"
 	"fmt"
 	"net/http"
 
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/google/go-containerregistry/pkg/v1/static"
 	"github.com/google/go-containerregistry/pkg/v1/types"
+	"golang.org/x/sync/errgroup"
 
 	"github.com/buildpacks/imgutil"
 )
 
 func (i *Image) Save(additionalNames ...string) error {
-	return i.SaveAs(i.Name(), additionalNames...)
+	return i.SaveAsWithContext(i.ctx, i.Name(), additionalNames...)
+}
+
+func (i *Image) SaveWithContext(ctx context.Context, additionalNames ...string) error {
+	return i.SaveAsWithContext(ctx, i.Name(), additionalNames...)
 }
 
 var (
@@ -23,9 +30,16 @@ var (
 )
 
 func (i *Image) SaveAs(name string, additionalNames ...string) error {
+	return i.SaveAsWithContext(i.ctx, name, additionalNames...)
+}
+
+func (i *Image) SaveAsWithContext(ctx context.Context, name string, additionalNames ...string) error {
 	if err := i.SetCreatedAtAndHistory(); err != nil {
 		return err
 	}
+	if err := i.CompressLayers(); err != nil {
+		return err
+	}
 
 	// add empty layer if needed
 	layers, err := i.Layers()
@@ -38,12 +52,32 @@ func (i *Image) SaveAs(name string, additionalNames ...string) error {
 		}
 	}
 
-	// save
-	var diagnostics []imgutil.SaveDiagnostic
+	// save, pushing the image once per repository (concurrently) and only the manifest for the other names in the repository
 	allNames := append([]string{name}, additionalNames...)
-	for _, n := range allNames {
-		if err := i.doSave(n); err != nil {
-			diagnostics = append(diagnostics, imgutil.SaveDiagnostic{ImageName: n, Cause: err})
+	causes := make([]error, len(allNames))
+	var g errgroup.Group
+	g.SetLimit(maxConcurrentPushes)
+	for _, idxs := range namesByRepository(allNames) {
+		idxs := idxs
+		g.Go(func() error {
+			pushed := false
+			for _, idx := range idxs {
+				if pushed {
+					causes[idx] = i.doTag(ctx, allNames[idx])
+					continue
+				}
+				causes[idx] = i.doSave(ctx, allNames[idx])
+				pushed = causes[idx] == nil
+			}
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	var diagnostics []imgutil.SaveDiagnostic
+	for idx, cause := range causes {
+		if cause != nil {
+			diagnostics = append(diagnostics, imgutil.SaveDiagnostic{ImageName: allNames[idx], Cause: cause})
 		}
 	}
 	if len(diagnostics) > 0 {
@@ -52,26 +86,79 @@ func (i *Image) SaveAs(name string, additionalNames ...string) error {
 	return nil
 }
 
-func (i *Image) doSave(imageName string) error {
-	reg := getRegistrySetting(i.repoName, i.registrySettings)
-	ref, auth, err := referenceForRepoName(i.keychain, imageName, reg.Insecure)
+// maxConcurrentPushes is the maximum number of repositories an image is pushed to concurrently.
+const maxConcurrentPushes = 4
+
+// namesByRepository groups the indexes of the provided names by repository, in the order of the names.
+// Names that cannot be parsed are in their own group.
+func namesByRepository(names []string) [][]int {
+	var groups [][]int
+	groupIdxs := make(map[string]int)
+	for idx, n := range names {
+		ref, err := name.ParseReference(n, name.WeakValidation)
+		if err != nil {
+			groups = append(groups, []int{idx})
+			continue
+		}
+		if groupIdx, ok := groupIdxs[ref.Context().Name()]; ok {
+			groups[groupIdx] = append(groups[groupIdx], idx)
+			continue
+		}
+		groupIdxs[ref.Context().Name()] = len(groups)
+		groups = append(groups, []int{idx})
+	}
+	return groups
+}
+
+func (i *Image) doSave(ctx context.Context, imageName string) error {
+	ref, auth, rt, err := i.pushTarget(imageName)
 	if err != nil {
 		return err
 	}
+	var recorder *pushRecorder
+	if i.pushReport != nil {
+		recorder = newPushRecorder(rt)
+		rt = recorder
+	}
+	var image v1.Image = i.CNBImageCore
+	if i.progress != nil {
+		image = &progressImage{Image: image, progress: i.progress}
+	}
+	if err = remote.Write(ref, image, remoteOptions(ctx, auth, rt, i.retryPolicy)...); err != nil {
+		return registryError(err)
+	}
+	if recorder != nil {
+		i.pushReport(recorder.report(imageName))
+	}
+	return nil
+}
 
-	return remote.Write(ref, i.CNBImageCore,
-		remote.WithAuth(auth),
-		remote.WithTransport(getTransport(reg.Insecure)),
-	)
+// doTag pushes the manifest of the image with the provided name, to a repository the image was already pushed to.
+func (i *Image) doTag(ctx context.Context, imageName string) error {
+	ref, auth, rt, err := i.pushTarget(imageName)
+	if err != nil {
+		return err
+	}
+	if err = remote.Put(ref, i.CNBImageCore, remoteOptions(ctx, auth, rt, i.retryPolicy)...); err != nil {
+		return registryError(err)
+	}
+	if i.pushReport != nil {
+		i.pushReport(imgutil.PushReport{ImageName: imageName})
+	}
+	return nil
 }
 
-func getTransport(insecure bool) http.RoundTripper {
-	if insecure {
-		return &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: true, // #nosec G402
-			},
-		}
+// pushTarget returns the reference, authenticator and transport to push the image with the provided name,
+// using the settings of the registry of the name.
+func (i *Image) pushTarget(imageName string) (name.Reference, authn.Authenticator, http.RoundTripper, error) {
+	reg := getRegistrySetting(imageName, i.registrySettings)
+	ref, auth, err := referenceForRepoName(i.keychain, imageName, reg.Insecure)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	rt, err := getTransport(reg, i.transport)
+	if err != nil {
+		return nil, nil, nil, err
 	}
-	return http.DefaultTransport
+	return ref, auth, rt, nil
 }
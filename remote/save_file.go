@@ -1,9 +1,19 @@
 package remote
 
 import (
-	"errors"
+	"github.com/buildpacks/imgutil"
 )
 
+// SaveFile saves the image as a tar file in the format chosen with imgutil.WithArchiveFormat (a docker archive by default),
+// without requiring a daemon, and returns the path of the file.
+// Layers from the base image are fetched from the registry.
+// Like Save, it sets the created at time and history of the image, and compresses its layers as requested.
 func (i *Image) SaveFile() (string, error) {
-	return "", errors.New("not yet implemented")
+	if err := i.SetCreatedAtAndHistory(); err != nil {
+		return "", err
+	}
+	if err := i.CompressLayers(); err != nil {
+		return "", err
+	}
+	return imgutil.SaveArchiveFile(i.CNBImageCore, i.Name(), i.archiveFormat)
 }
@@ -0,0 +1,439 @@
+package remote_test
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/compression"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil"
+	"github.com/buildpacks/imgutil/remote"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestSave(t *testing.T) {
+	spec.Run(t, "Save", testSave, spec.Sequential(), spec.Report(report.Terminal{}))
+}
+
+// testSave runs against an in-process registry that, like most registries, keeps track of the blobs in each repository
+// and supports mounting blobs from other repositories.
+func testSave(t *testing.T, when spec.G, it spec.S) {
+	var (
+		server    *httptest.Server
+		host      string
+		reports   []imgutil.PushReport
+		layerPath string
+	)
+
+	it.Before(func() {
+		server = httptest.NewServer(newMountingRegistry())
+		host = strings.TrimPrefix(server.URL, "http://")
+		reports = nil
+		var err error
+		layerPath, err = h.CreateSingleFileLayerTar("/some-file.txt", "some-content", "linux")
+		h.AssertNil(t, err)
+	})
+
+	it.After(func() {
+		server.Close()
+		os.Remove(layerPath)
+	})
+
+	var mutex sync.Mutex
+	withPushReport := remote.WithPushReport(func(report imgutil.PushReport) { // called concurrently
+		mutex.Lock()
+		defer mutex.Unlock()
+		reports = append(reports, report)
+	})
+
+	// saveBaseImage saves an image with a layer and returns the digest of the layer
+	saveBaseImage := func(repoName string) string {
+		baseImage, err := remote.NewImage(repoName, authn.DefaultKeychain)
+		h.AssertNil(t, err)
+		h.AssertNil(t, baseImage.AddLayer(layerPath))
+		h.AssertNil(t, baseImage.Save())
+		layers, err := baseImage.Layers()
+		h.AssertNil(t, err)
+		digest, err := layers[0].Digest()
+		h.AssertNil(t, err)
+		return digest.String()
+	}
+
+	when("#WithPushReport", func() {
+		it("reports the uploaded blobs", func() {
+			img, err := remote.NewImage(host+"/some-image", authn.DefaultKeychain, withPushReport)
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.AddLayer(layerPath))
+
+			h.AssertNil(t, img.Save())
+
+			h.AssertEq(t, len(reports), 1)
+			h.AssertEq(t, reports[0].ImageName, host+"/some-image")
+			h.AssertEq(t, len(reports[0].Uploaded), 2) // the layer and the config
+			h.AssertEq(t, len(reports[0].Mounted), 0)
+		})
+
+		it("mounts the layers of the base image from its repository", func() {
+			baseLayerDigest := saveBaseImage(host + "/some-base-image")
+			img, err := remote.NewImage(host+"/some-image", authn.DefaultKeychain, remote.FromBaseImage(host+"/some-base-image"), withPushReport)
+			h.AssertNil(t, err)
+
+			h.AssertNil(t, img.Save())
+
+			h.AssertEq(t, reports[0].Mounted, []imgutil.BlobMount{{Digest: baseLayerDigest, From: "some-base-image"}})
+			h.AssertEq(t, len(reports[0].Uploaded), 1) // the config
+		})
+
+		it("mounts the reused layers of the previous image from its repository", func() {
+			layerDigest := saveBaseImage(host + "/some-previous-image")
+			img, err := remote.NewImage(host+"/some-image", authn.DefaultKeychain, remote.WithPreviousImage(host+"/some-previous-image"), withPushReport)
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.ReuseLayer(h.FileDiffID(t, layerPath)))
+
+			h.AssertNil(t, img.Save())
+
+			h.AssertEq(t, reports[0].Mounted, []imgutil.BlobMount{{Digest: layerDigest, From: "some-previous-image"}})
+		})
+
+		it("skips the blobs that already exist in the repository", func() {
+			baseLayerDigest := saveBaseImage(host + "/some-image")
+			img, err := remote.NewImage(host+"/some-image", authn.DefaultKeychain, remote.FromBaseImage(host+"/some-image"), withPushReport)
+			h.AssertNil(t, err)
+			otherLayerPath, err := h.CreateSingleFileLayerTar("/other-file.txt", "other-content", "linux")
+			h.AssertNil(t, err)
+			defer os.Remove(otherLayerPath)
+			h.AssertNil(t, img.AddLayer(otherLayerPath))
+
+			h.AssertNil(t, img.Save())
+
+			h.AssertEq(t, reports[0].Existing, []string{baseLayerDigest})
+			h.AssertEq(t, len(reports[0].Uploaded), 2) // the other layer and the config
+			h.AssertEq(t, len(reports[0].Mounted), 0)
+		})
+	})
+
+	when("#WithProgress", func() {
+		var (
+			updates     []imgutil.Progress
+			withUpdates = imgutil.WithProgress(func(p imgutil.Progress) { // called concurrently
+				mutex.Lock()
+				defer mutex.Unlock()
+				updates = append(updates, p)
+			})
+		)
+
+		it.Before(func() {
+			updates = nil
+		})
+
+		it("reports the bytes uploaded for each layer", func() {
+			img, err := remote.NewImage(host+"/some-image", authn.DefaultKeychain, withUpdates)
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.AddLayer(layerPath))
+			layers, err := img.UnderlyingImage().Layers()
+			h.AssertNil(t, err)
+			digest, err := layers[0].Digest()
+			h.AssertNil(t, err)
+			size, err := layers[0].Size()
+			h.AssertNil(t, err)
+
+			h.AssertNil(t, img.Save())
+
+			h.AssertEq(t, len(updates) > 0, true)
+			last := updates[len(updates)-1]
+			h.AssertEq(t, last, imgutil.Progress{Layer: digest.String(), Complete: size, Total: size})
+		})
+
+		it("does not report the layers mounted from other repositories", func() {
+			saveBaseImage(host + "/some-base-image")
+			img, err := remote.NewImage(host+"/some-image", authn.DefaultKeychain, remote.FromBaseImage(host+"/some-base-image"), withUpdates, withPushReport)
+			h.AssertNil(t, err)
+
+			h.AssertNil(t, img.Save())
+
+			h.AssertEq(t, len(reports[0].Mounted), 1)
+			h.AssertEq(t, len(updates), 0)
+		})
+	})
+
+	when("#WithLayerCompression", func() {
+		it("recompresses the layers of the base image", func() {
+			saveBaseImage(host + "/some-base-image")
+			img, err := remote.NewImage(
+				host+"/some-image",
+				authn.DefaultKeychain,
+				remote.FromBaseImage(host+"/some-base-image"),
+				imgutil.WithLayerCompression(imgutil.LayerCompression{Algorithm: compression.ZStd}),
+			)
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.SetAnnotation("some-key", "some-value"))
+
+			h.AssertNil(t, img.Save())
+
+			saved, err := remote.NewImage(host+"/some-other-image", authn.DefaultKeychain, remote.FromBaseImage(host+"/some-image"))
+			h.AssertNil(t, err)
+			manifest, err := saved.UnderlyingImage().Manifest()
+			h.AssertNil(t, err)
+			h.AssertEq(t, manifest.Layers[0].MediaType, types.OCILayerZStd)
+			h.AssertEq(t, manifest.Annotations["some-key"], "some-value")
+			configFile, err := saved.UnderlyingImage().ConfigFile()
+			h.AssertNil(t, err)
+			h.AssertEq(t, configFile.RootFS.DiffIDs[0].String(), h.FileDiffID(t, layerPath))
+		})
+	})
+
+	when("#WithRetryPolicy", func() {
+		var (
+			retryPolicy   = imgutil.RetryPolicy{Attempts: 3, InitialBackoff: time.Millisecond}
+			failingServer *httptest.Server
+		)
+
+		it.After(func() {
+			failingServer.Close()
+		})
+
+		// failingHost returns the host of a registry that fails the matching requests with the provided function,
+		// and a function returning the number of matching requests
+		failingHost := func(matches func(*http.Request) bool, fail func(http.ResponseWriter)) (string, func() int) {
+			var (
+				count      int
+				countMutex sync.Mutex
+			)
+			failingServer = httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+				if matches(request) {
+					countMutex.Lock()
+					count++
+					countMutex.Unlock()
+					fail(response)
+					return
+				}
+				server.Config.Handler.ServeHTTP(response, request)
+			}))
+			failingServer.Config.SetKeepAlivesEnabled(false) // otherwise, net/http sends requests failing on a reused connection again
+			return strings.TrimPrefix(failingServer.URL, "http://"), func() int {
+				countMutex.Lock()
+				defer countMutex.Unlock()
+				return count
+			}
+		}
+		internalServerError := func(response http.ResponseWriter) {
+			http.Error(response, "some-error", http.StatusInternalServerError)
+		}
+
+		it("sends a failing manifest push at most the attempts of the policy", func() {
+			failingHost, count := failingHost(func(request *http.Request) bool {
+				return request.Method == http.MethodPut && strings.Contains(request.URL.Path, "/manifests/")
+			}, internalServerError)
+			img, err := remote.NewImage(failingHost+"/some-image", authn.DefaultKeychain, imgutil.WithRetryPolicy(retryPolicy))
+			h.AssertNil(t, err)
+
+			h.AssertError(t, img.Save(), "Internal Server Error")
+			h.AssertEq(t, count(), 3)
+		})
+
+		it("sends a failing blob upload at most the attempts of the policy", func() {
+			failingHost, count := failingHost(func(request *http.Request) bool {
+				return request.Method == http.MethodPut && blobUploadPathPattern.MatchString(request.URL.Path)
+			}, internalServerError)
+			img, err := remote.NewImage(failingHost+"/some-image", authn.DefaultKeychain, imgutil.WithRetryPolicy(retryPolicy))
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.AddLayer(layerPath))
+
+			h.AssertError(t, img.Save(), "Internal Server Error")
+			h.AssertEq(t, count(), 6) // the layer and the config
+		})
+
+		it("sends a request failing with a network error at most the attempts of the policy", func() {
+			saveBaseImage(host + "/some-base-image")
+			failingHost, count := failingHost(func(request *http.Request) bool {
+				return request.Method == http.MethodGet && strings.Contains(request.URL.Path, "/manifests/")
+			}, func(response http.ResponseWriter) {
+				conn, _, err := response.(http.Hijacker).Hijack()
+				h.AssertNil(t, err)
+				conn.Close()
+			})
+
+			_, err := remote.NewImage(failingHost+"/some-image", authn.DefaultKeychain,
+				imgutil.FromBaseImage(failingHost+"/some-base-image"),
+				imgutil.WithRetryPolicy(retryPolicy),
+			)
+			h.AssertError(t, err, "EOF")
+			h.AssertEq(t, count(), 3)
+		})
+	})
+
+	when("#SaveFile", func() {
+		it("sets the created at time and history like Save", func() {
+			createdAt := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+			img, err := remote.NewImage(host+"/some-image", authn.DefaultKeychain, imgutil.WithCreatedAt(createdAt))
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.AddLayer(layerPath))
+
+			path, err := img.SaveFile()
+			h.AssertNil(t, err)
+			defer os.Remove(path)
+
+			archived, err := tarball.ImageFromPath(path, nil)
+			h.AssertNil(t, err)
+			configFile, err := archived.ConfigFile()
+			h.AssertNil(t, err)
+			h.AssertEq(t, configFile.Created.Time.Equal(createdAt), true)
+			h.AssertEq(t, len(configFile.History), 1)
+			h.AssertEq(t, configFile.History[0].Created.Time.Equal(createdAt), true)
+		})
+	})
+
+	when("#SaveAs", func() {
+		it("pushes the image once per repository and only the manifest for other names", func() {
+			img, err := remote.NewImage(host+"/some-image", authn.DefaultKeychain, withPushReport)
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.AddLayer(layerPath))
+
+			h.AssertNil(t, img.SaveAs(host+"/some-image", host+"/some-image:other-tag", host+"/other-image", host+"/other-image:other-tag"))
+
+			h.AssertEq(t, len(reports), 4)
+			for _, report := range reports {
+				switch report.ImageName {
+				case host + "/some-image", host + "/other-image":
+					h.AssertEq(t, len(report.Uploaded), 2) // the layer and the config
+				default:
+					h.AssertEq(t, len(report.Uploaded), 0)
+					h.AssertEq(t, len(report.Existing), 0)
+				}
+			}
+			for _, name := range []string{"/some-image", "/some-image:other-tag", "/other-image", "/other-image:other-tag"} {
+				saved, err := remote.NewImage(host+name, authn.DefaultKeychain)
+				h.AssertNil(t, err)
+				h.AssertEq(t, saved.Found(), true)
+			}
+		})
+
+		it("pushes the image to each registry with the settings of the registry", func() {
+			otherServer := httptest.NewServer(newMountingRegistry())
+			defer otherServer.Close()
+			otherHost := strings.TrimPrefix(otherServer.URL, "http://")
+			recorder, otherRecorder := &recordingTransport{}, &recordingTransport{}
+			img, err := remote.NewImage(host+"/some-image", authn.DefaultKeychain,
+				remote.WithRegistryTransport(host, recorder),
+				remote.WithRegistryTransport(otherHost, otherRecorder),
+			)
+			h.AssertNil(t, err)
+			h.AssertNil(t, img.AddLayer(layerPath))
+
+			h.AssertNil(t, img.SaveAs(host+"/some-image", otherHost+"/some-image", host+"/some-image:other-tag", otherHost+"/some-image:other-tag"))
+
+			for _, r := range []*recordingTransport{recorder, otherRecorder} {
+				h.AssertEq(t, r.count(http.MethodPut, "/v2/some-image/manifests/latest"), 1)
+				h.AssertEq(t, r.count(http.MethodPut, "/v2/some-image/manifests/other-tag"), 1)
+			}
+			for _, name := range []string{host + "/some-image:other-tag", otherHost + "/some-image:other-tag"} {
+				saved, err := remote.NewImage(name, authn.DefaultKeychain)
+				h.AssertNil(t, err)
+				h.AssertEq(t, saved.Found(), true)
+			}
+		})
+
+		it("reports failures per name", func() {
+			img, err := remote.NewImage(host+"/some-image", authn.DefaultKeychain)
+			h.AssertNil(t, err)
+
+			err = img.SaveAs(host+"/some-image", host+"/Some-Invalid-Image", host+"/some-image:other-tag")
+			saveErr, ok := err.(imgutil.SaveError)
+			h.AssertEq(t, ok, true)
+			h.AssertEq(t, len(saveErr.Errors), 1)
+			h.AssertEq(t, saveErr.Errors[0].ImageName, host+"/Some-Invalid-Image")
+
+			saved, err := remote.NewImage(host+"/some-image:other-tag", authn.DefaultKeychain)
+			h.AssertNil(t, err)
+			h.AssertEq(t, saved.Found(), true)
+		})
+
+		it("wraps the errors of the registry", func() {
+			denyingServer := httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+				if request.Method == http.MethodPut && strings.Contains(request.URL.Path, "/manifests/") {
+					http.Error(response, `{"errors":[{"code":"DENIED","message":"some-message"}]}`, http.StatusForbidden)
+					return
+				}
+				server.Config.Handler.ServeHTTP(response, request)
+			}))
+			defer denyingServer.Close()
+			denyingHost := strings.TrimPrefix(denyingServer.URL, "http://")
+			img, err := remote.NewImage(denyingHost+"/some-image", authn.DefaultKeychain)
+			h.AssertNil(t, err)
+
+			err = img.SaveAs(denyingHost + "/some-image")
+			h.AssertEq(t, errors.Is(err, imgutil.ErrUnauthorized), true)
+		})
+	})
+}
+
+var (
+	blobPathPattern       = regexp.MustCompile(`^/v2/(.+)/blobs/(sha256:[0-9a-f]+)$`)
+	blobUploadPathPattern = regexp.MustCompile(`^/v2/(.+)/blobs/uploads/`)
+)
+
+// mountingRegistry wraps a registry keeping all blobs together
+// so that blobs are only found in the repositories they were uploaded to or mounted into.
+type mountingRegistry struct {
+	handler http.Handler
+	mutex   sync.Mutex
+	blobs   map[string]map[string]bool // repository -> digest -> present
+}
+
+func newMountingRegistry() *mountingRegistry {
+	return &mountingRegistry{
+		handler: registry.New(registry.Logger(log.New(io.Discard, "", log.Lshortfile))),
+		blobs:   make(map[string]map[string]bool),
+	}
+}
+
+func (r *mountingRegistry) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	query := request.URL.Query()
+	if matches := blobPathPattern.FindStringSubmatch(request.URL.Path); matches != nil && request.Method == http.MethodHead && !r.has(matches[1], matches[2]) {
+		response.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if matches := blobUploadPathPattern.FindStringSubmatch(request.URL.Path); matches != nil {
+		switch {
+		case request.Method == http.MethodPost && query.Get("mount") != "" && r.has(query.Get("from"), query.Get("mount")):
+			r.add(matches[1], query.Get("mount"))
+			response.Header().Set("Location", "/v2/"+matches[1]+"/blobs/"+query.Get("mount"))
+			response.Header().Set("Docker-Content-Digest", query.Get("mount"))
+			response.WriteHeader(http.StatusCreated)
+			return
+		case request.Method == http.MethodPut && query.Get("digest") != "":
+			r.add(matches[1], query.Get("digest"))
+		}
+	}
+	r.handler.ServeHTTP(response, request)
+}
+
+func (r *mountingRegistry) has(repository, digest string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.blobs[repository][digest]
+}
+
+func (r *mountingRegistry) add(repository, digest string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.blobs[repository] == nil {
+		r.blobs[repository] = make(map[string]bool)
+	}
+	r.blobs[repository][digest] = true
+}
@@ -0,0 +1,64 @@
+package remote
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/buildpacks/imgutil"
+)
+
+// getTransport returns the transport for requests to a registry with the provided setting:
+// the transport of the setting if any, else the provided transport if any, else http.DefaultTransport.
+// The TLS and proxy settings of the registry are applied to a clone of the transport if it is an *http.Transport;
+// any other transport is used as is.
+func getTransport(reg imgutil.RegistrySetting, withTransport http.RoundTripper) (http.RoundTripper, error) {
+	base := http.DefaultTransport
+	switch {
+	case reg.Transport != nil:
+		base = reg.Transport
+	case withTransport != nil:
+		base = withTransport
+	}
+	httpTransport, ok := base.(*http.Transport)
+	if !ok || (!reg.Insecure && len(reg.CACertificates) == 0 && len(reg.ClientCertificate) == 0 && reg.Proxy == "") {
+		return base, nil
+	}
+
+	transport := httpTransport.Clone()
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	if reg.Insecure {
+		transport.TLSClientConfig.InsecureSkipVerify = true // #nosec G402
+	}
+	if len(reg.CACertificates) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		for idx, pem := range reg.CACertificates {
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates found in CA certificate %d", idx)
+			}
+		}
+		transport.TLSClientConfig.RootCAs = pool
+	}
+	if len(reg.ClientCertificate) > 0 || len(reg.ClientKey) > 0 {
+		cert, err := tls.X509KeyPair(reg.ClientCertificate, reg.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		transport.TLSClientConfig.Certificates = []tls.Certificate{cert}
+	}
+	if reg.Proxy != "" {
+		proxyURL, err := url.Parse(reg.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("parsing proxy URL: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	return transport, nil
+}
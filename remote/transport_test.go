@@ -0,0 +1,342 @@
+package remote_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/imgutil"
+	"github.com/buildpacks/imgutil/remote"
+	h "github.com/buildpacks/imgutil/testhelpers"
+)
+
+func TestTransport(t *testing.T) {
+	spec.Run(t, "Transport", testTransport, spec.Sequential(), spec.Report(report.Terminal{}))
+}
+
+// testTransport runs against an in-process registry. When served over TLS, the registry has a certificate for "example.com"
+// and is reached through a proxy, since the name does not resolve to the registry.
+func testTransport(t *testing.T, when spec.G, it spec.S) {
+	var (
+		server    *httptest.Server
+		proxy     *httptest.Server
+		proxied   atomic.Int32
+		caPEM     []byte
+		repoName  string
+		proxyOpts []imgutil.ImageOption
+	)
+
+	it.Before(func() {
+		server = httptest.NewUnstartedServer(registry.New(registry.Logger(log.New(io.Discard, "", log.Lshortfile))))
+		proxied.Store(0)
+		proxy = httptest.NewServer(connectProxy(server.Listener.Addr().String(), &proxied))
+		caPEM = nil
+		repoName = "example.com:" + port(server.Listener.Addr()) + "/some-image"
+		proxyOpts = []imgutil.ImageOption{remote.WithRegistryProxy("example.com", proxy.URL)}
+	})
+
+	it.After(func() {
+		server.Close()
+		proxy.Close()
+	})
+
+	startTLS := func() {
+		server.StartTLS()
+		caPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	}
+
+	when("#WithRegistryCACertificates", func() {
+		it("trusts the certificate authorities", func() {
+			startTLS()
+			img, err := remote.NewImage(repoName, authn.DefaultKeychain, append(proxyOpts, remote.WithRegistryCACertificates("example.com", caPEM))...)
+			h.AssertNil(t, err)
+
+			h.AssertNil(t, img.Save())
+
+			h.AssertEq(t, img.Found(), true)
+			h.AssertEq(t, proxied.Load() > 0, true)
+		})
+
+		it("fails to verify the registry without them", func() {
+			startTLS()
+			img, err := remote.NewImage(repoName, authn.DefaultKeychain, proxyOpts...)
+			h.AssertNil(t, err)
+
+			err = img.Save()
+			h.AssertError(t, err, "certificate")
+		})
+
+		when("names are on other registries", func() {
+			var (
+				otherServer   *httptest.Server
+				otherProxy    *httptest.Server
+				otherProxied  atomic.Int32
+				otherRepoName string
+				otherOpts     []imgutil.ImageOption
+			)
+
+			it.Before(func() {
+				startTLS()
+				otherServer = httptest.NewUnstartedServer(registry.New(registry.Logger(log.New(io.Discard, "", log.Lshortfile))))
+				otherServer.StartTLS()
+				otherProxied.Store(0)
+				otherProxy = httptest.NewServer(connectProxy(otherServer.Listener.Addr().String(), &otherProxied))
+				otherRepoName = "other.example.com:" + port(otherServer.Listener.Addr()) + "/other-image"
+				otherOpts = []imgutil.ImageOption{
+					remote.WithRegistryProxy("example.com", proxy.URL),
+					remote.WithRegistryCACertificates("example.com", caPEM),
+					remote.WithRegistryProxy("other.example.com", otherProxy.URL),
+				}
+			})
+
+			it.After(func() {
+				otherServer.Close()
+				otherProxy.Close()
+			})
+
+			it("saves each name with the settings of its registry", func() {
+				img, err := remote.NewImage(repoName, authn.DefaultKeychain, append(otherOpts,
+					remote.WithRegistryCACertificates("other.example.com", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: otherServer.Certificate().Raw})),
+				)...)
+				h.AssertNil(t, err)
+
+				h.AssertNil(t, img.Save(otherRepoName))
+
+				h.AssertEq(t, proxied.Load() > 0, true)
+				h.AssertEq(t, otherProxied.Load() > 0, true)
+			})
+
+			it("fails to save the names on registries with other certificate authorities", func() {
+				img, err := remote.NewImage(repoName, authn.DefaultKeychain, append(otherOpts,
+					remote.WithRegistryCACertificates("other.example.com", []byte("some-garbage")),
+				)...)
+				h.AssertNil(t, err)
+
+				err = img.Save(otherRepoName)
+
+				var saveErr imgutil.SaveError
+				h.AssertEq(t, errors.As(err, &saveErr), true)
+				h.AssertEq(t, len(saveErr.Errors), 1)
+				h.AssertEq(t, saveErr.Errors[0].ImageName, otherRepoName)
+				h.AssertError(t, saveErr.Errors[0].Cause, "no certificates found in CA certificate 0")
+			})
+
+			it("saves indexes with the settings of the registry of each name", func() {
+				idx, err := remote.NewIndex(repoName+"-index", authn.DefaultKeychain, append(otherOpts,
+					remote.WithRegistryCACertificates("other.example.com", []byte("some-garbage")),
+				)...)
+				h.AssertNil(t, err)
+
+				err = idx.SaveAs(repoName+"-index", otherRepoName+"-index")
+
+				var saveErr imgutil.SaveError
+				h.AssertEq(t, errors.As(err, &saveErr), true)
+				h.AssertEq(t, len(saveErr.Errors), 1)
+				h.AssertEq(t, saveErr.Errors[0].ImageName, otherRepoName+"-index")
+				h.AssertError(t, saveErr.Errors[0].Cause, "no certificates found in CA certificate 0")
+			})
+		})
+
+		it("fails if the certificates are not PEM-encoded", func() {
+			startTLS()
+			img, err := remote.NewImage(repoName, authn.DefaultKeychain, append(proxyOpts, remote.WithRegistryCACertificates("example.com", []byte("some-garbage")))...)
+			h.AssertNil(t, err)
+
+			err = img.Save()
+			h.AssertError(t, err, "no certificates found in CA certificate 0")
+		})
+	})
+
+	when("#WithRegistryClientCertificate", func() {
+		var clientCertPEM, clientKeyPEM []byte
+
+		it.Before(func() {
+			var clientCert *x509.Certificate
+			clientCert, clientCertPEM, clientKeyPEM = newClientCertificate(t)
+			clientCAs := x509.NewCertPool()
+			clientCAs.AddCert(clientCert)
+			server.TLS = &tls.Config{MinVersion: tls.VersionTLS12, ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+			startTLS()
+		})
+
+		it("authenticates with the certificate", func() {
+			img, err := remote.NewImage(
+				repoName,
+				authn.DefaultKeychain,
+				append(proxyOpts,
+					remote.WithRegistryCACertificates("example.com", caPEM),
+					remote.WithRegistryClientCertificate("example.com", clientCertPEM, clientKeyPEM),
+				)...,
+			)
+			h.AssertNil(t, err)
+
+			h.AssertNil(t, img.Save())
+
+			h.AssertEq(t, img.Found(), true)
+		})
+
+		it("is rejected by the registry without the certificate", func() {
+			img, err := remote.NewImage(repoName, authn.DefaultKeychain, append(proxyOpts, remote.WithRegistryCACertificates("example.com", caPEM))...)
+			h.AssertNil(t, err)
+
+			h.AssertError(t, img.Save(), "tls")
+		})
+	})
+
+	when("#WithTransport", func() {
+		var recorder *recordingTransport
+
+		it.Before(func() {
+			server.Start()
+			recorder = &recordingTransport{}
+			repoName = strings.TrimPrefix(server.URL, "http://") + "/some-image"
+		})
+
+		it("sends all requests through the transport", func() {
+			baseImage, err := remote.NewImage(repoName+"-base", authn.DefaultKeychain)
+			h.AssertNil(t, err)
+			h.AssertNil(t, baseImage.Save())
+
+			img, err := remote.NewImage(repoName, authn.DefaultKeychain, remote.FromBaseImage(repoName+"-base"), remote.WithTransport(recorder))
+			h.AssertNil(t, err)
+			h.AssertEq(t, recorder.count(http.MethodGet, "/v2/some-image-base/manifests/latest") > 0, true)
+
+			canReadWrite, err := img.CheckReadWriteAccess()
+			h.AssertNil(t, err)
+			h.AssertEq(t, canReadWrite, true)
+			h.AssertEq(t, recorder.count(http.MethodPost, "/v2/some-image/blobs/uploads/") > 0, true)
+
+			h.AssertNil(t, img.Save())
+			h.AssertEq(t, recorder.count(http.MethodPut, "/v2/some-image/manifests/latest"), 1)
+
+			h.AssertEq(t, img.Valid(), true)
+			h.AssertEq(t, recorder.count(http.MethodGet, "/v2/some-image/manifests/latest") > 0, true)
+
+			h.AssertNil(t, img.Delete())
+			h.AssertEq(t, recorder.count(http.MethodDelete, "") > 0, true)
+		})
+
+		when("#WithRegistryTransport", func() {
+			it("overrides the transport for the registry", func() {
+				registryRecorder := &recordingTransport{}
+				img, err := remote.NewImage(
+					repoName,
+					authn.DefaultKeychain,
+					remote.WithTransport(recorder),
+					remote.WithRegistryTransport(strings.TrimSuffix(repoName, "/some-image"), registryRecorder),
+				)
+				h.AssertNil(t, err)
+
+				h.AssertNil(t, img.Save())
+
+				h.AssertEq(t, registryRecorder.count(http.MethodPut, "/v2/some-image/manifests/latest"), 1)
+				h.AssertEq(t, recorder.count("", ""), 0)
+			})
+		})
+	})
+}
+
+// recordingTransport records the requests it sends through http.DefaultTransport.
+type recordingTransport struct {
+	mutex    sync.Mutex
+	requests []*http.Request
+}
+
+func (r *recordingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	r.mutex.Lock()
+	r.requests = append(r.requests, request)
+	r.mutex.Unlock()
+	return http.DefaultTransport.RoundTrip(request)
+}
+
+// count returns the number of requests sent with the method and path, if not empty.
+func (r *recordingTransport) count(method, path string) int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	var count int
+	for _, request := range r.requests {
+		if (method == "" || request.Method == method) && (path == "" || request.URL.Path == path) {
+			count++
+		}
+	}
+	return count
+}
+
+// connectProxy returns a handler tunneling CONNECT requests to the provided address, whatever their host.
+func connectProxy(addr string, proxied *atomic.Int32) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			http.Error(w, "only CONNECT is supported", http.StatusMethodNotAllowed)
+			return
+		}
+		proxied.Add(1)
+		upstream, err := net.Dial("tcp", addr)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			upstream.Close()
+			return
+		}
+		go func() {
+			defer upstream.Close()
+			defer conn.Close()
+			go func() { _, _ = io.Copy(upstream, buf) }()
+			_, _ = io.Copy(conn, upstream)
+		}()
+	})
+}
+
+func port(addr net.Addr) string {
+	return addr.String()[strings.LastIndex(addr.String(), ":")+1:]
+}
+
+// newClientCertificate returns a self-signed certificate for client authentication, PEM-encoded along with its key.
+func newClientCertificate(t *testing.T) (*x509.Certificate, []byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	h.AssertNil(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "some-client"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	h.AssertNil(t, err)
+	cert, err := x509.ParseCertificate(der)
+	h.AssertNil(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	h.AssertNil(t, err)
+	return cert,
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
@@ -0,0 +1,70 @@
+package imgutil
+
+import (
+	"math"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy describes how operations against registries are retried.
+// Responses with a retryable status code are retried after the delay in their `Retry-After` header,
+// if it is longer than the backoff.
+type RetryPolicy struct {
+	// Attempts is the maximum number of attempts, including the first one.
+	Attempts int
+	// InitialBackoff is the delay before the first retry.
+	InitialBackoff time.Duration
+	// Factor multiplies the delay before each following retry; if less than 1, the delay is constant.
+	Factor float64
+	// MaxBackoff caps the delay between attempts; if zero, the delay is not capped.
+	MaxBackoff time.Duration
+	// Jitter adds a random delay of up to the provided fraction of the delay (e.g., 0.1 adds up to 10%).
+	Jitter float64
+	// RetryableStatusCodes are the HTTP status codes of the responses to retry;
+	// if nil, the status codes of DefaultRetryPolicy are retried.
+	RetryableStatusCodes []int
+}
+
+// DefaultRetryPolicy returns the policy used when none is provided:
+// three attempts, waiting 100ms after the first failure and 200ms after the second,
+// retrying timeouts, throttling (429) and server errors.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		Attempts:       3,
+		InitialBackoff: 100 * time.Millisecond,
+		Factor:         2,
+		MaxBackoff:     10 * time.Second,
+		RetryableStatusCodes: []int{
+			http.StatusRequestTimeout,
+			http.StatusTooManyRequests,
+			http.StatusInternalServerError,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+	}
+}
+
+// Backoff returns the delay before the provided retry (1 for the first retry).
+func (p RetryPolicy) Backoff(retry int) time.Duration {
+	factor := math.Max(p.Factor, 1)
+	delay := float64(p.InitialBackoff) * math.Pow(factor, float64(retry-1))
+	if p.Jitter > 0 {
+		delay += delay * p.Jitter * rand.Float64() // #nosec G404 -- jitter does not need a secure random number
+	}
+	if p.MaxBackoff > 0 && delay > float64(p.MaxBackoff) {
+		return p.MaxBackoff
+	}
+	return time.Duration(delay)
+}
+
+// IsRetryableStatusCode returns true if responses with the provided status code should be retried.
+func (p RetryPolicy) IsRetryableStatusCode(statusCode int) bool {
+	for _, code := range p.RetryableStatusCodes {
+		if code == statusCode {
+			return true
+		}
+	}
+	return false
+}
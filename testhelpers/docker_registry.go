@@ -28,6 +28,7 @@ type DockerRegistry struct {
 	regHandler      http.Handler
 	authnHandler    http.Handler
 	imagePrivileges map[string]ImagePrivileges // map from an imageName to its permissions
+	faults          *faultInjector
 }
 
 type RegistryOption func(registry *DockerRegistry)
@@ -59,7 +60,8 @@ func WithAuth(dockerConfigDir string) RegistryOption {
 
 func NewDockerRegistry(ops ...RegistryOption) *DockerRegistry {
 	dockerRegistry := &DockerRegistry{
-		Name: "test-registry-" + RandString(10),
+		Name:   "test-registry-" + RandString(10),
+		faults: newFaultInjector(),
 	}
 
 	for _, op := range ops {
@@ -128,6 +130,7 @@ func delegator(basicAuthHandler http.Handler, regHandler http.Handler, permissio
 //   - By default the shared handler will be wrapped with a read only handler
 //   - In case credentials are configured, the shared handler will be wrapped with a basic authentication handler and
 //     if any image privileges were set, then the custom handler will be used to wrap the auth handler.
+//   - Faults (see WithFaults) are injected before any other handler
 func (r *DockerRegistry) Start(t *testing.T) {
 	t.Helper()
 
@@ -163,7 +166,7 @@ func (r *DockerRegistry) Start(t *testing.T) {
 
 	r.server = &httptest.Server{
 		Listener: listener,
-		Config:   &http.Server{Handler: r.authnHandler}, //nolint
+		Config:   &http.Server{Handler: r.faults.wrap(r.authnHandler)}, //nolint
 	}
 
 	r.server.Start()
@@ -0,0 +1,175 @@
+package testhelpers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Path patterns matching registry API requests, for use in Fault.PathPattern.
+const (
+	ManifestPathPattern   = `^/v2/.+/manifests/[^/]+$`
+	BlobPathPattern       = `^/v2/.+/blobs/sha256:[0-9a-f]+$`
+	BlobUploadPathPattern = `^/v2/.+/blobs/uploads/`
+)
+
+// Fault describes a failure injected by the test registry into the requests matching the method and path pattern.
+// Latency is added before any other failure; if only latency is set, the request is served normally after the delay.
+type Fault struct {
+	// Method restricts the fault to requests with the method (e.g., "PUT"); if empty, all methods match.
+	Method string
+	// PathPattern is a regular expression matched against the request path; if empty, all paths match.
+	PathPattern string
+	// Times is the number of matching requests that fail; if zero, all matching requests fail.
+	Times int
+
+	// Latency delays the response.
+	Latency time.Duration
+	// StatusCode is returned instead of the response (e.g., http.StatusInternalServerError or http.StatusTooManyRequests).
+	StatusCode int
+	// RetryAfter is returned in the `Retry-After` header (rounded up to seconds) along with StatusCode.
+	RetryAfter time.Duration
+	// TruncateBody returns the headers and half of the body of the response, then closes the connection,
+	// so that reading the body fails with io.ErrUnexpectedEOF.
+	TruncateBody bool
+	// CloseConnection closes the connection without a response, so that the client fails with io.EOF.
+	CloseConnection bool
+}
+
+// WithFaults injects the provided faults into requests to the registry.
+// Faults can also be added (and removed) after the registry is started with DockerRegistry.InjectFault.
+func WithFaults(faults ...Fault) RegistryOption {
+	return func(registry *DockerRegistry) {
+		for _, fault := range faults {
+			registry.faults.add(fault)
+		}
+	}
+}
+
+// InjectFault injects the provided fault into requests to the registry.
+func (r *DockerRegistry) InjectFault(fault Fault) {
+	r.faults.add(fault)
+}
+
+// ClearFaults removes all the faults injected into requests to the registry.
+func (r *DockerRegistry) ClearFaults() {
+	r.faults.clear()
+}
+
+// InjectedFaultCount returns the number of requests that faults were injected into.
+func (r *DockerRegistry) InjectedFaultCount() int {
+	return r.faults.count()
+}
+
+type faultInjector struct {
+	mutex    sync.Mutex
+	faults   []*activeFault
+	injected int
+}
+
+type activeFault struct {
+	Fault
+	path      *regexp.Regexp
+	remaining int
+}
+
+func newFaultInjector() *faultInjector {
+	return &faultInjector{}
+}
+
+func (f *faultInjector) add(fault Fault) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.faults = append(f.faults, &activeFault{
+		Fault:     fault,
+		path:      regexp.MustCompile(fault.PathPattern),
+		remaining: fault.Times,
+	})
+}
+
+func (f *faultInjector) clear() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.faults = nil
+}
+
+func (f *faultInjector) count() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.injected
+}
+
+// next returns the first fault matching the request, if any, counting it as injected.
+func (f *faultInjector) next(request *http.Request) *activeFault {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for idx, fault := range f.faults {
+		if fault.Method != "" && fault.Method != request.Method {
+			continue
+		}
+		if !fault.path.MatchString(request.URL.Path) {
+			continue
+		}
+		if fault.Times > 0 {
+			fault.remaining--
+			if fault.remaining == 0 {
+				f.faults = append(f.faults[:idx], f.faults[idx+1:]...)
+			}
+		}
+		f.injected++
+		return fault
+	}
+	return nil
+}
+
+// wrap returns a handler that injects faults into requests to the provided handler.
+func (f *faultInjector) wrap(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		fault := f.next(request)
+		if fault == nil {
+			handler.ServeHTTP(response, request)
+			return
+		}
+
+		if fault.Latency > 0 {
+			select {
+			case <-request.Context().Done():
+				return
+			case <-time.After(fault.Latency):
+			}
+		}
+
+		switch {
+		case fault.StatusCode != 0:
+			if fault.RetryAfter > 0 {
+				response.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(fault.RetryAfter.Seconds()))))
+			}
+			response.Header().Set("Content-Type", "application/json")
+			response.WriteHeader(fault.StatusCode)
+			_, _ = fmt.Fprintf(response, `{"errors":[{"code":"UNKNOWN","message":"injected fault: %s"}]}`+"\n", http.StatusText(fault.StatusCode))
+		case fault.CloseConnection:
+			panic(http.ErrAbortHandler) // the server closes the connection without logging
+		case fault.TruncateBody:
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+			body := recorder.Body.Bytes()
+			for key, values := range recorder.Header() {
+				response.Header()[key] = values
+			}
+			response.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			response.WriteHeader(recorder.Code)
+			_, _ = response.Write(body[:len(body)/2])
+			if flusher, ok := response.(http.Flusher); ok {
+				flusher.Flush()
+			}
+			panic(http.ErrAbortHandler)
+		default:
+			handler.ServeHTTP(response, request)
+		}
+	})
+}
@@ -440,6 +440,34 @@ func CreateSingleFileTar(path, txt string) (io.Reader, error) {
 	return bytes.NewReader(buf.Bytes()), nil
 }
 
+// Untar extracts the regular files of the tar at the given path into the destination directory.
+func Untar(t *testing.T, tarPath, dest string) {
+	t.Helper()
+
+	f, err := os.Open(filepath.Clean(tarPath))
+	AssertNil(t, err)
+	defer f.Close()
+
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return
+		}
+		AssertNil(t, err)
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		path := filepath.Join(dest, filepath.Clean(hdr.Name))
+		AssertNil(t, os.MkdirAll(filepath.Dir(path), 0750))
+		fh, err := os.Create(path)
+		AssertNil(t, err)
+		_, err = io.Copy(fh, tr) // #nosec G110
+		fh.Close()
+		AssertNil(t, err)
+	}
+}
+
 func RandomLayer(t *testing.T, tmpDir string) (path string, sha string, contents []byte) {
 	t.Helper()
 